@@ -6,12 +6,12 @@ cd $(dirname $0)
 
 set -e -x
 
-# compile the cni binaries
+# compile the cni plugins, which includes the portmap and bandwidth plugins
 cnidir=$(mktemp -d)
 trap "rm -rf $cnidir" EXIT
-git clone https://github.com/containernetworking/cni.git $cnidir/cni --branch v0.3.0
-version=$(cd $cnidir/cni/.git && git describe --tags)
-(cd $cnidir/cni && ./build)
+git clone https://github.com/containernetworking/plugins.git $cnidir/plugins --branch v0.8.7
+version=$(cd $cnidir/plugins/.git && git describe --tags)
+(cd $cnidir/plugins && ./build_linux.sh)
 
 # if we're running in TeamCity, then export the version information.
 if [ -n "$TC_BUILD_NUMBER" ]; then
@@ -26,15 +26,15 @@ chmod 755 $dir
 
 # copy in cni binaries
 mkdir -p $dir/usr/bin
-cp $cnidir/cni/bin/* $dir/usr/bin/
-# except cnitool
-rm $dir/usr/bin/cnitool
+cp $cnidir/plugins/bin/* $dir/usr/bin/
 
 # copy in the networking script
 mkdir -p $dir/opt/network
-cp $BASE_PATH/bin/cni-netplugin-setup $dir/opt/network/setup
+cp $BASE_PATH/bin/cni-netplugin-run $dir/opt/network/run
+cp setup.sh $dir/opt/network/setup
 cp add.sh $dir/opt/network/add
 cp del.sh $dir/opt/network/del
+cp check.sh $dir/opt/network/check
 chmod a+x $dir/opt/network/*
 
 # generate the aci
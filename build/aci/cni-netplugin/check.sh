@@ -0,0 +1,34 @@
+#!/bin/sh
+
+set -e
+
+export PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin
+
+# read stdin
+payload=$(mktemp $TMPDIR/cni-command.XXXXXX)
+cat > $payload <&0
+
+# locate the plugin
+plugin=$(jq -r '.type // ""' < $payload)
+if [ "$plugin" == "" ]; then
+    echo "No CNI plugin specified"
+    exit 1
+fi
+
+# plugins only support CHECK as of CNI 0.4.0, so report the check as skipped
+# for earlier versions
+version=$(jq -r '.cniVersion // "0.2.0"' < $payload)
+case "$version" in
+    0.1.*|0.2.*|0.3.*)
+        jq -n --arg reason "CNI version $version does not support CHECK" '{skipped: $reason}'
+        exit 0
+        ;;
+esac
+
+export CNI_PATH=/usr/bin
+export CNI_COMMAND=CHECK
+export CNI_NETNS=$1
+export CNI_CONTAINERID=$2
+export CNI_IFNAME=$3
+
+$plugin < $payload
@@ -2,8 +2,21 @@
 
 set -e
 
+export PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin
+
 # read stdin
-cat > /dev/null <&0
+payload=$(mktemp $TMPDIR/cni-command.XXXXXX)
+cat > $payload <&0
 
-# sit indefinitely
-cat
+# ensure each of the CNI plugins in the network's chain are available
+plugins=$(jq -r 'if .plugins then .plugins[].type else .type // "" end' < $payload)
+if [ "$plugins" == "" ]; then
+    echo "No CNI plugin specified"
+    exit 1
+fi
+for plugin in $plugins; do
+    if [ ! -x "/usr/bin/$plugin" ]; then
+        echo "CNI plugin $plugin is not available"
+        exit 1
+    fi
+done
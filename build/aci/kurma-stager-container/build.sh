@@ -24,7 +24,10 @@ cp ../../../bin/stager-container-init $dir/init/init
 
 # symlink other stager entry points
 mkdir -p $dir/opt/stager
+ln -s /stager $dir/opt/stager/attach
+ln -s /stager $dir/opt/stager/logs
 ln -s /stager $dir/opt/stager/run
+ln -s /stager $dir/opt/stager/status
 
 # copy some other binaries that may be needed
 mkdir $dir/bin
@@ -5,18 +5,28 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/apcera/kurma/pkg/apiproxy"
 	"github.com/apcera/logray"
 )
 
 func main() {
+	var retainableCapabilities string
+	flag.StringVar(&retainableCapabilities, "retainableCapabilities", "",
+		"Comma separated list of capabilities that apps launched remotely may retain")
+	flag.Parse()
+
 	logray.AddDefaultOutput("stdout://", logray.ALL)
 
 	opts := &apiproxy.Options{}
+	if retainableCapabilities != "" {
+		opts.RetainableCapabilities = strings.Split(retainableCapabilities, ",")
+	}
 
 	s := apiproxy.New(opts)
 	if err := s.Start(); err != nil {
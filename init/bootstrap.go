@@ -24,6 +24,7 @@ import (
 	"github.com/apcera/kurma/pkg/daemon"
 	"github.com/apcera/kurma/pkg/devices"
 	"github.com/apcera/kurma/pkg/imagestore"
+	"github.com/apcera/kurma/pkg/keystore"
 	"github.com/apcera/kurma/pkg/networkmanager"
 	"github.com/apcera/kurma/pkg/podmanager"
 	"github.com/apcera/logray"
@@ -589,6 +590,20 @@ func (r *runner) createImageManager() error {
 	return nil
 }
 
+// createKeystore loads the keys trusted to sign images, if a keystore has been
+// configured.
+func (r *runner) createKeystore() error {
+	if r.config.Keystore == nil {
+		return nil
+	}
+	ks, err := keystore.New(r.config.Keystore)
+	if err != nil {
+		return fmt.Errorf("failed to create the keystore: %v", err)
+	}
+	r.keystore = ks
+	return nil
+}
+
 // createPodManager creates the pod manager to allow pods to be
 // launched.
 func (r *runner) createPodManager() error {
@@ -596,10 +611,30 @@ func (r *runner) createPodManager() error {
 	if r.config.DefaultStagerImage == "" {
 		return fmt.Errorf("a defaultStagerImage setting must be specified")
 	}
-	stagerHash, _, err := aciremote.LoadImage(r.config.DefaultStagerImage, true, r.imageManager)
+	stagerHash, _, err := aciremote.LoadImage(r.config.DefaultStagerImage, r.fetchOptions(), r.imageManager)
 	if err != nil {
 		return fmt.Errorf("failed to fetch default stager image %q: %v", r.config.DefaultStagerImage, err)
 	}
+	if err := r.keystore.CheckImage(stagerHash, r.imageManager); err != nil {
+		return fmt.Errorf("default stager image %q is not trusted: %v", r.config.DefaultStagerImage, err)
+	}
+	r.imageManager.AddReference(stagerHash, "the default stager")
+
+	// retrieve any additional stagers that pods may request
+	trustedStagerHashes := make([]string, 0, len(r.config.TrustedStagerImages))
+	for _, aci := range r.config.TrustedStagerImages {
+		hash, _, err := aciremote.LoadImage(aci, r.fetchOptions(), r.imageManager)
+		if err != nil {
+			r.log.Warnf("Failed to fetch trusted stager image %q: %v", aci, err)
+			continue
+		}
+		if err := r.keystore.CheckImage(hash, r.imageManager); err != nil {
+			r.log.Warnf("Refusing to use stager image %q: %v", aci, err)
+			continue
+		}
+		r.imageManager.AddReference(hash, "a trusted stager")
+		trustedStagerHashes = append(trustedStagerHashes, hash)
+	}
 
 	mopts := &podmanager.Options{
 		PodDirectory:          filepath.Join(kurmaPath, string(kurmaPathPods)),
@@ -607,6 +642,9 @@ func (r *runner) createPodManager() error {
 		VolumeDirectory:       filepath.Join(kurmaPath, string(kurmaPathVolumes)),
 		ParentCgroupName:      r.config.ParentCgroupName,
 		DefaultStagerHash:     stagerHash,
+		TrustedStagerHashes:   trustedStagerHashes,
+		UserNamespaceRange:    r.config.UserNamespaceRange,
+		Keystore:              r.keystore,
 		Log:                   r.log.Clone(),
 	}
 	m, err := podmanager.NewManager(r.imageManager, nil, mopts)
@@ -635,11 +673,16 @@ func (r *runner) createNetworkManager() error {
 	networkDrivers := make([]*backend.NetworkDriver, 0, len(r.config.PodNetworks))
 
 	for _, podNet := range r.config.PodNetworks {
-		hash, _, err := aciremote.LoadImage(podNet.ACI, true, r.imageManager)
+		hash, _, err := aciremote.LoadImage(podNet.ACI, r.fetchOptions(), r.imageManager)
 		if err != nil {
 			r.log.Warnf("Failed to load image for network %q: %v", podNet.Name, err)
 			continue
 		}
+		if err := r.keystore.CheckImage(hash, r.imageManager); err != nil {
+			r.log.Warnf("Refusing to use image for network %q: %v", podNet.Name, err)
+			continue
+		}
+		r.imageManager.AddReference(hash, fmt.Sprintf("network %q", podNet.Name))
 
 		imageID, err := types.NewHash(hash)
 		if err != nil {
@@ -713,6 +756,9 @@ func (r *runner) startServer() error {
 	opts := &daemon.Options{
 		ImageManager:      r.imageManager,
 		PodManager:        r.podManager,
+		NetworkManager:    r.networkManager,
+		Keystore:          r.keystore,
+		FetchOptions:      r.fetchOptions(),
 		SocketFile:        filepath.Join(kurmaPath, "socket"),
 		SocketPermissions: &perms,
 		SocketGroup:       &group,
@@ -730,7 +776,7 @@ func (r *runner) startServer() error {
 // configuration.
 func (r *runner) startInitialPods() error {
 	for d, ip := range r.config.InitialPods {
-		name, podManifest, err := ip.Process(r.imageManager)
+		name, podManifest, err := ip.Process(r.imageManager, r.fetchOptions())
 		if name == "" {
 			name = fmt.Sprintf("pod%d", d+1)
 		}
@@ -766,7 +812,7 @@ func (r *runner) startConsole() error {
 	}
 
 	// Get the pod manifest
-	_, podManifest, err := r.config.Console.Process(r.imageManager)
+	_, podManifest, err := r.config.Console.Process(r.imageManager, r.fetchOptions())
 	if err != nil {
 		r.log.Warnf("Failed to fetch console pod: %v", err)
 		return nil
@@ -841,7 +887,7 @@ func (r *runner) setupDiscoveryProxy() error {
 // them.
 func (r *runner) prefetchImages() error {
 	for _, aci := range r.config.PrefetchImages {
-		_, _, err := aciremote.LoadImage(aci, true, r.imageManager)
+		_, _, err := aciremote.LoadImage(aci, r.fetchOptions(), r.imageManager)
 		if err != nil {
 			r.log.Warnf("Failed to fetch image %q: %v", aci, err)
 			continue
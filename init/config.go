@@ -6,26 +6,34 @@ import (
 	"fmt"
 
 	"github.com/apcera/kurma/kurmad"
+	"github.com/apcera/kurma/pkg/aciremote"
 	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/keystore"
 	"github.com/apcera/kurma/pkg/networkmanager/types"
+	"github.com/apcera/kurma/pkg/podmanager"
 	"github.com/appc/spec/schema"
 )
 
 type kurmaConfig struct {
-	Debug              bool                         `json:"debug,omitempty"`
-	SuccessfulBoot     *string                      `json:"-"`
-	OEMConfig          *OEMConfig                   `json:"oemConfig"`
-	Datasources        []string                     `json:"datasources,omitempty"`
-	Hostname           string                       `json:"hostname,omitempty"`
-	NetworkConfig      kurmaNetworkConfig           `json:"networkConfig,omitempty"`
-	Modules            []string                     `json:"modules,omitmepty"`
-	Disks              []*kurmaDiskConfiguration    `json:"disks,omitempty"`
-	ParentCgroupName   string                       `json:"parentCgroupName,omitempty"`
-	DefaultStagerImage string                       `json:"defaultStagerImage,omitempty"`
-	PrefetchImages     []string                     `json:"prefetchImages,omitempty"`
-	InitialPods        []*kurmad.InitialPodManifest `json:"initialPods,omitempty"`
-	PodNetworks        []*types.NetConf             `json:"podNetworks,omitempty"`
-	Console            kurmaConsoleService          `json:"console,omitempty"`
+	Debug               bool                         `json:"debug,omitempty"`
+	SuccessfulBoot      *string                      `json:"-"`
+	OEMConfig           *OEMConfig                   `json:"oemConfig"`
+	Datasources         []string                     `json:"datasources,omitempty"`
+	Hostname            string                       `json:"hostname,omitempty"`
+	NetworkConfig       kurmaNetworkConfig           `json:"networkConfig,omitempty"`
+	Modules             []string                     `json:"modules,omitmepty"`
+	Disks               []*kurmaDiskConfiguration    `json:"disks,omitempty"`
+	ParentCgroupName    string                       `json:"parentCgroupName,omitempty"`
+	DefaultStagerImage  string                       `json:"defaultStagerImage,omitempty"`
+	TrustedStagerImages []string                     `json:"trustedStagerImages,omitempty"`
+	PrefetchImages      []string                     `json:"prefetchImages,omitempty"`
+	InitialPods         []*kurmad.InitialPodManifest `json:"initialPods,omitempty"`
+	PodNetworks         []*types.NetConf             `json:"podNetworks,omitempty"`
+	Console             kurmaConsoleService          `json:"console,omitempty"`
+	UserNamespaceRange  *podmanager.IDRange          `json:"userNamespaceRange,omitempty"`
+	Keystore            *keystore.Config             `json:"keystore,omitempty"`
+	FetchPolicy         *aciremote.FetchPolicy       `json:"fetchPolicy,omitempty"`
+	KeepDockerLayers    *bool                        `json:"keepDockerLayers,omitempty"`
 }
 
 type OEMConfig struct {
@@ -77,7 +85,7 @@ type kurmaConsoleService struct {
 	SSHKeys     []string                   `json:"sshKeys,omitempty"`
 }
 
-func (s *kurmaConsoleService) Process(imageManager backend.ImageManager) (string, *schema.PodManifest, error) {
+func (s *kurmaConsoleService) Process(imageManager backend.ImageManager, fetchOptions *aciremote.FetchOptions) (string, *schema.PodManifest, error) {
 	if s.ACI != nil && s.PodManifest != nil {
 		return "", nil, fmt.Errorf(`both "aci" and "podManifest" cannot be set at the same time`)
 	}
@@ -85,9 +93,9 @@ func (s *kurmaConsoleService) Process(imageManager backend.ImageManager) (string
 		return "", nil, fmt.Errorf(`must set either "aci" or "podManifest"`)
 	}
 	if s.ACI != nil {
-		return s.ACI.Process(imageManager)
+		return s.ACI.Process(imageManager, fetchOptions)
 	}
-	return s.PodManifest.Process(imageManager)
+	return s.PodManifest.Process(imageManager, fetchOptions)
 }
 
 func (cfg *kurmaConfig) mergeConfig(o *kurmaConfig) {
@@ -140,6 +148,31 @@ func (cfg *kurmaConfig) mergeConfig(o *kurmaConfig) {
 	if o.DefaultStagerImage != "" {
 		cfg.DefaultStagerImage = o.DefaultStagerImage
 	}
+	if len(o.TrustedStagerImages) > 0 {
+		cfg.TrustedStagerImages = append(cfg.TrustedStagerImages, o.TrustedStagerImages...)
+	}
+	if o.UserNamespaceRange != nil {
+		cfg.UserNamespaceRange = o.UserNamespaceRange
+	}
+
+	// keystore, appending any trusted keys
+	if o.Keystore != nil {
+		if cfg.Keystore == nil {
+			cfg.Keystore = &keystore.Config{}
+		}
+		if o.Keystore.Directory != "" {
+			cfg.Keystore.Directory = o.Keystore.Directory
+		}
+		cfg.Keystore.TrustedKeys = append(cfg.Keystore.TrustedKeys, o.Keystore.TrustedKeys...)
+	}
+
+	// replace the fetch policy
+	if o.FetchPolicy != nil {
+		cfg.FetchPolicy = o.FetchPolicy
+	}
+	if o.KeepDockerLayers != nil {
+		cfg.KeepDockerLayers = o.KeepDockerLayers
+	}
 
 	// append init pods
 	if len(o.InitialPods) > 0 {
@@ -5,7 +5,9 @@ package init
 import (
 	"fmt"
 
+	"github.com/apcera/kurma/pkg/aciremote"
 	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/keystore"
 	"github.com/apcera/logray"
 )
 
@@ -18,6 +20,17 @@ type runner struct {
 	podManager     backend.PodManager
 	imageManager   backend.ImageManager
 	networkManager backend.NetworkManager
+	keystore       *keystore.Keystore
+}
+
+// fetchOptions returns the options used when retrieving images.
+func (r *runner) fetchOptions() *aciremote.FetchOptions {
+	return &aciremote.FetchOptions{
+		Insecure:         true,
+		Keystore:         r.keystore,
+		Policy:           r.config.FetchPolicy,
+		KeepDockerLayers: r.config.KeepDockerLayers != nil && *r.config.KeepDockerLayers,
+	}
 }
 
 // Run takes over the process and launches KurmaOS.
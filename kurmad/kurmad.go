@@ -6,16 +6,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/apcera/kurma/pkg/aciremote"
 	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/imagestore"
+	"github.com/apcera/kurma/pkg/keystore"
 	"github.com/apcera/kurma/pkg/networkmanager/types"
+	"github.com/apcera/kurma/pkg/podmanager"
 	"github.com/apcera/logray"
 	"github.com/appc/spec/schema"
 
 	atypes "github.com/appc/spec/schema/types"
 )
 
+const (
+	// imageGCInterval is how often disk usage is checked to determine whether
+	// images should be garbage collected.
+	imageGCInterval = time.Minute
+)
+
 var (
 	// The setup functions that should be run in order to handle setting up
 	// kurmad.
@@ -24,10 +34,13 @@ var (
 		(*runner).loadConfigurationFile,
 		(*runner).configureLogging,
 		(*runner).createDirectories,
+		(*runner).createKeystore,
 		(*runner).createImageManager,
 		(*runner).prefetchImages,
 		(*runner).createPodManager,
 		(*runner).createNetworkManager,
+		(*runner).restorePods,
+		(*runner).startImageGC,
 		(*runner).startDaemon,
 		(*runner).startInitialPods,
 	}
@@ -35,17 +48,44 @@ var (
 
 // Config is the configuration structure of kurmad.
 type Config struct {
-	Debug              bool                  `json:"debug,omitempty"`
-	SocketPath         string                `json:"socketPath,omitempty"`
-	SocketPermissions  *int                  `json:"socketPermissions,omitempty"`
-	ParentCgroupName   string                `json:"parentCgroupName,omitempty"`
-	PodsDirectory      string                `json:"podsDirectory,omitempty"`
-	ImagesDirectory    string                `json:"imagesDirectory,omitempty"`
-	VolumesDirectory   string                `json:"volumesDirectory,omitempty"`
-	DefaultStagerImage string                `json:"defaultStagerImage,omitempty"`
-	PrefetchImages     []string              `json:"prefetchImages,omitempty"`
-	InitialPods        []*InitialPodManifest `json:"initialPods,omitempty"`
-	PodNetworks        []*types.NetConf      `json:"podNetworks"`
+	Debug               bool                  `json:"debug,omitempty"`
+	SocketPath          string                `json:"socketPath,omitempty"`
+	SocketPermissions   *int                  `json:"socketPermissions,omitempty"`
+	ParentCgroupName    string                `json:"parentCgroupName,omitempty"`
+	PodsDirectory       string                `json:"podsDirectory,omitempty"`
+	ImagesDirectory     string                `json:"imagesDirectory,omitempty"`
+	VolumesDirectory    string                `json:"volumesDirectory,omitempty"`
+	DefaultStagerImage  string                `json:"defaultStagerImage,omitempty"`
+	TrustedStagerImages []string              `json:"trustedStagerImages,omitempty"`
+	PrefetchImages      []string              `json:"prefetchImages,omitempty"`
+	InitialPods         []*InitialPodManifest `json:"initialPods,omitempty"`
+	PodNetworks         []*types.NetConf      `json:"podNetworks"`
+
+	// UserNamespaceRange is the range of host uids and gids that are allocated
+	// to pods which request a user namespace without specifying mappings.
+	UserNamespaceRange *podmanager.IDRange `json:"userNamespaceRange,omitempty"`
+
+	// Keystore configures the OpenPGP keys trusted to sign images. When it is
+	// set, stager and network plugin images must be signed by a trusted key.
+	Keystore *keystore.Config `json:"keystore,omitempty"`
+
+	// FetchPolicy restricts the schemes and sources images may be retrieved
+	// from. If it is not set, images may be retrieved from anywhere.
+	FetchPolicy *aciremote.FetchPolicy `json:"fetchPolicy,omitempty"`
+
+	// KeepDockerLayers is whether Docker images are imported with each layer as
+	// its own image by default, so layers are shared between images rather than
+	// every image storing a squashed copy of them.
+	KeepDockerLayers bool `json:"keepDockerLayers,omitempty"`
+
+	// ImageGC is the policy for removing images which are no longer in use.
+	// Images are garbage collected automatically once disk usage reaches its
+	// high watermark.
+	ImageGC *imagestore.GCPolicy `json:"imageGC,omitempty"`
+
+	// LeavePodsRunning specifies that pods should be left running when kurmad
+	// shuts down, so they can be restored once it is started again.
+	LeavePodsRunning bool `json:"leavePodsRunning,omitempty"`
 }
 
 // InitialPodManifest is used to handle the inital pod configuration section,
@@ -59,7 +99,7 @@ type InitialPodManifest struct {
 
 // process handles processing the initial configuration input and turning it
 // into a ready to run pod manifest.
-func (ip *InitialPodManifest) Process(imageManager backend.ImageManager) (string, *schema.PodManifest, error) {
+func (ip *InitialPodManifest) Process(imageManager backend.ImageManager, fetchOptions *aciremote.FetchOptions) (string, *schema.PodManifest, error) {
 	if ip.pod != nil {
 		for i, app := range ip.pod.Apps {
 			if app.Image.ID.Val != "" {
@@ -91,7 +131,7 @@ func (ip *InitialPodManifest) Process(imageManager backend.ImageManager) (string
 	hash, imageManifest := imageManager.FindImage(ip.image, "")
 	if imageManifest == nil {
 		var err error
-		hash, imageManifest, err = aciremote.LoadImage(ip.image, true, imageManager)
+		hash, imageManifest, err = aciremote.LoadImage(ip.image, fetchOptions, imageManager)
 		if err != nil {
 			return ip.name, nil, fmt.Errorf("failed to get a retrieve image %q: %v", ip.image, err)
 		}
@@ -165,6 +205,17 @@ type runner struct {
 	podManager     backend.PodManager
 	imageManager   backend.ImageManager
 	networkManager backend.NetworkManager
+	keystore       *keystore.Keystore
+}
+
+// fetchOptions returns the options used when retrieving images.
+func (r *runner) fetchOptions() *aciremote.FetchOptions {
+	return &aciremote.FetchOptions{
+		Insecure:         true,
+		Keystore:         r.keystore,
+		Policy:           r.config.FetchPolicy,
+		KeepDockerLayers: r.config.KeepDockerLayers,
+	}
 }
 
 // Run takes over the process and launches kurmad.
@@ -10,11 +10,13 @@ import (
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/apcera/kurma/pkg/aciremote"
 	"github.com/apcera/kurma/pkg/backend"
 	"github.com/apcera/kurma/pkg/daemon"
 	"github.com/apcera/kurma/pkg/imagestore"
+	"github.com/apcera/kurma/pkg/keystore"
 	"github.com/apcera/kurma/pkg/networkmanager"
 	"github.com/apcera/kurma/pkg/podmanager"
 	"github.com/apcera/logray"
@@ -34,7 +36,7 @@ func (r *runner) setupSignalHandling() error {
 			switch sig {
 			case syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT:
 				r.log.Infof("Received %s. Shutting down.", sig.String())
-				if r.podManager != nil {
+				if r.podManager != nil && (r.config == nil || !r.config.LeavePodsRunning) {
 					r.podManager.Shutdown()
 				}
 				r.log.Flush()
@@ -121,11 +123,34 @@ func (r *runner) createDirectories() error {
 	return nil
 }
 
+// createKeystore loads the keys trusted to sign images, if a keystore has been
+// configured.
+func (r *runner) createKeystore() error {
+	if r.config.Keystore == nil {
+		return nil
+	}
+
+	if r.config.Keystore.Directory != "" && !filepath.IsAbs(r.config.Keystore.Directory) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to determine current working directory: %v", err)
+		}
+		r.config.Keystore.Directory = filepath.Join(wd, r.config.Keystore.Directory)
+	}
+
+	ks, err := keystore.New(r.config.Keystore)
+	if err != nil {
+		return fmt.Errorf("failed to create the keystore: %v", err)
+	}
+	r.keystore = ks
+	return nil
+}
+
 // prefetchImages is used to fetch specified images on start up to pre-load
 // them.
 func (r *runner) prefetchImages() error {
 	for _, aci := range r.config.PrefetchImages {
-		_, _, err := aciremote.LoadImage(aci, true, r.imageManager)
+		_, _, err := aciremote.LoadImage(aci, r.fetchOptions(), r.imageManager)
 		if err != nil {
 			r.log.Warnf("Failed to fetch image %q: %v", aci, err)
 			continue
@@ -141,6 +166,7 @@ func (r *runner) createImageManager() error {
 	iopts := &imagestore.Options{
 		Directory: r.config.ImagesDirectory,
 		Log:       r.log.Clone(),
+		GCPolicy:  r.config.ImageGC,
 	}
 	imageManager, err := imagestore.New(iopts)
 	if err != nil {
@@ -157,10 +183,30 @@ func (r *runner) createPodManager() error {
 	if r.config.DefaultStagerImage == "" {
 		return fmt.Errorf("a defaultStagerImage setting must be specified")
 	}
-	stagerHash, _, err := aciremote.LoadImage(r.config.DefaultStagerImage, true, r.imageManager)
+	stagerHash, _, err := aciremote.LoadImage(r.config.DefaultStagerImage, r.fetchOptions(), r.imageManager)
 	if err != nil {
 		return fmt.Errorf("failed to fetch default stager image %q: %v", r.config.DefaultStagerImage, err)
 	}
+	if err := r.keystore.CheckImage(stagerHash, r.imageManager); err != nil {
+		return fmt.Errorf("default stager image %q is not trusted: %v", r.config.DefaultStagerImage, err)
+	}
+	r.imageManager.AddReference(stagerHash, "the default stager")
+
+	// retrieve any additional stagers that pods may request
+	trustedStagerHashes := make([]string, 0, len(r.config.TrustedStagerImages))
+	for _, aci := range r.config.TrustedStagerImages {
+		hash, _, err := aciremote.LoadImage(aci, r.fetchOptions(), r.imageManager)
+		if err != nil {
+			r.log.Warnf("Failed to fetch trusted stager image %q: %v", aci, err)
+			continue
+		}
+		if err := r.keystore.CheckImage(hash, r.imageManager); err != nil {
+			r.log.Warnf("Refusing to use stager image %q: %v", aci, err)
+			continue
+		}
+		r.imageManager.AddReference(hash, "a trusted stager")
+		trustedStagerHashes = append(trustedStagerHashes, hash)
+	}
 
 	mopts := &podmanager.Options{
 		PodDirectory:          r.config.PodsDirectory,
@@ -168,6 +214,9 @@ func (r *runner) createPodManager() error {
 		VolumeDirectory:       r.config.VolumesDirectory,
 		ParentCgroupName:      r.config.ParentCgroupName,
 		DefaultStagerHash:     stagerHash,
+		TrustedStagerHashes:   trustedStagerHashes,
+		UserNamespaceRange:    r.config.UserNamespaceRange,
+		Keystore:              r.keystore,
 		Log:                   r.log.Clone(),
 	}
 	m, err := podmanager.NewManager(r.imageManager, nil, mopts)
@@ -194,11 +243,16 @@ func (r *runner) createNetworkManager() error {
 	networkDrivers := make([]*backend.NetworkDriver, 0, len(r.config.PodNetworks))
 
 	for _, podNet := range r.config.PodNetworks {
-		hash, _, err := aciremote.LoadImage(podNet.ACI, true, r.imageManager)
+		hash, _, err := aciremote.LoadImage(podNet.ACI, r.fetchOptions(), r.imageManager)
 		if err != nil {
 			r.log.Warnf("Failed to load image for network %q: %v", podNet.Name, err)
 			continue
 		}
+		if err := r.keystore.CheckImage(hash, r.imageManager); err != nil {
+			r.log.Warnf("Refusing to use image for network %q: %v", podNet.Name, err)
+			continue
+		}
+		r.imageManager.AddReference(hash, fmt.Sprintf("network %q", podNet.Name))
 
 		imageID, err := types.NewHash(hash)
 		if err != nil {
@@ -221,6 +275,36 @@ func (r *runner) createNetworkManager() error {
 	return nil
 }
 
+// restorePods reloads any pods left running by a previous run of kurmad. This
+// happens after networking is set up, so that any pods which have exited can
+// have their networking deprovisioned. The networking pod from the previous run
+// is replaced by the one that was just launched, which picks up the plugin state,
+// such as allocated addresses, from the volume they share.
+func (r *runner) restorePods() error {
+	if err := r.podManager.Restore(); err != nil {
+		r.log.Errorf("Failed to restore pods: %v", err)
+	}
+	return nil
+}
+
+// startImageGC periodically garbage collects images if a policy has been
+// configured. It is started once pods have been restored so the images they
+// are using have been referenced.
+func (r *runner) startImageGC() error {
+	if r.config.ImageGC == nil || r.config.ImageGC.HighWatermark <= 0 {
+		return nil
+	}
+
+	go func() {
+		for range time.Tick(imageGCInterval) {
+			if _, err := r.imageManager.GarbageCollect(); err != nil {
+				r.log.Warnf("Failed to garbage collect images: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
 // startDaemon begins the main Kurma RPC server and will take over execution.
 func (r *runner) startDaemon() error {
 	perms := os.FileMode(0666)
@@ -231,6 +315,9 @@ func (r *runner) startDaemon() error {
 	opts := &daemon.Options{
 		ImageManager:         r.imageManager,
 		PodManager:           r.podManager,
+		NetworkManager:       r.networkManager,
+		Keystore:             r.keystore,
+		FetchOptions:         r.fetchOptions(),
 		SocketRemoveIfExists: true,
 		SocketFile:           r.config.SocketPath,
 		SocketPermissions:    &perms,
@@ -245,13 +332,23 @@ func (r *runner) startDaemon() error {
 	return nil
 }
 
-// startInitialPods runs the initial pods from the configuration file.
+// startInitialPods runs the initial pods from the configuration file. Any that
+// are still running from a previous run of kurmad are left as is.
 func (r *runner) startInitialPods() error {
+	existing := make(map[string]bool)
+	for _, pod := range r.podManager.Pods() {
+		existing[pod.Name()] = true
+	}
+
 	for d, ip := range r.config.InitialPods {
-		name, podManifest, err := ip.Process(r.imageManager)
+		name, podManifest, err := ip.Process(r.imageManager, r.fetchOptions())
 		if name == "" {
 			name = fmt.Sprintf("initial-pod-%d", d+1)
 		}
+		if existing[name] {
+			r.log.Infof("Pod %q was restored, not launching it again.", name)
+			continue
+		}
 		if err != nil {
 			r.log.Errorf("Failed to configure pod %q: %v", name, err)
 			continue
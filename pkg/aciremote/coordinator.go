@@ -0,0 +1,150 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package aciremote
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/keystore"
+	"github.com/appc/spec/discovery"
+	"github.com/appc/spec/schema"
+
+	docker2acicommon "github.com/appc/docker2aci/lib/common"
+)
+
+// fetches tracks the image loads currently in progress.
+var fetches = &fetchCoordinator{fetches: make(map[fetchKey]*inflightFetch)}
+
+// fetchKey identifies an image load by the Image Manager it is loaded into, the
+// normalized URI of the image, and the options which decide whether the image
+// may be loaded and how it is verified. Loads with different options don't
+// share a fetch, so a load can't be given an image that its own options would
+// have rejected.
+type fetchKey struct {
+	imageManager backend.ImageManager
+	uri          string
+	insecure     bool
+	keystore     *keystore.Keystore
+	policy       *FetchPolicy
+}
+
+// newFetchKey returns the key for loading the image at the normalized URI with
+// the provided options.
+func newFetchKey(imageManager backend.ImageManager, uri string, opts *FetchOptions) fetchKey {
+	return fetchKey{
+		imageManager: imageManager,
+		uri:          uri,
+		insecure:     opts.Insecure,
+		keystore:     opts.Keystore,
+		policy:       opts.Policy,
+	}
+}
+
+// fetchCoordinator coalesces concurrent loads of the same image, so that only
+// one of them retrieves it and the others wait on its result.
+type fetchCoordinator struct {
+	fetches map[fetchKey]*inflightFetch
+	lock    sync.Mutex
+}
+
+// inflightFetch is an image load in progress, which reports its progress to
+// every caller waiting on it.
+type inflightFetch struct {
+	done     chan struct{}
+	hash     string
+	manifest *schema.ImageManifest
+	err      error
+
+	progress     []func(Progress)
+	progressLock sync.Mutex
+}
+
+// load calls fn to load the image unless a load of the same image is already
+// in progress, in which case it waits for that load and returns its result.
+func (c *fetchCoordinator) load(key fetchKey, opts *FetchOptions, fn func(*FetchOptions) (string, *schema.ImageManifest, error)) (string, *schema.ImageManifest, error) {
+	c.lock.Lock()
+	f, exists := c.fetches[key]
+	if !exists {
+		f = &inflightFetch{done: make(chan struct{})}
+		c.fetches[key] = f
+	}
+	if opts.Progress != nil {
+		f.progressLock.Lock()
+		f.progress = append(f.progress, opts.Progress)
+		f.progressLock.Unlock()
+	}
+	c.lock.Unlock()
+
+	if exists {
+		<-f.done
+		return f.hash, f.manifest, f.err
+	}
+
+	fopts := *opts
+	fopts.Progress = f.report
+	f.hash, f.manifest, f.err = fn(&fopts)
+
+	c.lock.Lock()
+	delete(c.fetches, key)
+	c.lock.Unlock()
+	close(f.done)
+	return f.hash, f.manifest, f.err
+}
+
+// report passes the progress on to each of the callers waiting on the load.
+func (f *inflightFetch) report(p Progress) {
+	f.progressLock.Lock()
+	defer f.progressLock.Unlock()
+	for _, fn := range f.progress {
+		fn(p)
+	}
+}
+
+// normalizeURI returns the URI in a canonical form, so that different ways of
+// referring to the same image share a fetch. The options which change the
+// image that is loaded are included.
+func normalizeURI(imageUri string, opts *FetchOptions) (string, error) {
+	u, err := url.Parse(imageUri)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "docker":
+		p, err := docker2acicommon.ParseDockerURL(imageUri[9:])
+		if err != nil {
+			return "", fmt.Errorf("failed to parse Docker image %q: %v", imageUri, err)
+		}
+		ref := ":" + p.Tag
+		if p.Digest != "" {
+			ref = "@" + p.Digest
+		}
+		uri := "docker://" + p.IndexURL + "/" + p.ImageName + ref
+		if opts.KeepDockerLayers {
+			uri += "#layers"
+		}
+		return uri, nil
+
+	case "":
+		app, err := discovery.NewAppFromString(imageUri)
+		if err != nil {
+			return "", err
+		}
+		labels := make(url.Values)
+		for k, v := range app.Labels {
+			labels.Set(k.String(), v)
+		}
+		for k, v := range opts.Labels {
+			labels.Set(k.String(), v)
+		}
+		return app.Name.String() + "?" + labels.Encode(), nil
+
+	default:
+		u.Host = strings.ToLower(u.Host)
+		return u.String(), nil
+	}
+}
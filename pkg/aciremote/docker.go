@@ -0,0 +1,207 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package aciremote
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/ociimage"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+
+	docker2aci "github.com/appc/docker2aci/lib"
+	docker2acicommon "github.com/appc/docker2aci/lib/common"
+)
+
+// convertDocker converts the Docker image into ACIs within the directory,
+// returning their paths from the base layer up. If squash is true, a single
+// ACI containing all of the layers is returned.
+func convertDocker(imageUri string, opts *FetchOptions, squash bool, dir string) ([]string, error) {
+	output := filepath.Join(dir, "output")
+	if err := os.Mkdir(output, os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+
+	// Squashed layers are written to a temporary directory beneath dir before
+	// the squashed image is written to the output directory.
+	stopWatching := watchConversion(dir, opts, func(p string) bool {
+		return (filepath.Dir(p) == output) != squash
+	})
+	acis, err := docker2aci.ConvertRemoteRepo(imageUri[9:], docker2aci.RemoteConfig{
+		CommonConfig: docker2aci.CommonConfig{
+			Squash:      squash,
+			OutputDir:   output,
+			TmpDir:      dir,
+			Compression: docker2acicommon.NoCompression,
+		},
+		Insecure: opts.Insecure,
+	})
+	stopWatching()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Docker image: %v", err)
+	}
+	return acis, nil
+}
+
+// loadDockerLayers converts the Docker image and imports each of its layers
+// as its own image, so that layers are shared between the images built on
+// them. It returns the hash and manifest of the top level image.
+func loadDockerLayers(imageUri string, opts *FetchOptions, imageManager backend.ImageManager) (string, *schema.ImageManifest, error) {
+	tmpdir, err := ioutil.TempDir(os.TempDir(), "docker2aci")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp path to handle Docker image conversion: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	acis, err := convertDocker(imageUri, opts, false, tmpdir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	opts.report(Progress{Stage: StageImporting})
+	importer := ociimage.NewImporter(imageManager.CreateImage)
+	var lower []string
+	var top *schema.ImageManifest
+	for i, aci := range acis {
+		layer := filepath.Join(tmpdir, fmt.Sprintf("layer-%d.tar", i))
+		manifest, err := convertLayerACI(aci, layer, lower)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to convert Docker layer: %v", err)
+		}
+		if err := importer.AddLayer(layer, ""); err != nil {
+			return "", nil, err
+		}
+		os.Remove(layer)
+		lower = manifest.PathWhitelist
+		top = manifest
+	}
+	if top == nil {
+		return "", nil, fmt.Errorf("the Docker image has no layers")
+	}
+
+	// The top level image takes the manifest of the top layer, in the same way
+	// as the manifest of a squashed image.
+	manifest := *top
+	manifest.Labels = nil
+	for _, l := range top.Labels {
+		if l.Name.String() != "layer" {
+			manifest.Labels = append(manifest.Labels, l)
+		}
+	}
+	if i := strings.LastIndex(manifest.Name.String(), "-"); i > 0 {
+		manifest.Name = types.ACIdentifier(manifest.Name.String()[:i])
+	}
+	manifest.PathWhitelist = nil
+	return importer.Finish(&manifest)
+}
+
+// convertLayerACI converts a layer ACI produced by docker2aci back into a layer
+// tar file using OCI whiteouts. docker2aci records the files which exist once
+// each layer is applied in the manifest's path whitelist rather than keeping
+// the layer's whiteouts, so any path in the whitelist of the layer beneath
+// which is missing from the layer's own whitelist has been removed. It
+// returns the layer's manifest.
+func convertLayerACI(aci, layer string, lower []string) (*schema.ImageManifest, error) {
+	in, err := os.Open(aci)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	out, err := os.Create(layer)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	tr := tar.NewReader(in)
+	tw := tar.NewWriter(out)
+	var manifest *schema.ImageManifest
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(header.Name)
+		if name == "manifest" {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return nil, fmt.Errorf("failed to parse layer manifest: %v", err)
+			}
+			continue
+		}
+		if !strings.HasPrefix(name, "rootfs/") {
+			continue
+		}
+
+		header.Name = strings.TrimPrefix(header.Name, "rootfs/")
+		if header.Typeflag == tar.TypeLink {
+			header.Linkname = strings.TrimPrefix(path.Clean(header.Linkname), "rootfs/")
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return nil, err
+		}
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("layer %s has no manifest", filepath.Base(aci))
+	}
+
+	for _, p := range removedPaths(lower, manifest.PathWhitelist) {
+		dir, base := path.Split(strings.TrimPrefix(p, "/"))
+		header := &tar.Header{
+			Name:     dir + ".wh." + base,
+			Mode:     0644,
+			ModTime:  time.Unix(0, 0),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, tw.Close()
+}
+
+// removedPaths returns the paths within lower which aren't in current, in
+// order. Paths within a directory that was removed are left out, since the
+// directory's whiteout covers them. Nothing is removed if current is empty,
+// since there is then no whitelist to compare against.
+func removedPaths(lower, current []string) []string {
+	if len(current) == 0 {
+		return nil
+	}
+	exists := make(map[string]bool, len(current))
+	for _, p := range current {
+		exists[path.Clean(p)] = true
+	}
+	removed := make(map[string]bool)
+	for _, p := range lower {
+		if p = path.Clean(p); !exists[p] {
+			removed[p] = true
+		}
+	}
+
+	var paths []string
+	for p := range removed {
+		if !removed[path.Dir(p)] {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
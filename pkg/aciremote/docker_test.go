@@ -0,0 +1,96 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package aciremote
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+// writeLayerACI writes an ACI in the form docker2aci produces for each layer.
+func writeLayerACI(t *testing.T, file string, pathWhitelist []string, files ...string) {
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatalf("Error creating layer: %s", err)
+	}
+	defer f.Close()
+
+	manifest := schema.BlankImageManifest()
+	manifest.Name = types.ACIdentifier("example.com/app-0123")
+	manifest.PathWhitelist = pathWhitelist
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("Error encoding manifest: %s", err)
+	}
+
+	tw := tar.NewWriter(f)
+	entries := append([]string{"rootfs", ""}, files...)
+	entries = append(entries, "manifest", string(b))
+	for i := 0; i < len(entries); i += 2 {
+		header := &tar.Header{Name: entries[i], Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(entries[i+1]))}
+		if entries[i+1] == "" {
+			header.Mode = 0755
+			header.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Error writing layer: %s", err)
+		}
+		tw.Write([]byte(entries[i+1]))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Error writing layer: %s", err)
+	}
+}
+
+func TestConvertLayerACI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aciremote")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the layer removes /etc/passwd and /var/cache, and replaces /bin/sh
+	lower := []string{"/bin", "/bin/sh", "/etc", "/etc/passwd", "/etc/group", "/var", "/var/cache", "/var/cache/old"}
+	aci := filepath.Join(dir, "layer.aci")
+	writeLayerACI(t, aci, []string{"/bin", "/bin/sh", "/etc", "/etc/group", "/var"}, "rootfs/bin", "", "rootfs/bin/sh", "sh")
+
+	layer := filepath.Join(dir, "layer.tar")
+	manifest, err := convertLayerACI(aci, layer, lower)
+	if err != nil {
+		t.Fatalf("Error converting layer: %s", err)
+	}
+	if manifest.Name.String() != "example.com/app-0123" {
+		t.Fatalf("Expected the layer manifest; got %q", manifest.Name)
+	}
+
+	f, err := os.Open(layer)
+	if err != nil {
+		t.Fatalf("Error opening converted layer: %s", err)
+	}
+	defer f.Close()
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Error reading converted layer: %s", err)
+		}
+		names = append(names, header.Name)
+	}
+
+	expected := []string{"bin", "bin/sh", "etc/.wh.passwd", "var/.wh.cache"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected the converted layer to contain %v; got %v", expected, names)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package aciremote
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/appc/spec/discovery"
+
+	docker2acicommon "github.com/appc/docker2aci/lib/common"
+)
+
+// discoveryScheme is the name used within a FetchPolicy for images that are
+// retrieved through App Container discovery.
+const discoveryScheme = "discovery"
+
+// FetchPolicy restricts where images may be retrieved from. Images retrieved
+// through discovery must be allowed both by their name and by the URL of the
+// endpoint they are downloaded from.
+//
+// The Allow and Deny patterns are globs matched against the source of the
+// image: the host and path for http and https, the registry and repository
+// for docker (such as "registry-1.docker.io/library/busybox"), the image name
+// for discovery, and the path for file. A pattern matches if it matches the
+// whole source or any leading path segments of it, so "*.example.com" matches
+// every image from a host under example.com.
+type FetchPolicy struct {
+	// Schemes are the schemes images may be retrieved with: file, http, https,
+	// docker, and discovery. If it is empty, all schemes are allowed.
+	Schemes []string `json:"schemes,omitempty"`
+
+	// Allow are the patterns of sources images may be retrieved from. If it is
+	// empty, all sources are allowed.
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny are the patterns of sources images may not be retrieved from, even
+	// if they are allowed.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// Check returns an error naming the rule that blocks the image from being
+// retrieved, or nil if it is allowed. It is safe to call on a nil policy,
+// which allows everything.
+func (p *FetchPolicy) Check(imageUri string) error {
+	if p == nil {
+		return nil
+	}
+
+	scheme, source, err := imageSource(imageUri)
+	if err != nil {
+		return err
+	}
+
+	if len(p.Schemes) > 0 && !containsString(p.Schemes, scheme) {
+		return fmt.Errorf("image %q is denied by the fetch policy: scheme %q is not in the allowed schemes %v", imageUri, scheme, p.Schemes)
+	}
+	for _, pattern := range p.Deny {
+		if patternMatches(pattern, source) {
+			return fmt.Errorf("image %q is denied by the fetch policy: %q matches deny rule %q", imageUri, source, pattern)
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if patternMatches(pattern, source) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %q is denied by the fetch policy: %q does not match any of the allow rules %v", imageUri, source, p.Allow)
+}
+
+// imageSource returns the policy scheme of the image and the source the Allow
+// and Deny patterns are matched against.
+func imageSource(imageUri string) (string, string, error) {
+	u, err := url.Parse(imageUri)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch u.Scheme {
+	case "file":
+		return u.Scheme, filepath.Join(u.Host, u.Path), nil
+	case "http", "https":
+		return u.Scheme, strings.TrimSuffix(u.Host+u.Path, "/"), nil
+	case "docker":
+		p, err := docker2acicommon.ParseDockerURL(imageUri[9:])
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse Docker image %q: %v", imageUri, err)
+		}
+		return u.Scheme, p.IndexURL + "/" + p.ImageName, nil
+	case "":
+		app, err := discovery.NewAppFromString(imageUri)
+		if err != nil {
+			return "", "", err
+		}
+		return discoveryScheme, app.Name.String(), nil
+	default:
+		return "", "", fmt.Errorf("%q scheme not supported", u.Scheme)
+	}
+}
+
+// patternMatches returns whether the glob pattern matches the source or any of
+// its leading path segments.
+func patternMatches(pattern, source string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	for i := 0; i <= len(source); i++ {
+		if i == 0 || (i < len(source) && source[i] != '/') {
+			continue
+		}
+		if ok, _ := path.Match(pattern, source[:i]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package aciremote
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFetchPolicy(t *testing.T) {
+	policy := &FetchPolicy{
+		Schemes: []string{"https", "docker", "discovery"},
+		Allow:   []string{"*.internal.example.com", "example.com/apps"},
+		Deny:    []string{"registry.internal.example.com/untrusted"},
+	}
+
+	allowed := []string{
+		"https://registry.internal.example.com/images/app.aci",
+		"docker://mirror.internal.example.com/library/busybox:latest",
+		"example.com/apps/web:1.0",
+		"example.com/apps",
+	}
+	for _, uri := range allowed {
+		if err := policy.Check(uri); err != nil {
+			t.Fatalf("Expected %q to be allowed; got %s", uri, err)
+		}
+	}
+
+	denied := map[string]string{
+		"http://registry.internal.example.com/app.aci":                  `scheme "http"`,
+		"file:///tmp/app.aci":                                           `scheme "file"`,
+		"https://registry.internal.example.com/untrusted/app.aci":       `deny rule "registry.internal.example.com/untrusted"`,
+		"docker://busybox":                                              `"registry-1.docker.io/library/busybox" does not match`,
+		"example.com/appsweb":                                           "does not match",
+		"https://registry.internal.example.com.attacker.com/app.aci":    "does not match",
+		"docker://registry.internal.example.com/untrusted/busybox:1.24": "deny rule",
+	}
+	for uri, rule := range denied {
+		err := policy.Check(uri)
+		if err == nil {
+			t.Fatalf("Expected %q to be denied", uri)
+		}
+		if !strings.Contains(err.Error(), rule) {
+			t.Fatalf("Expected the denial of %q to name %s; got %s", uri, rule, err)
+		}
+	}
+
+	// A nil policy allows everything.
+	var none *FetchPolicy
+	if err := none.Check("http://example.com/app.aci"); err != nil {
+		t.Fatalf("Expected no error from a nil policy; got %s", err)
+	}
+
+	// Retrieval is blocked before anything is fetched.
+	_, _, err := retrieveImage("file:///tmp/app.aci", nil, &FetchOptions{Policy: policy})
+	if err == nil || !strings.Contains(err.Error(), "fetch policy") {
+		t.Fatalf("Expected retrieval to be denied by the fetch policy; got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package aciremote
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// StageDiscovering is reported while an image's endpoints are discovered.
+	StageDiscovering = "discovering"
+
+	// StageDownloading is reported as an image is downloaded.
+	StageDownloading = "downloading"
+
+	// StageConverting is reported as a Docker image's layers are downloaded and
+	// converted to an ACI.
+	StageConverting = "converting"
+
+	// StageVerifying is reported while an image's signature is verified.
+	StageVerifying = "verifying"
+
+	// StageImporting is reported while an image is added to the image manager.
+	StageImporting = "importing"
+)
+
+// progressInterval is the minimum amount of time between the progress updates
+// reported while data is transferred.
+var progressInterval = 250 * time.Millisecond
+
+// Progress describes how far along retrieving an image is.
+type Progress struct {
+	// Stage is the current stage of the retrieval.
+	Stage string
+
+	// Bytes is the number of bytes handled so far in the stage.
+	Bytes int64
+
+	// Total is the expected number of bytes for the stage, or 0 if it isn't
+	// known.
+	Total int64
+
+	// Layers is the number of layers converted so far for Docker images.
+	Layers int
+}
+
+// report passes the progress to the progress function, if one is set.
+func (opts *FetchOptions) report(p Progress) {
+	if opts.Progress != nil {
+		opts.Progress(p)
+	}
+}
+
+// progressReader reports the number of bytes read through it, at most once
+// every progressInterval, and once more when the end is reached.
+type progressReader struct {
+	r        io.Reader
+	opts     *FetchOptions
+	progress Progress
+	last     time.Time
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.progress.Bytes += int64(n)
+	if err == io.EOF || time.Since(pr.last) >= progressInterval {
+		pr.last = time.Now()
+		pr.opts.report(pr.progress)
+	}
+	return n, err
+}
+
+// watchConversion reports the progress of a Docker conversion taking place in
+// the directory, based on the amount of data and the number of layer ACIs
+// written beneath it, until the returned function is called. isLayer reports
+// whether an ACI is a layer, since a squashed ACI shouldn't be counted.
+func watchConversion(dir string, opts *FetchOptions, isLayer func(string) bool) func() {
+	opts.report(Progress{Stage: StageConverting})
+	if opts.Progress == nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			p := Progress{Stage: StageConverting}
+			filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+				if err != nil || fi.IsDir() {
+					return nil
+				}
+				p.Bytes += fi.Size()
+				if strings.HasSuffix(path, ".aci") && isLayer(path) {
+					p.Layers++
+				}
+				return nil
+			})
+			opts.report(p)
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
@@ -10,15 +10,15 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/keystore"
+	"github.com/apcera/kurma/pkg/ociimage"
 	"github.com/apcera/util/tempfile"
 	"github.com/appc/spec/discovery"
 	"github.com/appc/spec/schema"
 	"github.com/appc/spec/schema/types"
-
-	docker2aci "github.com/appc/docker2aci/lib"
-	docker2acicommon "github.com/appc/docker2aci/lib/common"
 )
 
 var (
@@ -34,108 +34,220 @@ func init() {
 	discovery.ClientInsecureTLS.Transport = &http.Transport{}
 }
 
+// FetchOptions contains the settings used when retrieving images.
+type FetchOptions struct {
+	// Insecure allows images to be discovered and retrieved over plain HTTP.
+	Insecure bool
+
+	// Keystore is used to verify the signatures of images. If it is nil,
+	// signatures aren't checked.
+	Keystore *keystore.Keystore
+
+	// Policy restricts where images may be retrieved from. If it is nil, any
+	// source is allowed.
+	Policy *FetchPolicy
+
+	// KeepDockerLayers imports each layer of a Docker image as its own image
+	// rather than squashing them, so that images built on the same layers
+	// share them.
+	KeepDockerLayers bool
+
+	// Labels are additional labels used when discovering images.
+	Labels map[types.ACIdentifier]string
+
+	// Progress is called as the retrieval of an image progresses. It may be
+	// nil.
+	Progress func(Progress)
+}
+
 // RetrieveImage can be used to retrieve a remote image, and optionally discover
 // an image based on the App Container Image Discovery specification. Supports
 // handling local images as well as
 func RetrieveImage(imageUri string, labels map[types.ACIdentifier]string, insecure bool) (tempfile.ReadSeekCloser, error) {
+	r, _, err := retrieveImage(imageUri, labels, &FetchOptions{Insecure: insecure})
+	return r, err
+}
+
+// retrieveImage retrieves the image and, if a keystore is configured, verifies
+// its signature. It returns the fingerprint of the key that signed the image,
+// or an empty string if the image is unsigned. Images retrieved through
+// discovery must be signed, while other images are only checked if a
+// signature is found alongside them. The fetch policy is checked before
+// anything is retrieved, including the endpoints found through discovery.
+func retrieveImage(imageUri string, labels map[types.ACIdentifier]string, opts *FetchOptions) (tempfile.ReadSeekCloser, string, error) {
 	u, err := url.Parse(imageUri)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if err := opts.Policy.Check(imageUri); err != nil {
+		return nil, "", err
 	}
 
 	insecureOption := discovery.InsecureNone
-	if insecure {
+	if opts.Insecure {
 		insecureOption = discovery.InsecureHTTP
 	}
 
 	switch u.Scheme {
 	case "file":
 		// for file:// urls, just load the file and return it
-		filename := u.Path
-		if u.Host != "" {
-			filename = filepath.Join(u.Host, u.Path)
+		f, err := os.Open(localPath(u))
+		if err != nil {
+			return nil, "", err
 		}
-		return os.Open(filename)
+		return verifyImage(f, imageUri+".asc", "", opts, false)
 
 	case "http", "https":
 		// Handle HTTP retrievals, wrapped with a tempfile that cleans up.
+		opts.report(Progress{Stage: StageDownloading})
 		resp, err := Client.Get(imageUri)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		defer resp.Body.Close()
 
 		switch resp.StatusCode {
 		case http.StatusOK:
 		default:
-			return nil, fmt.Errorf("HTTP %d on retrieving %q", resp.StatusCode, imageUri)
+			return nil, "", fmt.Errorf("HTTP %d on retrieving %q", resp.StatusCode, imageUri)
 		}
 
-		return tempfile.New(resp.Body)
+		body := &progressReader{
+			r:        resp.Body,
+			opts:     opts,
+			progress: Progress{Stage: StageDownloading, Total: resp.ContentLength},
+			last:     time.Now(),
+		}
+		if body.progress.Total < 0 {
+			body.progress.Total = 0
+		}
+		f, err := tempfile.New(body)
+		if err != nil {
+			return nil, "", err
+		}
+		return verifyImage(f, imageUri+".asc", "", opts, false)
 
 	case "docker":
-		dockerName := imageUri[9:]
-
 		// create a temp path for the conversion
 		tmpdir, err := ioutil.TempDir(os.TempDir(), "docker2aci")
 		if err != nil {
-			return nil, fmt.Errorf("failed to create temp path to handle Docker image conversion: %v", err)
+			return nil, "", fmt.Errorf("failed to create temp path to handle Docker image conversion: %v", err)
 		}
 		defer os.RemoveAll(tmpdir)
 
-		acis, err := docker2aci.ConvertRemoteRepo(dockerName, docker2aci.RemoteConfig{
-			CommonConfig: docker2aci.CommonConfig{
-				Squash:      true,
-				OutputDir:   tmpdir,
-				TmpDir:      tmpdir,
-				Compression: docker2acicommon.NoCompression,
-			},
-			Insecure: insecure,
-		})
+		acis, err := convertDocker(imageUri, opts, true, tmpdir)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert Docker image: %v", err)
+			return nil, "", err
 		}
 
 		f, err := os.Open(acis[0])
 		if err != nil {
-			return nil, fmt.Errorf("failed to open converted Docker image: %v", err)
+			return nil, "", fmt.Errorf("failed to open converted Docker image: %v", err)
 		}
-		return f, nil
+		return f, "", nil
 
 	case "":
 		app, err := discovery.NewAppFromString(imageUri)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		for k, v := range labels {
 			app.Labels[k] = v
 		}
 
+		opts.report(Progress{Stage: StageDiscovering})
 		endpoints, _, err := discovery.DiscoverACIEndpoints(*app, nil, insecureOption)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
+		var lastErr error
 		for _, ep := range endpoints {
-			r, err := RetrieveImage(ep.ACI, nil, insecure)
+			r, _, err := retrieveImage(ep.ACI, nil, &FetchOptions{Insecure: opts.Insecure, Policy: opts.Policy, Progress: opts.Progress})
 			if err != nil {
+				lastErr = err
 				continue
 			}
-			// FIXME should also attempt to validate the signature
-			return r, nil
+			r, signer, err := verifyImage(r, ep.ASC, app.Name.String(), opts, true)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			return r, signer, nil
+		}
+		if lastErr != nil {
+			return nil, "", fmt.Errorf("failed to find a valid image for %q: %v", imageUri, lastErr)
 		}
-		return nil, fmt.Errorf("failed to find a valid image for %q", imageUri)
+		return nil, "", fmt.Errorf("failed to find a valid image for %q", imageUri)
 
 	default:
-		return nil, fmt.Errorf("%q scheme not supported", u.Scheme)
+		return nil, "", fmt.Errorf("%q scheme not supported", u.Scheme)
+	}
+}
+
+// verifyImage checks the image against the signature at the provided URI, if
+// a keystore is configured. The signature is subject to the fetch policy the
+// same as the image. If the signature can't be retrieved, an error is only
+// returned when it is required. The image is closed on any failure.
+func verifyImage(image tempfile.ReadSeekCloser, signatureUri, name string, opts *FetchOptions, required bool) (tempfile.ReadSeekCloser, string, error) {
+	if opts.Keystore == nil {
+		return image, "", nil
+	}
+
+	opts.report(Progress{Stage: StageVerifying})
+	err := opts.Policy.Check(signatureUri)
+	var signature tempfile.ReadSeekCloser
+	if err == nil {
+		signature, err = RetrieveImage(signatureUri, nil, opts.Insecure)
+	}
+	if err != nil {
+		if required {
+			image.Close()
+			return nil, "", fmt.Errorf("failed to retrieve image signature: %v", err)
+		}
+		return image, "", nil
+	}
+	defer signature.Close()
+
+	// A signature by a key that isn't trusted leaves the image unsigned, unless
+	// one is required. An invalid signature is always an error.
+	signer, err := opts.Keystore.CheckSignature(name, image, signature)
+	if err != nil && (required || err != keystore.ErrNoTrustedKey) {
+		image.Close()
+		return nil, "", err
 	}
+	if _, err := image.Seek(0, 0); err != nil {
+		image.Close()
+		return nil, "", err
+	}
+	return image, signer, nil
 }
 
 // LoadImage is used to retrieve the specified imageUri and load it into the
 // Image Manager, returning the hash, manifest, or an error on failure. In the
 // case of AppC discovery format, it will check to see if the image already
-// exists before retrieving.
-func LoadImage(imageUri string, insecure bool, imageManager backend.ImageManager) (string, *schema.ImageManifest, error) {
+// exists before retrieving, unless a keystore is configured and the existing
+// image has no recorded signer. If the image's signature is verified, the key that
+// signed it is recorded with the Image Manager. Local OCI image layouts and
+// Docker archives, along with Docker images when KeepDockerLayers is set, are
+// imported with each layer as its own image. Concurrent loads of the same
+// image into the Image Manager with the same options share a single fetch.
+func LoadImage(imageUri string, opts *FetchOptions, imageManager backend.ImageManager) (string, *schema.ImageManifest, error) {
+	if err := opts.Policy.Check(imageUri); err != nil {
+		return "", nil, err
+	}
+	uri, err := normalizeURI(imageUri, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	key := newFetchKey(imageManager, uri, opts)
+	return fetches.load(key, opts, func(opts *FetchOptions) (string, *schema.ImageManifest, error) {
+		return loadImage(imageUri, opts, imageManager)
+	})
+}
+
+// loadImage retrieves the image and loads it into the Image Manager.
+func loadImage(imageUri string, opts *FetchOptions, imageManager backend.ImageManager) (string, *schema.ImageManifest, error) {
 	u, err := url.Parse(imageUri)
 	if err != nil {
 		return "", nil, err
@@ -143,15 +255,27 @@ func LoadImage(imageUri string, insecure bool, imageManager backend.ImageManager
 
 	// Currently only supports loading from existing on AppC discovery format
 	switch u.Scheme {
+	case "file":
+		// OCI image layouts and Docker archives are imported a layer at a time,
+		// with the fragment selecting the image if there are several.
+		if filename := localPath(u); ociimage.IsImage(filename) {
+			opts.report(Progress{Stage: StageImporting})
+			return ociimage.Import(filename, u.Fragment, imageManager.CreateImage)
+		}
+	case "docker":
+		if opts.KeepDockerLayers {
+			return loadDockerLayers(imageUri, opts, imageManager)
+		}
 	case "":
 		app, err := discovery.NewAppFromString(imageUri)
 		if err != nil {
 			return "", nil, err
 		}
-
 		version := app.Labels[types.ACIdentifier("version")]
+		// An existing image without a recorded signer was never verified, so it
+		// is retrieved again when signatures are being checked.
 		hash, manifest := imageManager.FindImage(app.Name.String(), version)
-		if hash != "" {
+		if hash != "" && (opts.Keystore == nil || imageManager.ImageSigner(hash) != "") {
 			return hash, manifest, nil
 		}
 	}
@@ -159,12 +283,33 @@ func LoadImage(imageUri string, insecure bool, imageManager backend.ImageManager
 	labels := make(map[types.ACIdentifier]string)
 	labels[types.ACIdentifier("os")] = runtime.GOOS
 	labels[types.ACIdentifier("arch")] = runtime.GOARCH
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
 
-	f, err := RetrieveImage(imageUri, labels, insecure)
+	f, signer, err := retrieveImage(imageUri, labels, opts)
 	if err != nil {
 		return "", nil, err
 	}
 	defer f.Close()
 
-	return imageManager.CreateImage(f)
+	opts.report(Progress{Stage: StageImporting})
+	hash, manifest, err := imageManager.CreateImage(f)
+	if err != nil {
+		return "", nil, err
+	}
+	if signer != "" {
+		if err := imageManager.SetImageSigner(hash, signer); err != nil {
+			return "", nil, err
+		}
+	}
+	return hash, manifest, nil
+}
+
+// localPath returns the path to the file referenced by a file:// URL.
+func localPath(u *url.URL) string {
+	if u.Host != "" {
+		return filepath.Join(u.Host, u.Path)
+	}
+	return u.Path
 }
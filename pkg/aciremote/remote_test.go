@@ -3,10 +3,27 @@
 package aciremote
 
 import (
+	"bytes"
+	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
-
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/apcera/kurma/pkg/backend/mocks"
+	"github.com/apcera/kurma/pkg/keystore"
+	"github.com/appc/spec/discovery"
+	"github.com/appc/spec/schema"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
 )
 
 func TestRetrieveLocalFile(t *testing.T) {
@@ -33,3 +50,301 @@ func TestRetrieveUnsupportedScheme(t *testing.T) {
 		t.Fatalf("Expected error with URI %q, got none", uri)
 	}
 }
+
+func TestRetrieveVerifiesSignature(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "aciremote")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// generate a key and trust it
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	var key bytes.Buffer
+	w, _ := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	entity.Serialize(w)
+	w.Close()
+	ks, err := keystore.New(&keystore.Config{TrustedKeys: []*keystore.ConfigKey{{Key: key.String()}}})
+	if err != nil {
+		t.Fatalf("Error creating keystore: %s", err)
+	}
+	opts := &FetchOptions{Keystore: ks}
+
+	// an unsigned image is retrieved without a signer
+	image := filepath.Join(dir, "image.aci")
+	if err := ioutil.WriteFile(image, []byte("image"), 0644); err != nil {
+		t.Fatalf("Error writing image: %s", err)
+	}
+	r, signer, err := retrieveImage("file://"+image, nil, opts)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving unsigned image; got %s", err)
+	}
+	r.Close()
+	if signer != "" {
+		t.Fatalf("Expected no signer for unsigned image; got %s", signer)
+	}
+
+	// a signed image returns the signer
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, strings.NewReader("image"), nil); err != nil {
+		t.Fatalf("Error signing image: %s", err)
+	}
+	if err := ioutil.WriteFile(image+".asc", sig.Bytes(), 0644); err != nil {
+		t.Fatalf("Error writing signature: %s", err)
+	}
+	r, signer, err = retrieveImage("file://"+image, nil, opts)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving signed image; got %s", err)
+	}
+	b, _ := ioutil.ReadAll(r)
+	r.Close()
+	if signer == "" || string(b) != "image" {
+		t.Fatalf("Expected signer and image contents; got %q and %q", signer, string(b))
+	}
+
+	// a tampered image fails
+	if err := ioutil.WriteFile(image, []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Error writing image: %s", err)
+	}
+	if _, _, err := retrieveImage("file://"+image, nil, opts); err == nil {
+		t.Fatalf("Expected error retrieving tampered image, got none")
+	}
+}
+
+func TestRetrieveReportsProgress(t *testing.T) {
+	data := strings.Repeat("x", 64*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	var updates []Progress
+	opts := &FetchOptions{Progress: func(p Progress) { updates = append(updates, p) }}
+	r, _, err := retrieveImage(server.URL+"/image.aci", nil, opts)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving the image; got %s", err)
+	}
+	r.Close()
+
+	if len(updates) < 2 {
+		t.Fatalf("Expected at least 2 progress updates; got %d", len(updates))
+	}
+	last := updates[len(updates)-1]
+	if last.Stage != StageDownloading || last.Bytes != int64(len(data)) || last.Total != int64(len(data)) {
+		t.Fatalf("Expected the final update to cover the whole download; got %+v", last)
+	}
+}
+
+func TestLoadImageCoalescesFetches(t *testing.T) {
+	var requests int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(started)
+		}
+		<-release
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	var creates int32
+	imageManager := &mocks.ImageManager{
+		CreateImageFunc: func(r io.Reader) (string, *schema.ImageManifest, error) {
+			atomic.AddInt32(&creates, 1)
+			ioutil.ReadAll(r)
+			return "sha512-image", schema.BlankImageManifest(), nil
+		},
+	}
+
+	// the same image, referred to with a differently cased scheme
+	uris := []string{server.URL + "/image.aci", strings.Replace(server.URL, "http://", "HTTP://", 1) + "/image.aci"}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			opts := &FetchOptions{Progress: func(Progress) {}}
+			_, _, errs[i] = LoadImage(uris[i%len(uris)], opts, imageManager)
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+
+	// wait for every load to be waiting on the first fetch
+	for waiting := 0; waiting < len(errs); {
+		fetches.lock.Lock()
+		waiting = 0
+		for _, f := range fetches.fetches {
+			f.progressLock.Lock()
+			waiting += len(f.progress)
+			f.progressLock.Unlock()
+		}
+		fetches.lock.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Expected no error loading the image; got %s", err)
+		}
+	}
+	if requests != 1 || creates != 1 {
+		t.Fatalf("Expected the image to be fetched once; got %d requests and %d creates", requests, creates)
+	}
+}
+
+func TestFetchKeyIncludesOptions(t *testing.T) {
+	imageManager := &mocks.ImageManager{}
+	ks, err := keystore.New(&keystore.Config{})
+	if err != nil {
+		t.Fatalf("Error creating keystore: %s", err)
+	}
+	policy := &FetchPolicy{Deny: []string{"example.com"}}
+
+	key := newFetchKey(imageManager, "example.com/app", &FetchOptions{})
+	if key != newFetchKey(imageManager, "example.com/app", &FetchOptions{Progress: func(Progress) {}}) {
+		t.Fatalf("Expected loads differing only in their progress to share a fetch")
+	}
+	for _, opts := range []*FetchOptions{
+		{Insecure: true},
+		{Keystore: ks},
+		{Policy: policy},
+	} {
+		if key == newFetchKey(imageManager, "example.com/app", opts) {
+			t.Fatalf("Expected loads with options %+v not to share a fetch", opts)
+		}
+	}
+}
+
+func TestDiscoveredEndpointCheckedByPolicy(t *testing.T) {
+	var fetched int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ac-discovery") == "" {
+			atomic.AddInt32(&fetched, 1)
+			w.Write([]byte("image"))
+			return
+		}
+		w.Write([]byte(`<html><head><meta name="ac-discovery" content="example.com/app http://denied.example.com/app.{ext}"></head></html>`))
+	}))
+	defer server.Close()
+
+	defer routeToServer(server)()
+
+	opts := &FetchOptions{Insecure: true, Policy: &FetchPolicy{Deny: []string{"denied.example.com"}}}
+	_, _, err := retrieveImage("example.com/app", nil, opts)
+	if err == nil || !strings.Contains(err.Error(), "fetch policy") {
+		t.Fatalf("Expected the discovered endpoint to be denied by the fetch policy; got %v", err)
+	}
+	if fetched != 0 {
+		t.Fatalf("Expected the denied endpoint not to be fetched; got %d requests", fetched)
+	}
+}
+
+func TestSignatureCheckedByPolicy(t *testing.T) {
+	var signatures int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ac-discovery") != "" {
+			w.Write([]byte(`<html><head><meta name="ac-discovery" content="example.com/app http://example.com/app.{ext}"></head></html>`))
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".asc") {
+			atomic.AddInt32(&signatures, 1)
+		}
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	defer routeToServer(server)()
+
+	ks, err := keystore.New(&keystore.Config{})
+	if err != nil {
+		t.Fatalf("Error creating keystore: %s", err)
+	}
+	opts := &FetchOptions{Insecure: true, Keystore: ks, Policy: &FetchPolicy{Deny: []string{"example.com/*.asc"}}}
+
+	// a signature is required for discovered images
+	_, _, err = retrieveImage("example.com/app", nil, opts)
+	if err == nil || !strings.Contains(err.Error(), "fetch policy") {
+		t.Fatalf("Expected the signature to be denied by the fetch policy; got %v", err)
+	}
+
+	// other images are left unsigned
+	r, signer, err := retrieveImage("http://example.com/app.aci", nil, opts)
+	if err != nil {
+		t.Fatalf("Expected no error retrieving the image; got %s", err)
+	}
+	r.Close()
+	if signer != "" {
+		t.Fatalf("Expected the image to be unsigned; got signer %q", signer)
+	}
+
+	if signatures != 0 {
+		t.Fatalf("Expected the denied signature not to be fetched; got %d requests", signatures)
+	}
+}
+
+func TestLoadImageVerifiesExistingUnsignedImage(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	defer routeToServer(server)()
+
+	ks, err := keystore.New(&keystore.Config{})
+	if err != nil {
+		t.Fatalf("Error creating keystore: %s", err)
+	}
+	signer := ""
+	imageManager := &mocks.ImageManager{
+		FindImageFunc: func(name, version string) (string, *schema.ImageManifest) {
+			return "sha512-existing", schema.BlankImageManifest()
+		},
+		ImageSignerFunc: func(hash string) string { return signer },
+	}
+	opts := &FetchOptions{Insecure: true, Keystore: ks, Progress: func(Progress) {}}
+
+	// an existing image with no recorded signer is retrieved again to verify it
+	if _, _, err := LoadImage("example.com/app:1.0", opts, imageManager); err == nil {
+		t.Fatalf("Expected error loading an image that can't be verified, got none")
+	}
+	if requests == 0 {
+		t.Fatalf("Expected the unsigned image to be discovered again")
+	}
+
+	// an existing image with a recorded signer is used as is
+	atomic.StoreInt32(&requests, 0)
+	signer = "fingerprint"
+	hash, _, err := LoadImage("example.com/app:1.0", opts, imageManager)
+	if err != nil {
+		t.Fatalf("Expected no error loading the existing image; got %s", err)
+	}
+	if hash != "sha512-existing" || requests != 0 {
+		t.Fatalf("Expected the existing image without any requests; got %q and %d requests", hash, requests)
+	}
+}
+
+// routeToServer sends every request made while discovering and retrieving
+// images to the test server, returning a function that restores the original
+// transports.
+func routeToServer(server *httptest.Server) func() {
+	transport := &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.Dial(network, server.Listener.Addr().String())
+		},
+	}
+	origDiscovery, origClient := discovery.Client.Transport, Client.Transport
+	discovery.Client.Transport, Client.Transport = transport, transport
+	return func() { discovery.Client.Transport, Client.Transport = origDiscovery, origClient }
+}
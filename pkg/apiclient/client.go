@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
@@ -23,13 +24,39 @@ type Client interface {
 	CreatePod(req *PodCreateRequest) (*Pod, error)
 	ListPods() ([]*Pod, error)
 	GetPod(uuid string) (*Pod, error)
+	GetPodStatus(uuid string) (*PodStatus, error)
+	WaitPod(uuid string) (*PodStatus, error)
 	DestroyPod(uuid string) error
+	CheckPodNetworks(uuid string) error
+	AttachPodNetwork(uuid, network string) (*Pod, error)
+	DetachPodNetwork(uuid, network string) (*Pod, error)
 	EnterContainer(uuid string, appName string, app *schema.RunApp) (net.Conn, error)
+	AttachContainer(uuid string, appName string) (net.Conn, error)
+	ContainerLogs(req *ContainerLogsRequest) (io.ReadCloser, error)
 
 	CreateImage(reader io.Reader) (*Image, error)
 	ListImages() ([]*Image, error)
 	GetImage(hash string) (*Image, error)
 	DeleteImage(hash string) error
+	PruneImages(dryRun bool) ([]string, error)
+	TagImage(hash, name, tag string) error
+	UntagImage(name, tag string) error
+	FetchImage(req *ImageFetchRequest) (string, error)
+	ImageFetchProgress(id string) (io.ReadCloser, error)
+	ExportImage(req *ImageExportRequest) ([]string, error)
+	ImageExportData(hash string) (io.ReadCloser, error)
+	ListImageFiles(req *ImageFilesRequest) ([]*ImageFile, error)
+	ReadImageFile(hash, path string) (io.ReadCloser, error)
+
+	AddTrustedKey(prefix, key string) ([]*TrustedKey, error)
+	ListTrustedKeys() ([]*TrustedKey, error)
+	RemoveTrustedKey(fingerprint string) error
+
+	ListNetworks() ([]*Network, error)
+	GetNetwork(name string) (*Network, error)
+	AddNetwork(config []byte) (*Network, error)
+	UpdateNetwork(config []byte) (*Network, error)
+	RemoveNetwork(name string) error
 }
 
 type client struct {
@@ -132,10 +159,51 @@ func (c *client) GetPod(uuid string) (*Pod, error) {
 	return resp.Pod, nil
 }
 
+func (c *client) GetPodStatus(uuid string) (*PodStatus, error) {
+	var resp *PodStatusResponse
+	err := c.execute("Pods.Status", uuid, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
+func (c *client) WaitPod(uuid string) (*PodStatus, error) {
+	var resp *PodStatusResponse
+	err := c.execute("Pods.Wait", uuid, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
 func (c *client) DestroyPod(uuid string) error {
 	return c.execute("Pods.Destroy", uuid, nil)
 }
 
+func (c *client) CheckPodNetworks(uuid string) error {
+	// decode the response so that the failed checks are returned
+	return c.execute("Pods.CheckNetworks", uuid, &None{})
+}
+
+func (c *client) AttachPodNetwork(uuid, network string) (*Pod, error) {
+	var resp *PodResponse
+	err := c.execute("Pods.AttachNetwork", &PodNetworkRequest{UUID: uuid, Network: network}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Pod, nil
+}
+
+func (c *client) DetachPodNetwork(uuid, network string) (*Pod, error) {
+	var resp *PodResponse
+	err := c.execute("Pods.DetachNetwork", &PodNetworkRequest{UUID: uuid, Network: network}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Pod, nil
+}
+
 func (c *client) EnterContainer(uuid string, appName string, app *schema.RunApp) (net.Conn, error) {
 	u, err := url.Parse(c.baseUrl)
 	if err != nil {
@@ -172,6 +240,45 @@ func (c *client) EnterContainer(uuid string, appName string, app *schema.RunApp)
 	return wsc, nil
 }
 
+func (c *client) AttachContainer(uuid string, appName string) (net.Conn, error) {
+	u, err := url.Parse(c.baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/containers/attach"
+
+	// set headers
+	headers := http.Header{
+		"Origin": {u.String()},
+	}
+	u.Scheme = "ws"
+
+	// dial the connection
+	conn, err := c.dialer()
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the websocket
+	ws, _, err := websocket.NewClient(conn, u, headers, 1024, 1024)
+	if err != nil {
+		return nil, err
+	}
+
+	ar := ContainerAttachRequest{UUID: uuid, AppName: appName}
+	if err := ws.WriteJSON(ar); err != nil {
+		return nil, err
+	}
+
+	// create the websocket connection
+	wsc := wsconn.NewWebsocketConnection(ws)
+	return wsc, nil
+}
+
+func (c *client) ContainerLogs(lr *ContainerLogsRequest) (io.ReadCloser, error) {
+	return c.stream("/containers/logs", encodeLogsRequest(lr))
+}
+
 func (c *client) CreateImage(reader io.Reader) (*Image, error) {
 	u, err := url.Parse(c.baseUrl)
 	if err != nil {
@@ -223,6 +330,165 @@ func (c *client) DeleteImage(hash string) error {
 	return c.execute("Images.Delete", hash, nil)
 }
 
+func (c *client) PruneImages(dryRun bool) ([]string, error) {
+	var resp *ImagePruneResponse
+	err := c.execute("Images.Prune", &ImagePruneRequest{DryRun: dryRun}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Images, nil
+}
+
+func (c *client) TagImage(hash, name, tag string) error {
+	// decode the response so that errors, such as an invalid name, are returned
+	return c.execute("Images.Tag", &ImageTagRequest{Hash: hash, Name: name, Tag: tag}, &None{})
+}
+
+func (c *client) UntagImage(name, tag string) error {
+	return c.execute("Images.Untag", &ImageTagRequest{Name: name, Tag: tag}, &None{})
+}
+
+func (c *client) FetchImage(req *ImageFetchRequest) (string, error) {
+	var resp *ImageFetchResponse
+	err := c.execute("Images.Fetch", req, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// ImageFetchProgress streams the progress of a fetch started with FetchImage
+// as a sequence of JSON encoded ImageFetchProgress updates. The stream ends
+// after the update with the resulting image or error.
+func (c *client) ImageFetchProgress(id string) (io.ReadCloser, error) {
+	return c.stream("/images/fetch", url.Values{"id": []string{id}})
+}
+
+// ExportImage returns the hashes of the images to export, with dependencies
+// ahead of the images that depend on them. Each can then be retrieved with
+// ImageExportData.
+func (c *client) ExportImage(req *ImageExportRequest) ([]string, error) {
+	var resp *ImageExportResponse
+	err := c.execute("Images.Export", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Images, nil
+}
+
+// ImageExportData streams the image as an ACI. The same image is always
+// exported identically.
+func (c *client) ImageExportData(hash string) (io.ReadCloser, error) {
+	return c.stream("/images/export", url.Values{"hash": []string{hash}})
+}
+
+func (c *client) ListImageFiles(req *ImageFilesRequest) ([]*ImageFile, error) {
+	var resp *ImageFilesResponse
+	err := c.execute("Images.Files", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Files, nil
+}
+
+// ReadImageFile streams the contents of a file within the root filesystem of
+// the image.
+func (c *client) ReadImageFile(hash, path string) (io.ReadCloser, error) {
+	return c.stream("/images/files", url.Values{"hash": []string{hash}, "path": []string{path}})
+}
+
+func (c *client) AddTrustedKey(prefix, key string) ([]*TrustedKey, error) {
+	var resp *TrustListResponse
+	err := c.execute("Trust.Add", &TrustAddRequest{Prefix: prefix, Key: key}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+func (c *client) ListTrustedKeys() ([]*TrustedKey, error) {
+	var resp *TrustListResponse
+	err := c.execute("Trust.List", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+func (c *client) RemoveTrustedKey(fingerprint string) error {
+	return c.execute("Trust.Remove", fingerprint, nil)
+}
+
+func (c *client) ListNetworks() ([]*Network, error) {
+	var resp *NetworkListResponse
+	err := c.execute("Networks.List", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Networks, nil
+}
+
+func (c *client) GetNetwork(name string) (*Network, error) {
+	var resp *NetworkResponse
+	err := c.execute("Networks.Get", name, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Network, nil
+}
+
+// AddNetwork configures a new network from its JSON configuration, in the same
+// form as the podNetworks entries in the daemon's configuration.
+func (c *client) AddNetwork(config []byte) (*Network, error) {
+	var resp *NetworkResponse
+	err := c.execute("Networks.Add", &NetworkConfigRequest{Config: config}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Network, nil
+}
+
+// UpdateNetwork replaces the configuration of the network with the same name.
+func (c *client) UpdateNetwork(config []byte) (*Network, error) {
+	var resp *NetworkResponse
+	err := c.execute("Networks.Update", &NetworkConfigRequest{Config: config}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Network, nil
+}
+
+func (c *client) RemoveNetwork(name string) error {
+	return c.execute("Networks.Remove", name, &None{})
+}
+
+// stream makes a GET request to the path and returns the response body.
+func (c *client) stream(path string, query url.Values) (io.ReadCloser, error) {
+	u, err := url.Parse(c.baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("request failed with non-200 status: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return resp.Body, nil
+}
+
 func (c *client) execute(cmd string, args, reply interface{}) error {
 	buf, err := json2.EncodeClientRequest(cmd, args)
 	if err != nil {
@@ -0,0 +1,69 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package apiclient
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// encodeLogsRequest converts the logs request into the query parameters used by
+// the logs endpoint.
+func encodeLogsRequest(req *ContainerLogsRequest) url.Values {
+	v := url.Values{}
+	v.Set("uuid", req.UUID)
+	if req.AppName != "" {
+		v.Set("app", req.AppName)
+	}
+	if req.Stager {
+		v.Set("stager", "true")
+	}
+	if req.Follow {
+		v.Set("follow", "true")
+	}
+	if req.Tail > 0 {
+		v.Set("tail", strconv.Itoa(req.Tail))
+	}
+	if !req.Since.IsZero() {
+		v.Set("since", req.Since.Format(time.RFC3339Nano))
+	}
+	if req.Timestamps {
+		v.Set("timestamps", "true")
+	}
+	return v
+}
+
+// DecodeLogsRequest parses the query parameters of a logs request back into a
+// ContainerLogsRequest.
+func DecodeLogsRequest(v url.Values) (*ContainerLogsRequest, error) {
+	req := &ContainerLogsRequest{
+		UUID:       v.Get("uuid"),
+		AppName:    v.Get("app"),
+		Stager:     v.Get("stager") == "true",
+		Follow:     v.Get("follow") == "true",
+		Timestamps: v.Get("timestamps") == "true",
+	}
+	if req.UUID == "" {
+		return nil, fmt.Errorf("no pod UUID was specified")
+	}
+	if req.AppName == "" && !req.Stager {
+		return nil, fmt.Errorf("either an application name or the stager must be specified")
+	}
+	if s := v.Get("tail"); s != "" {
+		tail, err := strconv.Atoi(s)
+		if err != nil || tail < 0 {
+			return nil, fmt.Errorf("invalid tail value %q", s)
+		}
+		req.Tail = tail
+	}
+	if s := v.Get("since"); s != "" {
+		since, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since value %q: %v", s, err)
+		}
+		req.Since = since
+	}
+	return req, nil
+}
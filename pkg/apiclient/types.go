@@ -3,6 +3,11 @@
 package apiclient
 
 import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/apcera/kurma/pkg/aciremote"
 	"github.com/appc/spec/schema"
 	"github.com/appc/spec/schema/types"
 
@@ -11,24 +16,45 @@ import (
 )
 
 type Pod struct {
-	UUID     string              `json:"uuid"`
-	Name     string              `json:"name"`
-	Pod      *schema.PodManifest `json:"pod"`
-	Networks []*ntypes.IPResult  `json:"networks"`
-	State    State               `json:"state"`
+	UUID         string                `json:"uuid"`
+	Name         string                `json:"name"`
+	Pod          *schema.PodManifest   `json:"pod"`
+	Networks     []*ntypes.IPResult    `json:"networks"`
+	PortMappings []*ntypes.PortMapping `json:"portMappings,omitempty"`
+	State        State                 `json:"state"`
+	Error        string                `json:"error,omitempty"`
+}
+
+type AppStatus struct {
+	Pid          int    `json:"pid,omitempty"`
+	Exited       bool   `json:"exited"`
+	ExitCode     int    `json:"exitCode,omitempty"`
+	ExitReason   string `json:"exitReason,omitempty"`
+	RestartCount int    `json:"restartCount,omitempty"`
+}
+
+type PodStatus struct {
+	UUID     string                `json:"uuid"`
+	State    State                 `json:"state"`
+	Apps     map[string]*AppStatus `json:"apps"`
+	ExitCode *int                  `json:"exitCode,omitempty"`
 }
 
 type Image struct {
-	Hash     string                `json:"hash"`
-	Manifest *schema.ImageManifest `json:"manifest"`
-	Size     int64                 `json:"size"`
+	Hash           string                `json:"hash"`
+	Manifest       *schema.ImageManifest `json:"manifest"`
+	Size           int64                 `json:"size"`
+	CompressedSize int64                 `json:"compressedSize"`
+	TotalSize      int64                 `json:"totalSize"`
+	Tags           []string              `json:"tags,omitempty"`
 }
 
 type PodCreateRequest struct {
-	Name            string              `json:"name"`
-	Pod             *schema.PodManifest `json:"pod"`
-	Networks        []string            `json:"networks,omitempty"`
-	StagerImageHash string              `json:"stagerImageHash,omitempty"`
+	Name            string                `json:"name"`
+	Pod             *schema.PodManifest   `json:"pod"`
+	Networks        []string              `json:"networks,omitempty"`
+	PortMappings    []*ntypes.PortMapping `json:"portMappings,omitempty"`
+	StagerImageHash string                `json:"stagerImageHash,omitempty"`
 }
 
 type PodListResponse struct {
@@ -39,12 +65,36 @@ type PodResponse struct {
 	Pod *Pod `json:"pod"`
 }
 
+type PodStatusResponse struct {
+	Status *PodStatus `json:"status"`
+}
+
+type PodNetworkRequest struct {
+	UUID    string `json:"uuid"`
+	Network string `json:"network"`
+}
+
 type ContainerEnterRequest struct {
 	UUID    string         `json:"uuid"`
 	AppName string         `json:"appName"`
 	App     kschema.RunApp `json:"app"`
 }
 
+type ContainerAttachRequest struct {
+	UUID    string `json:"uuid"`
+	AppName string `json:"appName"`
+}
+
+type ContainerLogsRequest struct {
+	UUID       string    `json:"uuid"`
+	AppName    string    `json:"appName,omitempty"`
+	Stager     bool      `json:"stager,omitempty"`
+	Follow     bool      `json:"follow,omitempty"`
+	Tail       int       `json:"tail,omitempty"`
+	Since      time.Time `json:"since,omitempty"`
+	Timestamps bool      `json:"timestamps,omitempty"`
+}
+
 type ImageListResponse struct {
 	Images []*Image `json:"images"`
 }
@@ -53,6 +103,120 @@ type ImageResponse struct {
 	Image *Image `json:"image"`
 }
 
+type ImagePruneRequest struct {
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+type ImagePruneResponse struct {
+	Images []string `json:"images"`
+}
+
+type ImageFetchRequest struct {
+	URI    string            `json:"uri"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// KeepDockerLayers overrides the host's default for whether the layers of
+	// a Docker image are kept as separate images.
+	KeepDockerLayers *bool `json:"keepDockerLayers,omitempty"`
+}
+
+type ImageFetchResponse struct {
+	ID string `json:"id"`
+}
+
+// ImageFetchProgress is an update on an image being fetched by the host. The
+// final update has either the Image or the Error set.
+type ImageFetchProgress struct {
+	Stage  string `json:"stage,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	Total  int64  `json:"total,omitempty"`
+	Layers int    `json:"layers,omitempty"`
+	Image  *Image `json:"image,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImageTagRequest struct {
+	Hash string `json:"hash,omitempty"`
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+}
+
+type ImageExportRequest struct {
+	Hash         string `json:"hash"`
+	Dependencies bool   `json:"dependencies,omitempty"`
+}
+
+// ImageExportResponse lists the images to be exported, with the dependencies
+// of each image ahead of it so they can be imported in order.
+type ImageExportResponse struct {
+	Images []string `json:"images"`
+}
+
+type ImageFilesRequest struct {
+	Hash string `json:"hash"`
+	Path string `json:"path"`
+}
+
+type ImageFilesResponse struct {
+	Files []*ImageFile `json:"files"`
+}
+
+// ImageFile describes a file within the root filesystem of an image.
+type ImageFile struct {
+	Path    string      `json:"path"`
+	Mode    os.FileMode `json:"mode"`
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"modTime"`
+	Target  string      `json:"target,omitempty"`
+}
+
+type TrustedKey struct {
+	Prefix      string   `json:"prefix"`
+	Fingerprint string   `json:"fingerprint"`
+	Identities  []string `json:"identities,omitempty"`
+	System      bool     `json:"system,omitempty"`
+}
+
+type TrustAddRequest struct {
+	Prefix string `json:"prefix"`
+	Key    string `json:"key"`
+}
+
+type TrustListResponse struct {
+	Keys []*TrustedKey `json:"keys"`
+}
+
+// Network is a network configured on the host, along with the pods it has
+// been provisioned on.
+type Network struct {
+	Name    string          `json:"name"`
+	ACI     string          `json:"aci,omitempty"`
+	Image   string          `json:"image"`
+	Default bool            `json:"default,omitempty"`
+	Config  json.RawMessage `json:"config"`
+	Pods    []*NetworkPod   `json:"pods,omitempty"`
+}
+
+// NetworkPod is a pod a network has been provisioned on, and the name of the
+// network's interface within the pod.
+type NetworkPod struct {
+	UUID      string `json:"uuid"`
+	Name      string `json:"name,omitempty"`
+	Interface string `json:"interface"`
+}
+
+type NetworkConfigRequest struct {
+	Config json.RawMessage `json:"config"`
+}
+
+type NetworkResponse struct {
+	Network *Network `json:"network"`
+}
+
+type NetworkListResponse struct {
+	Networks []*Network `json:"networks"`
+}
+
 type None struct{}
 
 type State string
@@ -75,4 +239,8 @@ type HostInfo struct {
 	ACVersion     types.SemVer `json:"ac_version"`
 	KurmaVersion  types.SemVer `json:"kurma_version"`
 	KernelVersion string       `json:"kernel_version"`
+
+	// FetchPolicy is the host's policy on where images may be retrieved from,
+	// which clients should apply when retrieving images for it.
+	FetchPolicy *aciremote.FetchPolicy `json:"fetch_policy,omitempty"`
 }
@@ -0,0 +1,45 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package apiproxy
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/util/wsconn"
+)
+
+func (s *Server) containerAttachRequest(w http.ResponseWriter, req *http.Request) {
+	iws, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		s.log.Errorf("Failed to upgrade tunnel connection: %v", err)
+		http.Error(w, "Failed to setup request", 500)
+		return
+	}
+
+	// parse the inbound request
+	var attachRequest *apiclient.ContainerAttachRequest
+	if err := iws.ReadJSON(&attachRequest); err != nil {
+		s.log.Errorf("Failed to unmarshal attach request: %v", err)
+		http.Error(w, "Failed to upgrade socket", 500)
+		return
+	}
+
+	// call out
+	owsc, err := s.client.AttachContainer(attachRequest.UUID, attachRequest.AppName)
+	if err != nil {
+		s.log.Errorf("Failed to call to kurma daemon: %v", err)
+		http.Error(w, "Failed to upgrade socket", 500)
+		return
+	}
+
+	// create the websocket connection
+	iwsc := wsconn.NewWebsocketConnection(iws)
+	defer iwsc.Close()
+
+	go io.Copy(owsc, iwsc)
+	io.Copy(iwsc, owsc)
+	owsc.Close()
+	iwsc.Close()
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package apiproxy
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+)
+
+func (s *Server) containerLogsRequest(w http.ResponseWriter, req *http.Request) {
+	logsRequest, err := apiclient.DecodeLogsRequest(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	// call out
+	body, err := s.client.ContainerLogs(logsRequest)
+	if err != nil {
+		s.log.Errorf("Failed to call to kurma daemon: %v", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer body.Close()
+
+	if err := streamBody(w, body); err != nil {
+		s.log.Errorf("Failed reading logs from kurma daemon: %v", err)
+	}
+}
+
+// streamBody copies the upstream body to the client, flushing after each read
+// so that it is streamed as it arrives. The upstream body is closed if the
+// client goes away.
+func streamBody(w http.ResponseWriter, body io.ReadCloser) error {
+	if cn, ok := w.(http.CloseNotifier); ok {
+		done := make(chan struct{})
+		defer close(done)
+		closed := cn.CloseNotify()
+		go func() {
+			select {
+			case <-closed:
+				body.Close()
+			case <-done:
+			}
+		}()
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return nil
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -5,6 +5,7 @@ package apiproxy
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/apcera/kurma/pkg/apiclient"
@@ -55,3 +56,93 @@ func (s *ImageService) Delete(r *http.Request, hash *string, resp *apiclient.Ima
 	}
 	return s.server.client.DeleteImage(*hash)
 }
+
+func (s *ImageService) Prune(r *http.Request, req *apiclient.ImagePruneRequest, resp *apiclient.ImagePruneResponse) error {
+	images, err := s.server.client.PruneImages(req.DryRun)
+	if err != nil {
+		return err
+	}
+	resp.Images = images
+	return nil
+}
+
+func (s *ImageService) Tag(r *http.Request, req *apiclient.ImageTagRequest, resp *apiclient.None) error {
+	return s.server.client.TagImage(req.Hash, req.Name, req.Tag)
+}
+
+func (s *ImageService) Untag(r *http.Request, req *apiclient.ImageTagRequest, resp *apiclient.None) error {
+	return s.server.client.UntagImage(req.Name, req.Tag)
+}
+
+func (s *ImageService) Fetch(r *http.Request, req *apiclient.ImageFetchRequest, resp *apiclient.ImageFetchResponse) error {
+	if err := validateFetchURI(req.URI); err != nil {
+		return err
+	}
+	id, err := s.server.client.FetchImage(req)
+	if err != nil {
+		return err
+	}
+	resp.ID = id
+	return nil
+}
+
+func (s *Server) imageFetchRequest(w http.ResponseWriter, req *http.Request) {
+	body, err := s.client.ImageFetchProgress(req.URL.Query().Get("id"))
+	if err != nil {
+		s.log.Errorf("Failed to call to kurma daemon: %v", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer body.Close()
+
+	if err := streamBody(w, body); err != nil {
+		s.log.Errorf("Failed reading fetch progress from kurma daemon: %v", err)
+	}
+}
+
+func (s *ImageService) Export(r *http.Request, req *apiclient.ImageExportRequest, resp *apiclient.ImageExportResponse) error {
+	images, err := s.server.client.ExportImage(req)
+	if err != nil {
+		return err
+	}
+	resp.Images = images
+	return nil
+}
+
+func (s *Server) imageExportRequest(w http.ResponseWriter, req *http.Request) {
+	body, err := s.client.ImageExportData(req.URL.Query().Get("hash"))
+	if err != nil {
+		s.log.Errorf("Failed to call to kurma daemon: %v", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(w, body); err != nil {
+		s.log.Errorf("Failed reading image export from kurma daemon: %v", err)
+	}
+}
+
+func (s *ImageService) Files(r *http.Request, req *apiclient.ImageFilesRequest, resp *apiclient.ImageFilesResponse) error {
+	files, err := s.server.client.ListImageFiles(req)
+	if err != nil {
+		return err
+	}
+	resp.Files = files
+	return nil
+}
+
+func (s *Server) imageFilesRequest(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	body, err := s.client.ReadImageFile(query.Get("hash"), query.Get("path"))
+	if err != nil {
+		s.log.Errorf("Failed to call to kurma daemon: %v", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(w, body); err != nil {
+		s.log.Errorf("Failed reading image file from kurma daemon: %v", err)
+	}
+}
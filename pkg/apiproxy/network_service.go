@@ -0,0 +1,37 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package apiproxy
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+)
+
+// NetworkService only exposes the configured networks. Adding, updating, and
+// removing networks is restricted to the host's local socket.
+type NetworkService struct {
+	server *Server
+}
+
+func (s *NetworkService) List(r *http.Request, args *apiclient.None, resp *apiclient.NetworkListResponse) error {
+	networks, err := s.server.client.ListNetworks()
+	if err != nil {
+		return err
+	}
+	resp.Networks = networks
+	return nil
+}
+
+func (s *NetworkService) Get(r *http.Request, name *string, resp *apiclient.NetworkResponse) error {
+	if name == nil {
+		return fmt.Errorf("no network name was specified")
+	}
+	network, err := s.server.client.GetNetwork(*name)
+	if err != nil {
+		return err
+	}
+	resp.Network = network
+	return nil
+}
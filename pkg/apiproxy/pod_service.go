@@ -15,7 +15,7 @@ type PodService struct {
 
 func (s *PodService) Create(r *http.Request, req *apiclient.PodCreateRequest, resp *apiclient.PodResponse) error {
 	// locally validate the manifest to gate remote vs local container functionality
-	if err := validatePodManifest(req.Pod); err != nil {
+	if err := s.server.validatePodManifest(req.Pod); err != nil {
 		return fmt.Errorf("image manifest is not valid: %v", err)
 	}
 
@@ -48,9 +48,58 @@ func (s *PodService) Get(r *http.Request, uuid *string, resp *apiclient.PodRespo
 	return nil
 }
 
+func (s *PodService) Status(r *http.Request, uuid *string, resp *apiclient.PodStatusResponse) error {
+	if uuid == nil {
+		return fmt.Errorf("no pod UUID was specified")
+	}
+	status, err := s.server.client.GetPodStatus(*uuid)
+	if err != nil {
+		return err
+	}
+	resp.Status = status
+	return nil
+}
+
+func (s *PodService) Wait(r *http.Request, uuid *string, resp *apiclient.PodStatusResponse) error {
+	if uuid == nil {
+		return fmt.Errorf("no pod UUID was specified")
+	}
+	status, err := s.server.client.WaitPod(*uuid)
+	if err != nil {
+		return err
+	}
+	resp.Status = status
+	return nil
+}
+
 func (s *PodService) Destroy(r *http.Request, uuid *string, ret *apiclient.None) error {
 	if uuid == nil {
 		return fmt.Errorf("no container UUID was specified")
 	}
 	return s.server.client.DestroyPod(*uuid)
 }
+
+func (s *PodService) CheckNetworks(r *http.Request, uuid *string, ret *apiclient.None) error {
+	if uuid == nil {
+		return fmt.Errorf("no pod UUID was specified")
+	}
+	return s.server.client.CheckPodNetworks(*uuid)
+}
+
+func (s *PodService) AttachNetwork(r *http.Request, req *apiclient.PodNetworkRequest, resp *apiclient.PodResponse) error {
+	pod, err := s.server.client.AttachPodNetwork(req.UUID, req.Network)
+	if err != nil {
+		return err
+	}
+	resp.Pod = pod
+	return nil
+}
+
+func (s *PodService) DetachNetwork(r *http.Request, req *apiclient.PodNetworkRequest, resp *apiclient.PodResponse) error {
+	pod, err := s.server.client.DetachPodNetwork(req.UUID, req.Network)
+	if err != nil {
+		return err
+	}
+	resp.Pod = pod
+	return nil
+}
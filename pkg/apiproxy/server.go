@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/kurma/pkg/capabilities"
 	"github.com/apcera/logray"
 	"github.com/gorilla/mux"
 	rpc "github.com/gorilla/rpc/v2"
@@ -17,6 +18,11 @@ import (
 // instantiating a new api.Server.
 type Options struct {
 	BindAddress string
+
+	// RetainableCapabilities is the set of capabilities that the apps in pods
+	// launched remotely are allowed to retain. It defaults to the capabilities
+	// apps are given when they don't specify a capabilities isolator.
+	RetainableCapabilities []string
 }
 
 // Server represents the process that acts as a daemon to receive container
@@ -33,6 +39,9 @@ func New(options *Options) *Server {
 	if options.BindAddress == "" {
 		options.BindAddress = ":12312"
 	}
+	if options.RetainableCapabilities == nil {
+		options.RetainableCapabilities = capabilities.DefaultCapabilities
+	}
 
 	s := &Server{
 		log:     logray.New(),
@@ -59,12 +68,19 @@ func (s *Server) Start() error {
 	svr.RegisterCodec(json2.NewCodec(), "application/json")
 	svr.RegisterService(&PodService{server: s}, "Pods")
 	svr.RegisterService(&ImageService{server: s}, "Images")
+	svr.RegisterService(&TrustService{server: s}, "Trust")
+	svr.RegisterService(&NetworkService{server: s}, "Networks")
 
 	router := mux.NewRouter()
 	router.Handle("/rpc", svr)
 	router.HandleFunc("/info", s.infoRequest).Methods("GET")
+	router.HandleFunc("/containers/attach", s.containerAttachRequest).Methods("GET")
 	router.HandleFunc("/containers/enter", s.containerEnterRequest).Methods("GET")
+	router.HandleFunc("/containers/logs", s.containerLogsRequest).Methods("GET")
 	router.HandleFunc("/images/create", s.imageCreateRequest).Methods("POST")
+	router.HandleFunc("/images/fetch", s.imageFetchRequest).Methods("GET")
+	router.HandleFunc("/images/export", s.imageExportRequest).Methods("GET")
+	router.HandleFunc("/images/files", s.imageFilesRequest).Methods("GET")
 
 	s.log.Debug("Server is ready")
 	go func() {
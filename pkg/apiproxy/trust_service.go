@@ -0,0 +1,24 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package apiproxy
+
+import (
+	"net/http"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+)
+
+// TrustService only exposes the list of trusted keys. Adding and removing keys
+// is restricted to the host's local socket.
+type TrustService struct {
+	server *Server
+}
+
+func (s *TrustService) List(r *http.Request, args *apiclient.None, resp *apiclient.TrustListResponse) error {
+	keys, err := s.server.client.ListTrustedKeys()
+	if err != nil {
+		return err
+	}
+	resp.Keys = keys
+	return nil
+}
@@ -4,12 +4,15 @@ package apiproxy
 
 import (
 	"fmt"
+	"net/url"
 
+	"github.com/apcera/kurma/pkg/capabilities"
 	kschema "github.com/apcera/kurma/schema"
 	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
 )
 
-func validatePodManifest(manifest *schema.PodManifest) error {
+func (s *Server) validatePodManifest(manifest *schema.PodManifest) error {
 	if len(manifest.Apps) == 0 {
 		return fmt.Errorf("the imageManifest must specify an App")
 	}
@@ -28,9 +31,99 @@ func validatePodManifest(manifest *schema.PodManifest) error {
 		}
 	}
 
+	// Ensure each app only retains the capabilities remote pods are allowed.
+	for _, runtimeApp := range manifest.Apps {
+		app := runtimeApp.App
+		if app == nil {
+			image, err := s.client.GetImage(runtimeApp.Image.ID.String())
+			if err != nil {
+				return fmt.Errorf("failed to look up the image for app %q: %v", runtimeApp.Name, err)
+			}
+			if image.Manifest == nil || image.Manifest.App == nil {
+				continue
+			}
+			app = image.Manifest.App
+		}
+
+		if err := s.validateCapabilities(app); err != nil {
+			return fmt.Errorf("app %q %v", runtimeApp.Name, err)
+		}
+	}
+
 	// FIXME once network isolation is in, this should force adding the container
 	// namespaces isolator to ensure any remotely sourced images are network
 	// namespaced.
 
 	return nil
 }
+
+// validateCapabilities ensures that the capabilities the app would be run with
+// are all within the set that remote pods are allowed to retain.
+func (s *Server) validateCapabilities(app *types.App) error {
+	caps, err := appCapabilities(app)
+	if err != nil {
+		return fmt.Errorf("has invalid capabilities: %v", err)
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range s.options.RetainableCapabilities {
+		if c, err := capabilities.Normalize(name); err == nil {
+			allowed[c] = true
+		}
+	}
+	for _, c := range caps {
+		if !allowed[c] {
+			return fmt.Errorf("cannot retain %s when launched remotely", c)
+		}
+	}
+	return nil
+}
+
+// appCapabilities returns the capabilities the app will be run with. The
+// isolators are applied in order, the same as the stager does.
+func appCapabilities(app *types.App) ([]string, error) {
+	caps := capabilities.DefaultCapabilities
+	for _, iso := range app.Isolators {
+		var err error
+		switch v := iso.Value().(type) {
+		case *kschema.LinuxPrivileged:
+			if bool(*v) {
+				caps = capabilities.GetAllCapabilities()
+			}
+		case *types.LinuxCapabilitiesRetainSet:
+			caps, err = capabilities.Retain(capabilities.Names(v))
+		case *types.LinuxCapabilitiesRevokeSet:
+			caps, err = capabilities.Remove(caps, capabilities.Names(v))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return caps, nil
+}
+
+// remoteFetchSchemes are the schemes remote clients are allowed to fetch images
+// with. An empty scheme is an image name resolved through discovery. Images on
+// the host's filesystem can only be fetched through the local API.
+var remoteFetchSchemes = map[string]bool{
+	"":       true,
+	"http":   true,
+	"https":  true,
+	"docker": true,
+}
+
+// validateFetchURI ensures that the image URI uses one of the schemes remote
+// clients are allowed to fetch images with.
+func validateFetchURI(uri string) error {
+	if uri == "" {
+		return fmt.Errorf("no image URI was specified")
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid image URI %q: %v", uri, err)
+	}
+	if !remoteFetchSchemes[u.Scheme] {
+		return fmt.Errorf("images cannot be fetched remotely with the %q scheme", u.Scheme)
+	}
+	return nil
+}
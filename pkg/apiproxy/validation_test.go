@@ -0,0 +1,81 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package apiproxy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/appc/spec/schema/types"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestFetchRejectsLocalSchemes(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	// the request is rejected before it is forwarded to the daemon, so the
+	// server has no client
+	s := &ImageService{server: &Server{}}
+	for _, uri := range []string{
+		"file:///etc/shadow",
+		"FILE:///var/lib/kurma/kurma.sock",
+		"ftp://example.com/image.aci",
+		"unix:///var/lib/kurma/kurma.sock",
+		"",
+	} {
+		err := s.Fetch(nil, &apiclient.ImageFetchRequest{URI: uri}, &apiclient.ImageFetchResponse{})
+		tt.TestExpectError(t, err)
+	}
+
+	for _, uri := range []string{
+		"http://example.com/image.aci",
+		"https://example.com/image.aci",
+		"docker:///busybox:latest",
+		"example.com/app:1.0",
+	} {
+		tt.TestExpectSuccess(t, validateFetchURI(uri))
+	}
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	s := New(&Options{
+		RetainableCapabilities: []string{"CAP_CHOWN", "CAP_KILL", "net_bind_service"},
+	})
+
+	tests := []struct {
+		name      string
+		isolators string
+		allowed   bool
+	}{
+		{"default capabilities", `[]`, false},
+		{"retain allowed", `[{"name":"os/linux/capabilities-retain-set","value":{"set":["CAP_CHOWN","kill"]}}]`, true},
+		{"retain normalized", `[{"name":"os/linux/capabilities-retain-set","value":{"set":["CAP_NET_BIND_SERVICE"]}}]`, true},
+		{"retain disallowed", `[{"name":"os/linux/capabilities-retain-set","value":{"set":["CAP_CHOWN","CAP_SYS_ADMIN"]}}]`, false},
+		{"retain unknown", `[{"name":"os/linux/capabilities-retain-set","value":{"set":["CAP_NOT_REAL"]}}]`, false},
+		{"remove leaves disallowed", `[{"name":"os/linux/capabilities-remove-set","value":{"set":["CAP_NET_RAW"]}}]`, false},
+		{"privileged", `[{"name":"os/linux/privileged","value":true}]`, false},
+		{"privileged then retain", `[{"name":"os/linux/privileged","value":true},{"name":"os/linux/capabilities-retain-set","value":{"set":["CAP_KILL"]}}]`, true},
+	}
+
+	for _, test := range tests {
+		app := &types.App{}
+		tt.TestExpectSuccess(t, json.Unmarshal([]byte(test.isolators), &app.Isolators))
+
+		err := s.validateCapabilities(app)
+		if test.allowed && err != nil {
+			t.Errorf("%s: expected the capabilities to be allowed: %v", test.name, err)
+		} else if !test.allowed && err == nil {
+			t.Errorf("%s: expected the capabilities to be rejected", test.name)
+		}
+	}
+
+	// by default, remote apps may retain the default capabilities
+	s = New(&Options{})
+	tt.TestExpectSuccess(t, s.validateCapabilities(&types.App{}))
+}
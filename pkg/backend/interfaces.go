@@ -28,6 +28,7 @@ const (
 	STOPPING
 	STOPPED
 	ERRORED
+	EXITED
 )
 
 func (c PodState) String() string {
@@ -44,6 +45,8 @@ func (c PodState) String() string {
 		return "STOPPED"
 	case ERRORED:
 		return "ERRORED"
+	case EXITED:
+		return "EXITED"
 	default:
 		return ""
 	}
@@ -68,15 +71,60 @@ type ImageManager interface {
 	GetImage(hash string) *schema.ImageManifest
 
 	// FindImage will find the image manifest and hash for the specified name and
-	// version label.
+	// version label. Local tags take precedence over version labels, and an
+	// empty version or "latest" resolves to the highest version of the image
+	// unless an image is tagged or labeled "latest".
 	FindImage(name, version string) (string, *schema.ImageManifest)
 
-	// GetImageSize will return the on disk size of the image.
-	GetImageSize(hash string) (int64, error)
+	// TagImage tags the image, so that finding the name with the tag as the
+	// version resolves to it. An existing tag is moved to the image.
+	TagImage(hash, name, tag string) error
 
-	// DeleteImage will remove the specified image hash from disk.
+	// UntagImage removes the tag from the name.
+	UntagImage(name, tag string) error
+
+	// ListTags returns a map of each tag, in the form name:tag, to the hash of
+	// the image it is on.
+	ListTags() map[string]string
+
+	// GetImageSize will return the on disk size of the image, its compressed
+	// size, and the total size including its dependencies.
+	GetImageSize(hash string) (*ImageSize, error)
+
+	// DeleteImage will remove the specified image hash from disk. It will return
+	// an error if the image is in use or another image depends on it.
 	DeleteImage(hash string) error
 
+	// SetImageSigner records the fingerprint of the key whose signature on the
+	// image was verified when it was retrieved.
+	SetImageSigner(hash, fingerprint string) error
+
+	// ImageSigner returns the fingerprint of the key that signed the image, or
+	// an empty string if the image's signature was never verified.
+	ImageSigner(hash string) string
+
+	// AddReference records that the owner is using the image, which keeps it
+	// from being deleted or garbage collected.
+	AddReference(hash, owner string)
+
+	// RemoveReferences removes all of the references held by the owner.
+	RemoveReferences(owner string)
+
+	// Prune removes all of the images which aren't needed under the garbage
+	// collection policy, regardless of disk usage. If dryRun is true, the images
+	// which would be removed are returned without removing them.
+	Prune(dryRun bool) ([]string, error)
+
+	// GarbageCollect removes images under the garbage collection policy once
+	// disk usage has reached the configured threshold. It returns the images
+	// that were removed.
+	GarbageCollect() ([]string, error)
+
+	// ExportImage writes the image to w as an ACI, returning the hash of the
+	// written ACI. Dependencies which reference images by ID are rewritten to
+	// the hashes those images have when they're exported.
+	ExportImage(hash string, w io.Writer) (string, error)
+
 	// ResolveTree will resolve the dependency tree for the specified image. It
 	// will return a []string returning the order images should be merged, the
 	// []string with all the relevant image paths on disk, the map of all the
@@ -99,6 +147,19 @@ type ResolutionTree struct {
 	Manifests map[string]*schema.ImageManifest
 }
 
+// ImageSize contains the sizes recorded for an image.
+type ImageSize struct {
+	// Size is the number of bytes the extracted image uses on disk.
+	Size int64
+
+	// CompressedSize is the size of the image as it was uploaded.
+	CompressedSize int64
+
+	// TotalSize is the on disk size of the image and all of the images it
+	// depends on.
+	TotalSize int64
+}
+
 // PodManager is responsible for the pod lifecycle management.
 type PodManager interface {
 	// SetHostSocketFile sets the path to the host's socket file for granting API
@@ -120,6 +181,10 @@ type PodManager interface {
 	// the UUID does not exist.
 	Pod(uuid string) Pod
 
+	// Restore reloads any pods left running by a previous instance of the pod
+	// manager, and cleans up after any that have since exited.
+	Restore() error
+
 	// Shutdown requests that the pod manager shut down running pods to prepare to
 	// exit.
 	Shutdown()
@@ -146,6 +211,11 @@ type PodOptions struct {
 	// networks to be used.
 	Networks []string
 
+	// PortMappings is the set of host ports to forward to ports within the pod.
+	// Ports exposed by the pod manifest are added to them when the pod is
+	// created.
+	PortMappings []*ntypes.PortMapping
+
 	// ContainerIO represents specific inputs/outputs that should be passed along
 	// to the stager for use in the specified containers. The key of the map is
 	// the application name from the pod manifest.
@@ -202,6 +272,17 @@ type Pod interface {
 	// Networks returns the configured network results for the pod.
 	Networks() []*ntypes.IPResult
 
+	// PortMappings returns the host ports forwarded to ports within the pod.
+	PortMappings() []*ntypes.PortMapping
+
+	// AttachNetwork provisions the named network on the running pod and adds it
+	// to the pod's networks.
+	AttachNetwork(network string) error
+
+	// DetachNetwork deprovisions the named network from the running pod and
+	// removes it from the pod's networks.
+	DetachNetwork(network string) error
+
 	// State returns the current operating state of the pod.
 	State() PodState
 
@@ -213,16 +294,70 @@ type Pod interface {
 	// stream in and out.
 	Enter(appName string, app *kschema.RunApp, stdin io.Reader, stdout, stderr io.Writer, postStart func()) (*os.Process, error)
 
+	// Attach is used to attach to the input and output of the primary process of
+	// an application within the pod. Input is only passed along if the
+	// application was launched interactively.
+	Attach(appName string, stdin io.Reader, stdout io.Writer) (*os.Process, error)
+
+	// AppStatus returns the status of each of the applications within the pod,
+	// keyed by the application name. It is retrieved by calling in to the
+	// stager while the pod is running, and returns the final status recorded by
+	// the stager once the pod has stopped.
+	AppStatus() (map[string]*AppStatus, error)
+
+	// ExitCode returns the exit code of the pod once it has stopped, as
+	// determined by the stager based on the pod's exit policy.
+	ExitCode() (int, error)
+
+	// StartupError returns the error which caused the pod to fail to start, or
+	// nil if it hasn't failed to start.
+	StartupError() error
+
+	// Logs writes the log output of the specified application to the provided
+	// writer. If no application name is given, the stager's own log is written
+	// instead. When following, it blocks until the log has ended or the cancel
+	// channel is closed.
+	Logs(appName string, options *LogOptions, w io.Writer, cancel <-chan struct{}) error
+
 	// WaitForState is used to poll until the state of the pod reaches a desired
 	// state.
 	WaitForState(timeout time.Duration, states ...PodState) error
 
 	// Wait can be used to block until the processes within a pod are finished
-	// executed. It is primarily intended for an internal API to code against
-	// system services.
+	// executed, or until the pod has failed to start. It is primarily intended
+	// for an internal API to code against system services.
 	Wait()
 }
 
+// AppStatus represents the runtime status of an individual application within
+// a pod, as reported by the stager.
+type AppStatus struct {
+	Pid          int    `json:"pid,omitempty"`
+	Exited       bool   `json:"exited"`
+	ExitCode     int    `json:"exitCode,omitempty"`
+	ExitReason   string `json:"exitReason,omitempty"`
+	RestartCount int    `json:"restartCount,omitempty"`
+}
+
+// LogOptions is used to specify which portion of a log should be retrieved.
+type LogOptions struct {
+	// Follow specifies whether to continue streaming new output as it is
+	// written.
+	Follow bool
+
+	// Tail is the number of lines from the end of the log to return. Zero
+	// returns the entire log.
+	Tail int
+
+	// Since excludes any output written before the specified time. It only
+	// applies to application logs.
+	Since time.Time
+
+	// Timestamps specifies whether each line should be prefixed with the time it
+	// was written. It only applies to application logs.
+	Timestamps bool
+}
+
 // NetworkDriver represents a single networking plugin within the networking
 // pod.
 type NetworkDriver struct {
@@ -230,6 +365,16 @@ type NetworkDriver struct {
 	Configuration *ntypes.NetConf
 }
 
+// Network describes a configured network and the pods it has been provisioned
+// on.
+type Network struct {
+	Driver *NetworkDriver
+
+	// PodInterfaces maps the UUID of each pod the network has been provisioned
+	// on to the name of its interface within the pod.
+	PodInterfaces map[string]string
+}
+
 // NetworkManager is responsible for managing the list of configured network
 // plugins, and communicating with the plugins for provisioning networking on
 // individual pods.
@@ -245,7 +390,38 @@ type NetworkManager interface {
 	// responsible for instrumenting the necessary network plugins for the pod.
 	Provision(pod Pod, networks []string) (string, []*ntypes.IPResult, error)
 
+	// HasNetwork returns whether a network with the specified name has been
+	// configured.
+	HasNetwork(name string) bool
+
 	// Deprovision is called when a pod is shutting down to handle any
 	// deallocation or cleanup processes that are necessary.
 	Deprovision(pod Pod) error
+
+	// Check verifies that the networks provisioned on a pod are still configured
+	// as they were provisioned.
+	Check(pod Pod) error
+
+	// Attach provisions an additional network on a running pod, returning the
+	// network's result.
+	Attach(pod Pod, network string) (*ntypes.IPResult, error)
+
+	// Detach deprovisions one of the networks from a running pod.
+	Detach(pod Pod, network string) error
+
+	// Networks returns each of the configured networks along with the
+	// interfaces they have provisioned on pods.
+	Networks() []*Network
+
+	// AddNetwork configures a new network, relaunching the networking pod to
+	// include its plugin.
+	AddNetwork(driver *NetworkDriver) error
+
+	// UpdateNetwork replaces the configuration of an existing network,
+	// relaunching the networking pod to apply it.
+	UpdateNetwork(driver *NetworkDriver) error
+
+	// RemoveNetwork removes a network which isn't provisioned on any pods,
+	// relaunching the networking pod without its plugin.
+	RemoveNetwork(name string) error
 }
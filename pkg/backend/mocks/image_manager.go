@@ -10,14 +10,24 @@ import (
 )
 
 type ImageManager struct {
-	RescanFunc       func() error
-	CreateImageFunc  func(reader io.Reader) (string, *schema.ImageManifest, error)
-	ListImagesFunc   func() map[string]*schema.ImageManifest
-	GetImageFunc     func(hash string) *schema.ImageManifest
-	FindImageFunc    func(name, version string) (string, *schema.ImageManifest)
-	GetImageSizeFunc func(hash string) (int64, error)
-	DeleteImageFunc  func(hash string) error
-	ResolveTreeFunc  func(hash string) (*backend.ResolutionTree, error)
+	RescanFunc           func() error
+	CreateImageFunc      func(reader io.Reader) (string, *schema.ImageManifest, error)
+	ListImagesFunc       func() map[string]*schema.ImageManifest
+	GetImageFunc         func(hash string) *schema.ImageManifest
+	FindImageFunc        func(name, version string) (string, *schema.ImageManifest)
+	TagImageFunc         func(hash, name, tag string) error
+	UntagImageFunc       func(name, tag string) error
+	ListTagsFunc         func() map[string]string
+	GetImageSizeFunc     func(hash string) (*backend.ImageSize, error)
+	DeleteImageFunc      func(hash string) error
+	SetImageSignerFunc   func(hash, fingerprint string) error
+	ImageSignerFunc      func(hash string) string
+	AddReferenceFunc     func(hash, owner string)
+	RemoveReferencesFunc func(owner string)
+	PruneFunc            func(dryRun bool) ([]string, error)
+	GarbageCollectFunc   func() ([]string, error)
+	ExportImageFunc      func(hash string, w io.Writer) (string, error)
+	ResolveTreeFunc      func(hash string) (*backend.ResolutionTree, error)
 }
 
 func (im *ImageManager) Rescan() error {
@@ -40,7 +50,19 @@ func (im *ImageManager) FindImage(name, version string) (string, *schema.ImageMa
 	return im.FindImageFunc(name, version)
 }
 
-func (im *ImageManager) GetImageSize(hash string) (int64, error) {
+func (im *ImageManager) TagImage(hash, name, tag string) error {
+	return im.TagImageFunc(hash, name, tag)
+}
+
+func (im *ImageManager) UntagImage(name, tag string) error {
+	return im.UntagImageFunc(name, tag)
+}
+
+func (im *ImageManager) ListTags() map[string]string {
+	return im.ListTagsFunc()
+}
+
+func (im *ImageManager) GetImageSize(hash string) (*backend.ImageSize, error) {
 	return im.GetImageSizeFunc(hash)
 }
 
@@ -48,6 +70,34 @@ func (im *ImageManager) DeleteImage(hash string) error {
 	return im.DeleteImageFunc(hash)
 }
 
+func (im *ImageManager) SetImageSigner(hash, fingerprint string) error {
+	return im.SetImageSignerFunc(hash, fingerprint)
+}
+
+func (im *ImageManager) ImageSigner(hash string) string {
+	return im.ImageSignerFunc(hash)
+}
+
+func (im *ImageManager) AddReference(hash, owner string) {
+	im.AddReferenceFunc(hash, owner)
+}
+
+func (im *ImageManager) RemoveReferences(owner string) {
+	im.RemoveReferencesFunc(owner)
+}
+
+func (im *ImageManager) Prune(dryRun bool) ([]string, error) {
+	return im.PruneFunc(dryRun)
+}
+
+func (im *ImageManager) GarbageCollect() ([]string, error) {
+	return im.GarbageCollectFunc()
+}
+
+func (im *ImageManager) ExportImage(hash string, w io.Writer) (string, error) {
+	return im.ExportImageFunc(hash, w)
+}
+
 func (im *ImageManager) ResolveTree(hash string) (*backend.ResolutionTree, error) {
 	return im.ResolveTreeFunc(hash)
 }
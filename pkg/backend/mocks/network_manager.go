@@ -10,9 +10,17 @@ import (
 )
 
 type NetworkManager struct {
-	SetupFunc       func(drivers []*backend.NetworkDriver) error
-	ProvisionFunc   func(pod backend.Pod, networks []string) (string, []*ntypes.IPResult, error)
-	DeprovisionFunc func(pod backend.Pod) error
+	SetupFunc         func(drivers []*backend.NetworkDriver) error
+	ProvisionFunc     func(pod backend.Pod, networks []string) (string, []*ntypes.IPResult, error)
+	DeprovisionFunc   func(pod backend.Pod) error
+	HasNetworkFunc    func(name string) bool
+	CheckFunc         func(pod backend.Pod) error
+	AttachFunc        func(pod backend.Pod, network string) (*ntypes.IPResult, error)
+	DetachFunc        func(pod backend.Pod, network string) error
+	NetworksFunc      func() []*backend.Network
+	AddNetworkFunc    func(driver *backend.NetworkDriver) error
+	UpdateNetworkFunc func(driver *backend.NetworkDriver) error
+	RemoveNetworkFunc func(name string) error
 }
 
 func (nm *NetworkManager) SetLog(log *logray.Logger) {}
@@ -25,6 +33,38 @@ func (nm *NetworkManager) Provision(pod backend.Pod, networks []string) (string,
 	return nm.ProvisionFunc(pod, networks)
 }
 
+func (nm *NetworkManager) HasNetwork(name string) bool {
+	return nm.HasNetworkFunc(name)
+}
+
 func (nm *NetworkManager) Deprovision(pod backend.Pod) error {
 	return nm.DeprovisionFunc(pod)
 }
+
+func (nm *NetworkManager) Check(pod backend.Pod) error {
+	return nm.CheckFunc(pod)
+}
+
+func (nm *NetworkManager) Attach(pod backend.Pod, network string) (*ntypes.IPResult, error) {
+	return nm.AttachFunc(pod, network)
+}
+
+func (nm *NetworkManager) Detach(pod backend.Pod, network string) error {
+	return nm.DetachFunc(pod, network)
+}
+
+func (nm *NetworkManager) Networks() []*backend.Network {
+	return nm.NetworksFunc()
+}
+
+func (nm *NetworkManager) AddNetwork(driver *backend.NetworkDriver) error {
+	return nm.AddNetworkFunc(driver)
+}
+
+func (nm *NetworkManager) UpdateNetwork(driver *backend.NetworkDriver) error {
+	return nm.UpdateNetworkFunc(driver)
+}
+
+func (nm *NetworkManager) RemoveNetwork(name string) error {
+	return nm.RemoveNetworkFunc(name)
+}
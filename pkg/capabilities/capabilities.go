@@ -8,11 +8,31 @@ import (
 	"os"
 	"strings"
 
+	"github.com/appc/spec/schema/types"
 	"github.com/syndtr/gocapability/capability"
 )
 
 var capabilityList []string
 
+// DefaultCapabilities is the set of capabilities that applications are run with
+// when they don't specify a capabilities isolator.
+var DefaultCapabilities = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_FSETID",
+	"CAP_FOWNER",
+	"CAP_MKNOD",
+	"CAP_NET_RAW",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETFCAP",
+	"CAP_SETPCAP",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_SYS_CHROOT",
+	"CAP_KILL",
+	"CAP_AUDIT_WRITE",
+}
+
 func init() {
 	RefreshCapabilities()
 }
@@ -61,3 +81,68 @@ func RefreshCapabilities() {
 		capabilityList = append(capabilityList, fmt.Sprintf("CAP_%s", strings.ToUpper(cap.String())))
 	}
 }
+
+// Normalize returns the name of the capability in the form of CAP_NAME, which
+// is how it is referenced by libcontainer and the appc isolators. It returns an
+// error if the capability isn't supported by the host.
+func Normalize(name string) (string, error) {
+	normalized := strings.ToUpper(name)
+	if !strings.HasPrefix(normalized, "CAP_") {
+		normalized = "CAP_" + normalized
+	}
+	for _, c := range capabilityList {
+		if c == normalized {
+			return normalized, nil
+		}
+	}
+	return "", fmt.Errorf("unknown capability %q", name)
+}
+
+// Retain returns the normalized set of capabilities for an app which should
+// retain only the specified capabilities.
+func Retain(retain []string) ([]string, error) {
+	caps := make([]string, 0, len(retain))
+	seen := make(map[string]bool)
+	for _, name := range retain {
+		c, err := Normalize(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[c] {
+			seen[c] = true
+			caps = append(caps, c)
+		}
+	}
+	return caps, nil
+}
+
+// Remove returns the provided set of capabilities without those that are to be
+// removed.
+func Remove(caps []string, remove []string) ([]string, error) {
+	removed := make(map[string]bool)
+	for _, name := range remove {
+		c, err := Normalize(name)
+		if err != nil {
+			return nil, err
+		}
+		removed[c] = true
+	}
+
+	result := make([]string, 0, len(caps))
+	for _, c := range caps {
+		if !removed[c] {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// Names returns the names of the capabilities in an appc capabilities isolator,
+// as they were given in the isolator.
+func Names(set types.LinuxCapabilitiesSet) []string {
+	names := make([]string, len(set.Set()))
+	for i, c := range set.Set() {
+		names[i] = string(c)
+	}
+	return names
+}
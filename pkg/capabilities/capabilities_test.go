@@ -0,0 +1,58 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package capabilities
+
+import (
+	"testing"
+
+	"github.com/appc/spec/schema/types"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestNormalize(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	c, err := Normalize("CAP_NET_ADMIN")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, c, "CAP_NET_ADMIN")
+
+	c, err = Normalize("net_admin")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, c, "CAP_NET_ADMIN")
+
+	_, err = Normalize("CAP_NOT_REAL")
+	tt.TestExpectError(t, err)
+}
+
+func TestRetainAndRemove(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	caps, err := Retain([]string{"CAP_NET_ADMIN", "CAP_CHOWN", "net_admin"})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, caps, []string{"CAP_NET_ADMIN", "CAP_CHOWN"})
+
+	_, err = Retain([]string{"CAP_NOT_REAL"})
+	tt.TestExpectError(t, err)
+
+	caps, err = Remove(DefaultCapabilities, []string{"CAP_NET_RAW"})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(caps), len(DefaultCapabilities)-1)
+	for _, c := range caps {
+		tt.TestNotEqual(t, c, "CAP_NET_RAW")
+	}
+
+	_, err = Remove(DefaultCapabilities, []string{"CAP_NOT_REAL"})
+	tt.TestExpectError(t, err)
+}
+
+func TestNames(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	set, err := types.NewLinuxCapabilitiesRetainSet("CAP_NET_ADMIN", "chown")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, Names(set), []string{"CAP_NET_ADMIN", "chown"})
+}
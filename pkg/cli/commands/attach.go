@@ -0,0 +1,150 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apcera/kurma/pkg/cli"
+	"github.com/appc/spec/schema/types"
+	"github.com/creack/termios/raw"
+	"github.com/spf13/cobra"
+
+	kschema "github.com/apcera/kurma/schema"
+)
+
+var (
+	AttachCmd = &cobra.Command{
+		Use:   "attach UUID APP",
+		Short: "Attach to the console of an app in a running pod",
+		Run:   cmdAttach,
+	}
+
+	attachDetachKeys string
+)
+
+func init() {
+	cli.RootCmd.AddCommand(AttachCmd)
+	AttachCmd.Flags().StringVarP(&attachDetachKeys, "detach-keys", "", "ctrl-p,ctrl-q", "key sequence for detaching from an interactive app")
+}
+
+func cmdAttach(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Invalid command options specified.\n")
+		cmd.Help()
+		return
+	}
+
+	detachKeys, err := parseDetachKeys(attachDetachKeys)
+	if err != nil {
+		fmt.Printf("Invalid detach keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	client := cli.GetClient()
+
+	// Check whether the app is interactive. Input is only sent to interactive
+	// apps, otherwise only its output is streamed.
+	pod, err := client.GetPod(args[0])
+	if err != nil {
+		fmt.Printf("Failed to retrieve pod: %v\n", err)
+		os.Exit(1)
+	}
+	runtimeApp := pod.Pod.Apps.Get(types.ACName(args[1]))
+	if runtimeApp == nil {
+		fmt.Printf("The pod does not contain an app named %q\n", args[1])
+		os.Exit(1)
+	}
+	interactive := false
+	if runtimeApp.App != nil {
+		if iso := runtimeApp.App.Isolators.GetByName(kschema.AppInteractiveName); iso != nil {
+			if piso, ok := iso.Value().(*kschema.AppInteractive); ok {
+				interactive = bool(*piso)
+			}
+		}
+	}
+
+	conn, err := client.AttachContainer(args[0], args[1])
+	if err != nil {
+		fmt.Printf("Failed to attach to the app: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	if interactive {
+		// Set the local terminal in raw mode to turn off buffering and local
+		// echo. Also defers setting it back to normal for when the call is done.
+		termios, err := raw.MakeRaw(os.Stdin.Fd())
+		if err == nil {
+			defer raw.TcSetAttr(os.Stdin.Fd(), termios)
+		}
+
+		go func() {
+			copyUntilDetach(conn, os.Stdin, detachKeys)
+			conn.Close()
+		}()
+	}
+	io.Copy(os.Stdout, conn)
+}
+
+// parseDetachKeys parses a comma separated list of keys, such as "ctrl-p,ctrl-q"
+// or "a", into the byte sequence they produce.
+func parseDetachKeys(s string) ([]byte, error) {
+	var keys []byte
+	for _, key := range strings.Split(s, ",") {
+		key = strings.ToLower(strings.TrimSpace(key))
+		switch {
+		case len(key) == 1:
+			keys = append(keys, key[0])
+		case strings.HasPrefix(key, "ctrl-") && len(key) == 6 && strings.IndexByte("abcdefghijklmnopqrstuvwxyz@[\\]^_", key[5]) >= 0:
+			// control characters are the key with the upper bits masked off
+			keys = append(keys, key[5]&0x1f)
+		default:
+			return nil, fmt.Errorf("unrecognized key %q", key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys specified")
+	}
+	return keys, nil
+}
+
+// copyUntilDetach copies from the reader to the writer until the reader is
+// closed or the detach key sequence is read. Any partially matched sequence is
+// passed along once it stops matching.
+func copyUntilDetach(w io.Writer, r io.Reader, detachKeys []byte) error {
+	buf := make([]byte, 1024)
+	matched := 0
+	for {
+		n, err := r.Read(buf)
+		out := make([]byte, 0, n+matched)
+		for _, b := range buf[:n] {
+			if b == detachKeys[matched] {
+				matched++
+				if matched == len(detachKeys) {
+					_, werr := w.Write(out)
+					return werr
+				}
+				continue
+			}
+			out = append(out, detachKeys[:matched]...)
+			matched = 0
+			if b == detachKeys[0] {
+				matched = 1
+				continue
+			}
+			out = append(out, b)
+		}
+		if len(out) > 0 {
+			if _, err := w.Write(out); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
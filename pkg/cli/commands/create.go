@@ -5,17 +5,21 @@ package commands
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/apcera/kurma/pkg/aciremote"
 	"github.com/apcera/kurma/pkg/apiclient"
 	"github.com/apcera/kurma/pkg/cli"
+	"github.com/apcera/kurma/pkg/ociimage"
 	"github.com/apcera/util/tempfile"
 	"github.com/appc/spec/schema"
 	"github.com/appc/spec/schema/types"
 	"github.com/spf13/cobra"
 
+	ntypes "github.com/apcera/kurma/pkg/networkmanager/types"
 	kschema "github.com/apcera/kurma/schema"
 )
 
@@ -29,6 +33,11 @@ var (
 	createManifestFile string
 	createName         string
 	createNetworks     []string
+	createPorts        []string
+	createInteractive  bool
+	createStager       string
+	createExitPolicy   string
+	createWait         bool
 )
 
 func init() {
@@ -36,11 +45,20 @@ func init() {
 	CreateCmd.Flags().StringVarP(&createName, "name", "n", "", "pod's name")
 	CreateCmd.Flags().StringVarP(&createManifestFile, "manifest", "", "", "specific manifest to use")
 	CreateCmd.Flags().StringSliceVarP(&createNetworks, "net", "", []string{}, "network to attach to the pod")
+	CreateCmd.Flags().StringSliceVarP(&createPorts, "port", "p", []string{}, "host port to forward to the pod, as [HOSTIP:]HOSTPORT:PORT[/PROTOCOL]")
+	CreateCmd.Flags().StringVarP(&createStager, "stager", "", "", "stager image hash, file, or URI to use for the pod")
+	CreateCmd.Flags().BoolVarP(&createInteractive, "interactive", "i", false, "launch the app interactively so its console can be attached to")
+	CreateCmd.Flags().StringVarP(&createExitPolicy, "exit-policy", "", "", "when to stop the pod as apps exit: none, all, any, or app:NAME")
+	CreateCmd.Flags().BoolVarP(&createWait, "wait", "", false, "wait for the pod to exit and exit with its exit code")
 }
 
 func createPodFromFile(file string) (*apiclient.Image, error) {
 	var f tempfile.ReadSeekCloser
 
+	if filename, ref := splitImageRef(file); ociimage.IsImage(filename) {
+		return importImage(filename, ref)
+	}
+
 	// open the file
 	f, err := os.Open(file)
 	if err != nil {
@@ -54,6 +72,11 @@ func createPodFromFile(file string) (*apiclient.Image, error) {
 			labels[types.ACIdentifier("os")] = "linux"
 			labels[types.ACIdentifier("arch")] = info.Arch
 
+			if err := info.FetchPolicy.Check(file); err != nil {
+				fmt.Printf("Failed to retrieve the container image: %v\n", err)
+				os.Exit(1)
+			}
+
 			f, err = aciremote.RetrieveImage(file, labels, true)
 			if err != nil {
 				fmt.Printf("Failed to retrieve the container image: %v\n", err)
@@ -120,6 +143,12 @@ func cmdCreate(cmd *cobra.Command, args []string) {
 		if len(args) > 1 {
 			app.Exec = args[1:]
 		}
+		if createInteractive {
+			if err := setInteractive(app); err != nil {
+				fmt.Printf("Failed to update the app to be interactive: %v\n", err)
+				os.Exit(1)
+			}
+		}
 
 		// create the RuntimeApp
 		runtimeApp := schema.RuntimeApp{
@@ -148,11 +177,45 @@ func cmdCreate(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	// apply the exit policy. Waiting on the pod requires that it will exit, so
+	// default to stopping it once all of its apps exit.
+	if createExitPolicy == "" && createWait && !hasExitPolicy(manifest) {
+		createExitPolicy = kschema.ExitPolicyAll
+	}
+	if createExitPolicy != "" {
+		if err := setExitPolicy(manifest, createExitPolicy); err != nil {
+			fmt.Printf("Failed to set the pod's exit policy: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	req := &apiclient.PodCreateRequest{
 		Name:     createName,
 		Pod:      manifest,
 		Networks: createNetworks,
 	}
+	for _, port := range createPorts {
+		mapping, err := parsePortMapping(port)
+		if err != nil {
+			fmt.Printf("Failed to parse the port mapping: %v\n", err)
+			os.Exit(1)
+		}
+		req.PortMappings = append(req.PortMappings, mapping)
+	}
+
+	// use the specified stager, uploading it if it isn't already a hash
+	if createStager != "" {
+		if strings.HasPrefix(createStager, "sha512-") {
+			req.StagerImageHash = createStager
+		} else {
+			image, err := createPodFromFile(createStager)
+			if err != nil {
+				fmt.Printf("Failed to handle stager image: %v\n", err)
+				os.Exit(1)
+			}
+			req.StagerImageHash = image.Hash
+		}
+	}
 
 	// create the container
 	pod, err := cli.GetClient().CreatePod(req)
@@ -161,7 +224,49 @@ func cmdCreate(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	if pod.Error != "" {
+		fmt.Printf("Pod %s failed to start: %s\n", pod.UUID, pod.Error)
+		os.Exit(1)
+	}
+
 	fmt.Printf("Launched pod %s\n", pod.UUID)
+
+	if createWait {
+		waitForPod(pod.UUID)
+	}
+}
+
+// parsePortMapping parses a port mapping in the form
+// [HOSTIP:]HOSTPORT:PORT[/PROTOCOL], such as "8080:80/tcp". The protocol
+// defaults to tcp.
+func parsePortMapping(s string) (*ntypes.PortMapping, error) {
+	mapping := &ntypes.PortMapping{Protocol: "tcp"}
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		mapping.Protocol = strings.ToLower(s[i+1:])
+		s = s[:i]
+	}
+
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("%q must specify both the host port and the pod's port", s)
+	}
+	port, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", s[i+1:])
+	}
+	mapping.ContainerPort = port
+
+	s = s[:i]
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		mapping.HostIP = strings.Trim(s[:i], "[]")
+		s = s[i+1:]
+	}
+	port, err = strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host port %q", s)
+	}
+	mapping.HostPort = port
+	return mapping, nil
 }
 
 func convertACIdentifierToACName(name types.ACIdentifier) (*types.ACName, error) {
@@ -208,3 +313,106 @@ func setHostNetworking(pod *schema.PodManifest) error {
 	pod.Isolators = append(pod.Isolators, i)
 	return nil
 }
+
+func setInteractive(app *types.App) error {
+	if app == nil {
+		return fmt.Errorf("the image does not define an app")
+	}
+	// drop any existing value before adding it
+	isolators := app.Isolators[:0]
+	for _, i := range app.Isolators {
+		if i.Name.String() != kschema.AppInteractiveName {
+			isolators = append(isolators, i)
+		}
+	}
+	app.Isolators = isolators
+
+	var i types.Isolator
+	b := []byte(fmt.Sprintf(`{"name":%q,"value":true}`, kschema.AppInteractiveName))
+	if err := i.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	app.Isolators = append(app.Isolators, i)
+	return nil
+}
+
+func hasExitPolicy(pod *schema.PodManifest) bool {
+	for _, i := range pod.Isolators {
+		if i.Name.String() == kschema.PodExitPolicyName {
+			return true
+		}
+	}
+	return false
+}
+
+// setExitPolicy sets the pod's exit policy. The policy is either "none", "all",
+// "any", or "app:NAME" to stop the pod when the named app exits.
+func setExitPolicy(pod *schema.PodManifest, policy string) error {
+	value := struct {
+		Policy string `json:"policy"`
+		App    string `json:"app,omitempty"`
+	}{Policy: policy}
+	if parts := strings.SplitN(policy, ":", 2); len(parts) == 2 && parts[0] == kschema.ExitPolicyApp {
+		value.Policy = kschema.ExitPolicyApp
+		value.App = parts[1]
+	}
+
+	// drop any existing value before adding it
+	isolators := pod.Isolators[:0]
+	for _, i := range pod.Isolators {
+		if i.Name.String() != kschema.PodExitPolicyName {
+			isolators = append(isolators, i)
+		}
+	}
+	pod.Isolators = isolators
+
+	var interim struct {
+		Name  string      `json:"name"`
+		Value interface{} `json:"value"`
+	}
+	interim.Name = kschema.PodExitPolicyName
+	interim.Value = value
+
+	b, err := json.Marshal(interim)
+	if err != nil {
+		return err
+	}
+
+	var i types.Isolator
+	if err := i.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	pod.Isolators = append(pod.Isolators, i)
+	return nil
+}
+
+// splitImageRef splits the name of the image to use within an OCI image
+// layout or Docker archive off of the file, as in "busybox.tar#busybox:latest".
+func splitImageRef(file string) (string, string) {
+	if _, err := os.Stat(file); err == nil {
+		return file, ""
+	}
+	if i := strings.LastIndex(file, "#"); i >= 0 {
+		return file[:i], file[i+1:]
+	}
+	return file, ""
+}
+
+// importImage uploads each of the layers of the image within the OCI image
+// layout or Docker archive as their own image, returning the top level image.
+func importImage(file, ref string) (*apiclient.Image, error) {
+	client := cli.GetClient()
+	hash, _, err := ociimage.Import(file, ref, func(r io.Reader) (string, *schema.ImageManifest, error) {
+		image, err := client.CreateImage(r)
+		if err != nil {
+			return "", nil, err
+		}
+		return image.Hash, image.Manifest, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import the image: %v", err)
+	}
+	return client.GetImage(hash)
+}
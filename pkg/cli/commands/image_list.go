@@ -5,9 +5,12 @@ package commands
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/apcera/kurma/pkg/cli"
+	"github.com/apcera/kurma/pkg/ociimage"
 	"github.com/apcera/termtables"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
@@ -24,16 +27,26 @@ var (
 	}
 
 	ImageUploadCmd = &cobra.Command{
-		Use:   "upload FILE",
+		Use:   "upload FILE[#IMAGE]",
 		Short: "Upload an image to the system",
 		Run:   cmdImageUpload,
 	}
+
+	ImagePruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Remove images which are no longer needed",
+		Run:   cmdImagePrune,
+	}
+
+	imagePruneDryRun bool
 )
 
 func init() {
 	cli.RootCmd.AddCommand(ImageCmd)
 	ImageCmd.AddCommand(ImageUploadCmd)
 	ImageCmd.AddCommand(ImageListCmd)
+	ImageCmd.AddCommand(ImagePruneCmd)
+	ImagePruneCmd.Flags().BoolVarP(&imagePruneDryRun, "dry-run", "", false, "only list the images that would be removed")
 }
 
 func cmdImageList(cmd *cobra.Command, args []string) {
@@ -51,10 +64,16 @@ func cmdImageList(cmd *cobra.Command, args []string) {
 	// create the table
 	table := termtables.CreateTable()
 
-	table.AddHeaders("UUID", "Name")
+	table.AddHeaders("UUID", "Name", "Size", "Compressed", "Total Size", "Tags")
 
 	for _, image := range images {
-		table.AddRow(getShortHash(image.Hash), image.Manifest.Name)
+		table.AddRow(
+			getShortHash(image.Hash),
+			image.Manifest.Name,
+			humanize.Bytes(uint64(image.Size)),
+			humanize.Bytes(uint64(image.CompressedSize)),
+			humanize.Bytes(uint64(image.TotalSize)),
+			strings.Join(image.Tags, ", "))
 	}
 	fmt.Printf("%s", table.Render())
 }
@@ -73,6 +92,17 @@ func cmdImageUpload(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// OCI image layouts and Docker archives are uploaded a layer at a time
+	if filename, ref := splitImageRef(args[0]); ociimage.IsImage(filename) {
+		image, err := importImage(filename, ref)
+		if err != nil {
+			fmt.Printf("Failed to upload the image: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Successfully uploaded image %s\n", image.Manifest.Name)
+		return
+	}
+
 	// open the file
 	f, err := os.Open(args[0])
 	if err != nil {
@@ -90,3 +120,27 @@ func cmdImageUpload(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Successfully uploaded image %s\n", image.Manifest.Name)
 }
+
+func cmdImagePrune(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		fmt.Printf("Invalid command options specified.\n")
+		cmd.Help()
+		return
+	}
+
+	images, err := cli.GetClient().PruneImages(imagePruneDryRun)
+	for _, hash := range images {
+		if imagePruneDryRun {
+			fmt.Printf("Would remove %s\n", getShortHash(hash))
+		} else {
+			fmt.Printf("Removed %s\n", getShortHash(hash))
+		}
+	}
+	if err != nil {
+		fmt.Printf("Failed to prune images: %v\n", err)
+		os.Exit(1)
+	}
+	if len(images) == 0 {
+		fmt.Printf("No images to remove\n")
+	}
+}
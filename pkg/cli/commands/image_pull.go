@@ -0,0 +1,106 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/kurma/pkg/cli"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ImagePullCmd = &cobra.Command{
+		Use:   "pull URI",
+		Short: "Have the host retrieve an image by URI or discovery name",
+		Run:   cmdImagePull,
+	}
+
+	imagePullLabels           []string
+	imagePullKeepDockerLayers bool
+)
+
+func init() {
+	ImageCmd.AddCommand(ImagePullCmd)
+	ImagePullCmd.Flags().StringSliceVarP(&imagePullLabels, "label", "l", []string{}, "label to use for discovery, as name=value")
+	ImagePullCmd.Flags().BoolVar(&imagePullKeepDockerLayers, "keep-docker-layers", false, "import each layer of a Docker image as its own image")
+}
+
+func cmdImagePull(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Invalid command options specified.\n")
+		cmd.Help()
+		return
+	}
+
+	req := &apiclient.ImageFetchRequest{URI: args[0], Labels: make(map[string]string)}
+	for _, label := range imagePullLabels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 {
+			fmt.Printf("Invalid label %q, it must be in the form name=value.\n", label)
+			os.Exit(1)
+		}
+		req.Labels[parts[0]] = parts[1]
+	}
+	if cmd.Flags().Changed("keep-docker-layers") {
+		req.KeepDockerLayers = &imagePullKeepDockerLayers
+	}
+
+	client := cli.GetClient()
+	id, err := client.FetchImage(req)
+	if err != nil {
+		fmt.Printf("Failed to fetch the image: %v\n", err)
+		os.Exit(1)
+	}
+
+	body, err := client.ImageFetchProgress(id)
+	if err != nil {
+		fmt.Printf("Failed to retrieve the fetch progress: %v\n", err)
+		os.Exit(1)
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var progress *apiclient.ImageFetchProgress
+		if err := dec.Decode(&progress); err == io.EOF {
+			fmt.Printf("\nThe fetch ended without a result.\n")
+			os.Exit(1)
+		} else if err != nil {
+			fmt.Printf("\nFailed to read the fetch progress: %v\n", err)
+			os.Exit(1)
+		}
+
+		switch {
+		case progress.Error != "":
+			fmt.Printf("\nFailed to fetch the image: %s\n", progress.Error)
+			os.Exit(1)
+		case progress.Image != nil:
+			fmt.Printf("\nFetched image %s (%s)\n", progress.Image.Hash, progress.Image.Manifest.Name)
+			return
+		default:
+			fmt.Printf("\r\033[K%s", formatFetchProgress(progress))
+		}
+	}
+}
+
+// formatFetchProgress returns a single line description of the progress.
+func formatFetchProgress(p *apiclient.ImageFetchProgress) string {
+	s := p.Stage
+	if p.Bytes > 0 {
+		s += " " + humanize.Bytes(uint64(p.Bytes))
+		if p.Total > 0 {
+			s += fmt.Sprintf(" / %s (%d%%)", humanize.Bytes(uint64(p.Total)), p.Bytes*100/p.Total)
+		}
+	}
+	if p.Layers > 0 {
+		s += fmt.Sprintf(", %d layers", p.Layers)
+	}
+	return s
+}
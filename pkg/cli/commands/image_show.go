@@ -0,0 +1,216 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/kurma/pkg/cli"
+	"github.com/apcera/termtables"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ImageShowCmd = &cobra.Command{
+		Use:   "show HASH [PATH]",
+		Short: "Show an image, or list the files at a path within it",
+		Run:   cmdImageShow,
+	}
+
+	ImageExportCmd = &cobra.Command{
+		Use:   "export HASH",
+		Short: "Export an image as an ACI",
+		Run:   cmdImageExport,
+	}
+
+	ImageCatCmd = &cobra.Command{
+		Use:   "cat HASH PATH",
+		Short: "Print the contents of a file within an image",
+		Run:   cmdImageCat,
+	}
+
+	imageExportOutput       string
+	imageExportDependencies bool
+)
+
+func init() {
+	ImageCmd.AddCommand(ImageShowCmd)
+	ImageCmd.AddCommand(ImageExportCmd)
+	ImageCmd.AddCommand(ImageCatCmd)
+	ImageExportCmd.Flags().StringVarP(&imageExportOutput, "output", "o", "", "file to write the image to, or the directory to write each image to with --dependencies")
+	ImageExportCmd.Flags().BoolVarP(&imageExportDependencies, "dependencies", "", false, "also export the images the image depends on")
+}
+
+func cmdImageShow(cmd *cobra.Command, args []string) {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Printf("Must specify the hash of the image to show.\n")
+		cmd.Help()
+		return
+	}
+
+	client := cli.GetClient()
+	hash := resolveImageHash(client, args[0])
+
+	if len(args) == 1 {
+		image, err := client.GetImage(hash)
+		if err != nil {
+			fmt.Printf("Failed to retrieve image: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Image %s:\n\n", image.Hash)
+
+		// convert back with pretty mode
+		b, err := json.MarshalIndent(image, "", "  ")
+		if err != nil {
+			fmt.Printf("Failed to marshal image: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s\n", string(b))
+		return
+	}
+
+	files, err := client.ListImageFiles(&apiclient.ImageFilesRequest{Hash: hash, Path: args[1]})
+	if err != nil {
+		fmt.Printf("Failed to list files: %v\n", err)
+		os.Exit(1)
+	}
+
+	table := termtables.CreateTable()
+	table.AddHeaders("Mode", "Size", "Modified", "Path")
+	for _, file := range files {
+		name := file.Path
+		if file.Target != "" {
+			name += " -> " + file.Target
+		}
+		table.AddRow(file.Mode.String(), humanize.Bytes(uint64(file.Size)), humanize.Time(file.ModTime), name)
+	}
+	fmt.Printf("%s", table.Render())
+}
+
+func cmdImageExport(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Must specify the hash of the image to export.\n")
+		cmd.Help()
+		return
+	}
+	if imageExportDependencies && imageExportOutput == "" {
+		fmt.Printf("Must specify the directory to export the images to with --output.\n")
+		os.Exit(1)
+	}
+
+	client := cli.GetClient()
+	hash := resolveImageHash(client, args[0])
+	images, err := client.ExportImage(&apiclient.ImageExportRequest{Hash: hash, Dependencies: imageExportDependencies})
+	if err != nil {
+		fmt.Printf("Failed to export image: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !imageExportDependencies {
+		if err := exportImage(client, hash, imageExportOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export image: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// each image is written to its own file, numbered in the order the images
+	// need to be uploaded
+	if err := os.MkdirAll(imageExportOutput, os.FileMode(0755)); err != nil {
+		fmt.Printf("Failed to create the output directory: %v\n", err)
+		os.Exit(1)
+	}
+	for i, hash := range images {
+		filename := filepath.Join(imageExportOutput, fmt.Sprintf("%02d-%s.aci", i+1, getShortHash(hash)))
+		if err := exportImage(client, hash, filename); err != nil {
+			fmt.Printf("Failed to export image %s: %v\n", getShortHash(hash), err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %s to %s\n", getShortHash(hash), filename)
+	}
+}
+
+// exportImage writes the image to the file, or to stdout if the filename is
+// empty or "-".
+func exportImage(client apiclient.Client, hash, filename string) error {
+	body, err := client.ImageExportData(hash)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if filename == "" || filename == "-" {
+		_, err := io.Copy(os.Stdout, body)
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(filename)
+		return err
+	}
+	return f.Close()
+}
+
+func cmdImageCat(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Must specify the hash of the image and the path of the file.\n")
+		cmd.Help()
+		return
+	}
+
+	client := cli.GetClient()
+	body, err := client.ReadImageFile(resolveImageHash(client, args[0]), args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read the file: %v\n", err)
+		os.Exit(1)
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(os.Stdout, body); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read the file: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// resolveImageHash expands the shortened hash shown by "image list" to the
+// full hash of the image.
+func resolveImageHash(client apiclient.Client, prefix string) string {
+	images, err := client.ListImages()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get list of images: %v\n", err)
+		os.Exit(1)
+	}
+
+	var matches []string
+	for _, image := range images {
+		if image.Hash == prefix {
+			return image.Hash
+		}
+		if strings.HasPrefix(image.Hash, prefix) {
+			matches = append(matches, image.Hash)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		fmt.Fprintf(os.Stderr, "No image matches %q.\n", prefix)
+	case 1:
+		return matches[0]
+	default:
+		fmt.Fprintf(os.Stderr, "More than one image matches %q.\n", prefix)
+	}
+	os.Exit(1)
+	return ""
+}
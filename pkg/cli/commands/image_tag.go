@@ -0,0 +1,72 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apcera/kurma/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ImageTagCmd = &cobra.Command{
+		Use:   "tag HASH NAME[:TAG]",
+		Short: "Tag an image so the name and tag resolve to it, moving the tag if it exists",
+		Run:   cmdImageTag,
+	}
+
+	ImageUntagCmd = &cobra.Command{
+		Use:   "untag NAME[:TAG]",
+		Short: "Remove a tag from an image",
+		Run:   cmdImageUntag,
+	}
+)
+
+func init() {
+	ImageCmd.AddCommand(ImageTagCmd)
+	ImageCmd.AddCommand(ImageUntagCmd)
+}
+
+func cmdImageTag(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Must specify the hash of the image and the name to tag it with.\n")
+		cmd.Help()
+		return
+	}
+
+	client := cli.GetClient()
+	hash := resolveImageHash(client, args[0])
+	name, tag := splitImageTag(args[1])
+	if err := client.TagImage(hash, name, tag); err != nil {
+		fmt.Printf("Failed to tag the image: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Tagged %s as %s:%s\n", getShortHash(hash), name, tag)
+}
+
+func cmdImageUntag(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Must specify the tag to remove.\n")
+		cmd.Help()
+		return
+	}
+
+	name, tag := splitImageTag(args[0])
+	if err := cli.GetClient().UntagImage(name, tag); err != nil {
+		fmt.Printf("Failed to remove the tag: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed tag %s:%s\n", name, tag)
+}
+
+// splitImageTag splits NAME[:TAG] into the name and the tag, which defaults to
+// "latest".
+func splitImageTag(s string) (string, string) {
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, "latest"
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/kurma/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+var (
+	LogsCmd = &cobra.Command{
+		Use:   "logs UUID [APP]",
+		Short: "Display the logs of an application within a pod",
+		Run:   cmdLogs,
+	}
+
+	logsFollow     bool
+	logsTail       int
+	logsSince      string
+	logsTimestamps bool
+	logsStager     bool
+)
+
+func init() {
+	cli.RootCmd.AddCommand(LogsCmd)
+	LogsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "continue streaming new log output")
+	LogsCmd.Flags().IntVarP(&logsTail, "tail", "", 0, "number of lines from the end of the log to show")
+	LogsCmd.Flags().StringVarP(&logsSince, "since", "", "", "only show logs since a timestamp (RFC3339) or relative duration (e.g. 10m)")
+	LogsCmd.Flags().BoolVarP(&logsTimestamps, "timestamps", "t", false, "show the timestamp of each line")
+	LogsCmd.Flags().BoolVarP(&logsStager, "stager", "", false, "show the pod stager's log rather than an application's")
+}
+
+func cmdLogs(cmd *cobra.Command, args []string) {
+	if len(args) == 0 || len(args) > 2 || (len(args) == 1) != logsStager {
+		fmt.Printf("Must specify the UUID of the pod and either an application name or --stager.\n")
+		cmd.Help()
+		return
+	}
+
+	req := &apiclient.ContainerLogsRequest{
+		UUID:       args[0],
+		Stager:     logsStager,
+		Follow:     logsFollow,
+		Tail:       logsTail,
+		Timestamps: logsTimestamps,
+	}
+	if len(args) == 2 {
+		req.AppName = args[1]
+	}
+
+	if logsSince != "" {
+		since, err := parseSince(logsSince)
+		if err != nil {
+			fmt.Printf("Invalid since value: %v\n", err)
+			os.Exit(1)
+		}
+		req.Since = since
+	}
+
+	body, err := cli.GetClient().ContainerLogs(req)
+	if err != nil {
+		fmt.Printf("Failed to retrieve logs: %v\n", err)
+		os.Exit(1)
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(os.Stdout, body); err != nil {
+		fmt.Printf("Failed reading logs: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseSince handles parsing the since value as either an absolute timestamp or
+// a duration relative to now.
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
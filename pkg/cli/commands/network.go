@@ -0,0 +1,227 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/apcera/kurma/pkg/cli"
+	"github.com/apcera/termtables"
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	NetworkCmd = &cobra.Command{
+		Use:   "network",
+		Short: "Manage the networks pods are attached to",
+	}
+
+	NetworkListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the configured networks",
+		Run:   cmdNetworkList,
+	}
+
+	NetworkShowCmd = &cobra.Command{
+		Use:   "show NAME",
+		Short: "Show a network and the pods it is provisioned on",
+		Run:   cmdNetworkShow,
+	}
+
+	NetworkAddCmd = &cobra.Command{
+		Use:   "add FILE",
+		Short: "Add a network from a JSON or YAML configuration file",
+		Run:   cmdNetworkAdd,
+	}
+
+	NetworkUpdateCmd = &cobra.Command{
+		Use:   "update FILE",
+		Short: "Replace the configuration of a network from a JSON or YAML file",
+		Run:   cmdNetworkUpdate,
+	}
+
+	NetworkRemoveCmd = &cobra.Command{
+		Use:   "remove NAME",
+		Short: "Remove a network which isn't provisioned on any pods",
+		Run:   cmdNetworkRemove,
+	}
+
+	NetworkConnectCmd = &cobra.Command{
+		Use:   "connect UUID NETWORK",
+		Short: "Attach a running pod to a network",
+		Run:   cmdNetworkConnect,
+	}
+
+	NetworkDisconnectCmd = &cobra.Command{
+		Use:   "disconnect UUID NETWORK",
+		Short: "Detach a running pod from a network",
+		Run:   cmdNetworkDisconnect,
+	}
+)
+
+func init() {
+	cli.RootCmd.AddCommand(NetworkCmd)
+	NetworkCmd.AddCommand(NetworkListCmd)
+	NetworkCmd.AddCommand(NetworkShowCmd)
+	NetworkCmd.AddCommand(NetworkAddCmd)
+	NetworkCmd.AddCommand(NetworkUpdateCmd)
+	NetworkCmd.AddCommand(NetworkRemoveCmd)
+	NetworkCmd.AddCommand(NetworkConnectCmd)
+	NetworkCmd.AddCommand(NetworkDisconnectCmd)
+}
+
+func cmdNetworkList(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		fmt.Printf("Invalid command options specified.\n")
+		os.Exit(1)
+	}
+
+	networks, err := cli.GetClient().ListNetworks()
+	if err != nil {
+		fmt.Printf("Failed to get list of networks: %v\n", err)
+		os.Exit(1)
+	}
+
+	table := termtables.CreateTable()
+	table.AddHeaders("Name", "Default", "Image", "Pods")
+	for _, network := range networks {
+		table.AddRow(network.Name, network.Default, getShortHash(network.Image), len(network.Pods))
+	}
+	fmt.Printf("%s", table.Render())
+}
+
+func cmdNetworkShow(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Must specify the name of the network to show.\n")
+		cmd.Help()
+		return
+	}
+
+	network, err := cli.GetClient().GetNetwork(args[0])
+	if err != nil {
+		fmt.Printf("Failed to retrieve network: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Network %s:\n\n", network.Name)
+
+	b, err := json.MarshalIndent(network, "", "  ")
+	if err != nil {
+		fmt.Printf("Failed to marshal network: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s\n", string(b))
+}
+
+func cmdNetworkAdd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Must specify the file with the network's configuration.\n")
+		cmd.Help()
+		return
+	}
+
+	config, err := readNetworkConfig(args[0])
+	if err != nil {
+		fmt.Printf("Failed to read the network configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	network, err := cli.GetClient().AddNetwork(config)
+	if err != nil {
+		fmt.Printf("Failed to add the network: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added network %q\n", network.Name)
+}
+
+func cmdNetworkUpdate(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Must specify the file with the network's configuration.\n")
+		cmd.Help()
+		return
+	}
+
+	config, err := readNetworkConfig(args[0])
+	if err != nil {
+		fmt.Printf("Failed to read the network configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	network, err := cli.GetClient().UpdateNetwork(config)
+	if err != nil {
+		fmt.Printf("Failed to update the network: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated network %q\n", network.Name)
+}
+
+func cmdNetworkRemove(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Must specify the name of the network to remove.\n")
+		cmd.Help()
+		return
+	}
+
+	if err := cli.GetClient().RemoveNetwork(args[0]); err != nil {
+		fmt.Printf("Failed to remove the network: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed network %q\n", args[0])
+}
+
+// readNetworkConfig reads a network's configuration, in the same form as the
+// podNetworks entries in the kurmad configuration, and returns it as JSON.
+func readNetworkConfig(filename string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(filename) {
+	case ".yml", ".yaml":
+		return yaml.YAMLToJSON(b)
+	default:
+		return b, nil
+	}
+}
+
+func cmdNetworkConnect(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Must specify the UUID of the pod and the network to attach it to.\n")
+		cmd.Help()
+		return
+	}
+
+	pod, err := cli.GetClient().AttachPodNetwork(args[0], args[1])
+	if err != nil {
+		fmt.Printf("Failed to attach the network: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, result := range pod.Networks {
+		if result.Name == args[1] && result.ContainerInterface != "" {
+			fmt.Printf("Attached pod %s to network %q on interface %s\n", pod.UUID, args[1], result.ContainerInterface)
+			return
+		}
+	}
+	fmt.Printf("Attached pod %s to network %q\n", pod.UUID, args[1])
+}
+
+func cmdNetworkDisconnect(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Printf("Must specify the UUID of the pod and the network to detach it from.\n")
+		cmd.Help()
+		return
+	}
+
+	pod, err := cli.GetClient().DetachPodNetwork(args[0], args[1])
+	if err != nil {
+		fmt.Printf("Failed to detach the network: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Detached pod %s from network %q\n", pod.UUID, args[1])
+}
@@ -5,6 +5,7 @@ package commands
 import (
 	"fmt"
 	"os"
+	"sort"
 
 	"github.com/apcera/kurma/pkg/cli"
 	"github.com/apcera/termtables"
@@ -14,8 +15,8 @@ import (
 
 var (
 	StatusCmd = &cobra.Command{
-		Use:   "status",
-		Short: "Displays status information about the host.",
+		Use:   "status [UUID]",
+		Short: "Displays status information about the host or a pod.",
 		Run:   cmdStatus,
 	}
 )
@@ -25,7 +26,12 @@ func init() {
 }
 
 func cmdStatus(cmd *cobra.Command, args []string) {
-	if len(args) > 0 {
+	switch len(args) {
+	case 0:
+	case 1:
+		podStatus(args[0])
+		return
+	default:
 		fmt.Printf("Invalid command options specified.\n")
 		os.Exit(1)
 	}
@@ -63,3 +69,34 @@ func cmdStatus(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Host Information\n\n%s", table.Render())
 }
+
+// podStatus displays the status of each of the applications within the
+// specified pod.
+func podStatus(uuid string) {
+	status, err := cli.GetClient().GetPodStatus(uuid)
+	if err != nil {
+		fmt.Printf("Failed to get pod status: %v\n", err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(status.Apps))
+	for name := range status.Apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// create the table
+	table := termtables.CreateTable()
+
+	table.AddHeaders("App", "State", "PID", "Exit Code", "Exit Reason", "Restarts")
+	for _, name := range names {
+		app := status.Apps[name]
+		if app.Exited {
+			table.AddRow(name, "exited", "", app.ExitCode, app.ExitReason, app.RestartCount)
+		} else {
+			table.AddRow(name, "running", app.Pid, "", "", app.RestartCount)
+		}
+	}
+
+	fmt.Printf("Pod %s (%s)\n\n%s", status.UUID, status.State, table.Render())
+}
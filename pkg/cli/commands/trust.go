@@ -0,0 +1,121 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/apcera/kurma/pkg/cli"
+	"github.com/apcera/termtables"
+	"github.com/spf13/cobra"
+)
+
+var (
+	TrustCmd = &cobra.Command{
+		Use:   "trust",
+		Short: "Manage the keys trusted to sign images",
+	}
+
+	TrustAddCmd = &cobra.Command{
+		Use:   "add FILE",
+		Short: "Trust an armored OpenPGP public key for a prefix of image names",
+		Run:   cmdTrustAdd,
+	}
+
+	TrustListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the trusted keys",
+		Run:   cmdTrustList,
+	}
+
+	TrustRemoveCmd = &cobra.Command{
+		Use:   "remove FINGERPRINT",
+		Short: "Stop trusting a key",
+		Run:   cmdTrustRemove,
+	}
+
+	trustPrefix string
+	trustRoot   bool
+)
+
+func init() {
+	cli.RootCmd.AddCommand(TrustCmd)
+	TrustCmd.AddCommand(TrustAddCmd)
+	TrustCmd.AddCommand(TrustListCmd)
+	TrustCmd.AddCommand(TrustRemoveCmd)
+	TrustAddCmd.Flags().StringVarP(&trustPrefix, "prefix", "", "", "image name prefix the key is trusted for")
+	TrustAddCmd.Flags().BoolVarP(&trustRoot, "root", "", false, "trust the key for all images")
+}
+
+func cmdTrustAdd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 || (trustPrefix == "") == !trustRoot {
+		fmt.Printf("Invalid command options specified, a file and either --prefix or --root must be given.\n")
+		cmd.Help()
+		return
+	}
+
+	b, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fmt.Printf("Failed to read the key: %v\n", err)
+		os.Exit(1)
+	}
+
+	keys, err := cli.GetClient().AddTrustedKey(trustPrefix, string(b))
+	if err != nil {
+		fmt.Printf("Failed to add the key: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, key := range keys {
+		fmt.Printf("Trusted key %s for %s\n", key.Fingerprint, displayPrefix(key.Prefix))
+	}
+}
+
+func cmdTrustList(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		fmt.Printf("Invalid command options specified.\n")
+		os.Exit(1)
+	}
+
+	keys, err := cli.GetClient().ListTrustedKeys()
+	if err != nil {
+		fmt.Printf("Failed to get the list of trusted keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	table := termtables.CreateTable()
+	table.AddHeaders("Fingerprint", "Prefix", "Identities", "Source")
+	for _, key := range keys {
+		source := "runtime"
+		if key.System {
+			source = "config"
+		}
+		table.AddRow(key.Fingerprint, displayPrefix(key.Prefix), strings.Join(key.Identities, ", "), source)
+	}
+	fmt.Printf("%s", table.Render())
+}
+
+func cmdTrustRemove(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Invalid command options specified.\n")
+		cmd.Help()
+		return
+	}
+
+	if err := cli.GetClient().RemoveTrustedKey(args[0]); err != nil {
+		fmt.Printf("Failed to remove the key: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed key %s\n", args[0])
+}
+
+func displayPrefix(prefix string) string {
+	if prefix == "" {
+		return "(all images)"
+	}
+	return prefix
+}
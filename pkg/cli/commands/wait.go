@@ -0,0 +1,60 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/apcera/kurma/pkg/cli"
+	"github.com/spf13/cobra"
+)
+
+var (
+	WaitCmd = &cobra.Command{
+		Use:   "wait UUID",
+		Short: "Wait for a pod to exit and exit with its exit code",
+		Run:   cmdWait,
+	}
+)
+
+func init() {
+	cli.RootCmd.AddCommand(WaitCmd)
+}
+
+func cmdWait(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Invalid command options specified.\n")
+		cmd.Help()
+		return
+	}
+
+	waitForPod(args[0])
+}
+
+// waitForPod blocks until the specified pod has exited, then displays the exit
+// code of each of its applications and exits with the pod's exit code.
+func waitForPod(uuid string) {
+	status, err := cli.GetClient().WaitPod(uuid)
+	if err != nil {
+		fmt.Printf("Failed to wait on the pod: %v\n", err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(status.Apps))
+	for name := range status.Apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("App %s exited with code %d\n", name, status.Apps[name].ExitCode)
+	}
+
+	if status.ExitCode == nil {
+		fmt.Printf("Pod %s stopped without reporting an exit code\n", status.UUID)
+		os.Exit(1)
+	}
+	fmt.Printf("Pod %s exited with code %d\n", status.UUID, *status.ExitCode)
+	os.Exit(*status.ExitCode)
+}
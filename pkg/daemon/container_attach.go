@@ -0,0 +1,48 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/util/wsconn"
+)
+
+func (s *Server) containerAttachRequest(w http.ResponseWriter, req *http.Request) {
+	ws, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		s.log.Errorf("Failed to upgrade tunnel connection: %v", err)
+		http.Error(w, "Failed to setup request", 500)
+		return
+	}
+
+	// parse the inbound request
+	var attachRequest *apiclient.ContainerAttachRequest
+	if err := ws.ReadJSON(&attachRequest); err != nil {
+		s.log.Errorf("Failed to unmarshal attach request: %v", err)
+		http.Error(w, "Failed to upgrade socket", 500)
+		return
+	}
+
+	// get the pod
+	pod := s.options.PodManager.Pod(attachRequest.UUID)
+	if pod == nil {
+		http.Error(w, "Not Found", 404)
+		return
+	}
+
+	// create the websocket connection
+	wsc := wsconn.NewWebsocketConnection(ws)
+	defer wsc.Close()
+
+	// attach to the app
+	process, err := pod.Attach(attachRequest.AppName, wsc, wsc)
+	if err != nil {
+		s.log.Errorf("Failed to attach to app: %v", err)
+		http.Error(w, "Failed to attach to app", 500)
+		return
+	}
+	process.Wait()
+	s.log.Debugf("Attach request finished")
+}
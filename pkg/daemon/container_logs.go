@@ -0,0 +1,78 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/kurma/pkg/backend"
+)
+
+func (s *Server) containerLogsRequest(w http.ResponseWriter, req *http.Request) {
+	logsRequest, err := apiclient.DecodeLogsRequest(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	pod := s.options.PodManager.Pod(logsRequest.UUID)
+	if pod == nil {
+		http.Error(w, "Not Found", 404)
+		return
+	}
+
+	appName := logsRequest.AppName
+	if logsRequest.Stager {
+		appName = ""
+	}
+	options := &backend.LogOptions{
+		Follow:     logsRequest.Follow,
+		Tail:       logsRequest.Tail,
+		Since:      logsRequest.Since,
+		Timestamps: logsRequest.Timestamps,
+	}
+
+	// stop streaming when the client goes away
+	var cancel <-chan bool
+	if cn, ok := w.(http.CloseNotifier); ok {
+		cancel = cn.CloseNotify()
+	}
+	cancelch := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-cancel:
+			close(cancelch)
+		case <-done:
+		}
+	}()
+
+	fw := &flushWriter{w: w}
+	if err := pod.Logs(appName, options, fw, cancelch); err != nil {
+		s.log.Errorf("Failed to retrieve logs: %v", err)
+		if !fw.written {
+			http.Error(w, err.Error(), 500)
+		}
+		return
+	}
+	s.log.Debugf("Logs request finished")
+}
+
+// flushWriter flushes each write out to the client so that followed logs are
+// streamed as they're written. It also tracks whether anything has been written
+// yet so errors can still be returned with a proper status.
+type flushWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	fw.written = true
+	n, err := fw.w.Write(p)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
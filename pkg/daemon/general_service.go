@@ -25,6 +25,9 @@ func (s *Server) infoRequest(w http.ResponseWriter, req *http.Request) {
 		KurmaVersion:  apiclient.KurmaVersion,
 		KernelVersion: misc.GetKernelVersion(),
 	}
+	if s.options.FetchOptions != nil {
+		hostInfo.FetchPolicy = s.options.FetchOptions.Policy
+	}
 
 	hostname, err := os.Hostname()
 	if err != nil {
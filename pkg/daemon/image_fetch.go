@@ -0,0 +1,160 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/pkg/aciremote"
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/util/uuid"
+	"github.com/appc/spec/schema/types"
+)
+
+// fetchRetention is how long the progress of a fetch is kept after it has
+// finished, so that clients can still retrieve the result.
+var fetchRetention = 5 * time.Minute
+
+// imageFetch tracks an image being fetched on behalf of a client.
+type imageFetch struct {
+	lock    sync.Mutex
+	updates []*apiclient.ImageFetchProgress
+	changed chan struct{}
+	done    bool
+}
+
+// add records the update and wakes up anything watching the fetch. The final
+// update marks the fetch as done.
+func (f *imageFetch) add(update *apiclient.ImageFetchProgress, final bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.updates = append(f.updates, update)
+	f.done = final
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// since returns the updates after the first n, whether the fetch is done, and
+// a channel which is closed on the next update.
+func (f *imageFetch) since(n int) ([]*apiclient.ImageFetchProgress, bool, <-chan struct{}) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.updates[n:], f.done, f.changed
+}
+
+func (s *ImageService) Fetch(r *http.Request, req *apiclient.ImageFetchRequest, resp *apiclient.ImageFetchResponse) error {
+	if req.URI == "" {
+		return fmt.Errorf("no image URI was specified")
+	}
+
+	opts := &aciremote.FetchOptions{}
+	if s.server.options.FetchOptions != nil {
+		*opts = *s.server.options.FetchOptions
+	}
+	if err := opts.Policy.Check(req.URI); err != nil {
+		return err
+	}
+	if req.KeepDockerLayers != nil {
+		opts.KeepDockerLayers = *req.KeepDockerLayers
+	}
+	opts.Labels = make(map[types.ACIdentifier]string, len(req.Labels))
+	for k, v := range req.Labels {
+		name, err := types.NewACIdentifier(k)
+		if err != nil {
+			return fmt.Errorf("invalid label %q: %v", k, err)
+		}
+		opts.Labels[*name] = v
+	}
+
+	f := &imageFetch{changed: make(chan struct{})}
+	opts.Progress = func(p aciremote.Progress) {
+		f.add(&apiclient.ImageFetchProgress{
+			Stage:  p.Stage,
+			Bytes:  p.Bytes,
+			Total:  p.Total,
+			Layers: p.Layers,
+		}, false)
+	}
+
+	id := uuid.Variant4().String()
+	s.server.fetchesLock.Lock()
+	s.server.fetches[id] = f
+	s.server.fetchesLock.Unlock()
+
+	uri := req.URI
+	go func() {
+		s.fetch(uri, opts, f)
+		time.AfterFunc(fetchRetention, func() {
+			s.server.fetchesLock.Lock()
+			delete(s.server.fetches, id)
+			s.server.fetchesLock.Unlock()
+		})
+	}()
+
+	resp.ID = id
+	return nil
+}
+
+// fetch loads the image and records the result as the final update of the
+// fetch.
+func (s *ImageService) fetch(uri string, opts *aciremote.FetchOptions, f *imageFetch) {
+	imageManager := s.server.options.ImageManager
+	hash, manifest, err := aciremote.LoadImage(uri, opts, imageManager)
+	if err != nil {
+		s.server.log.Errorf("Failed to fetch image %q: %v", uri, err)
+		f.add(&apiclient.ImageFetchProgress{Error: err.Error()}, true)
+		return
+	}
+
+	image := &apiclient.Image{Hash: hash, Manifest: manifest}
+	if imageSize, err := imageManager.GetImageSize(hash); err == nil {
+		image.Size = imageSize.Size
+		image.CompressedSize = imageSize.CompressedSize
+		image.TotalSize = imageSize.TotalSize
+	}
+	s.server.log.Infof("Fetched image %q as %s", uri, hash)
+	f.add(&apiclient.ImageFetchProgress{Image: image}, true)
+}
+
+// imageFetchRequest streams the progress of a fetch as JSON encoded updates,
+// starting from the beginning of the fetch, until it finishes or the client
+// goes away.
+func (s *Server) imageFetchRequest(w http.ResponseWriter, req *http.Request) {
+	s.fetchesLock.Lock()
+	f := s.fetches[req.URL.Query().Get("id")]
+	s.fetchesLock.Unlock()
+	if f == nil {
+		http.Error(w, "Not Found", 404)
+		return
+	}
+
+	var cancel <-chan bool
+	if cn, ok := w.(http.CloseNotifier); ok {
+		cancel = cn.CloseNotify()
+	}
+
+	fw := &flushWriter{w: w}
+	enc := json.NewEncoder(fw)
+	for n := 0; ; {
+		updates, done, changed := f.since(n)
+		for _, update := range updates {
+			if err := enc.Encode(update); err != nil {
+				return
+			}
+		}
+		n += len(updates)
+		if done {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-cancel:
+			return
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+)
+
+// Export returns the images to export, which is the image itself and, if
+// requested, its dependencies ahead of it.
+func (s *ImageService) Export(r *http.Request, req *apiclient.ImageExportRequest, resp *apiclient.ImageExportResponse) error {
+	imageManager := s.server.options.ImageManager
+	if imageManager.GetImage(req.Hash) == nil {
+		return fmt.Errorf("specified image not found")
+	}
+	if !req.Dependencies {
+		resp.Images = []string{req.Hash}
+		return nil
+	}
+
+	tree, err := imageManager.ResolveTree(req.Hash)
+	if err != nil {
+		return err
+	}
+	resp.Images = make([]string, 0, len(tree.Order))
+	for i := len(tree.Order) - 1; i >= 0; i-- {
+		resp.Images = append(resp.Images, tree.Order[i])
+	}
+	return nil
+}
+
+// imageExportRequest streams the image as an ACI.
+func (s *Server) imageExportRequest(w http.ResponseWriter, req *http.Request) {
+	hash := req.URL.Query().Get("hash")
+	if s.options.ImageManager.GetImage(hash) == nil {
+		http.Error(w, "Not Found", 404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err := s.options.ImageManager.ExportImage(hash, w); err != nil {
+		s.log.Errorf("Failed to export image %s: %v", hash, err)
+	}
+}
+
+// Files lists the files within a directory of the image's root filesystem, or
+// describes the file if the path isn't a directory.
+func (s *ImageService) Files(r *http.Request, req *apiclient.ImageFilesRequest, resp *apiclient.ImageFilesResponse) error {
+	p, filename, err := s.server.imageFilePath(req.Hash, req.Path)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Lstat(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read %q: %v", p, err)
+	}
+	if !fi.IsDir() {
+		file, err := imageFile(p, filename, fi)
+		if err != nil {
+			return err
+		}
+		resp.Files = []*apiclient.ImageFile{file}
+		return nil
+	}
+
+	fis, err := ioutil.ReadDir(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read %q: %v", p, err)
+	}
+	resp.Files = make([]*apiclient.ImageFile, 0, len(fis))
+	for _, fi := range fis {
+		file, err := imageFile(path.Join(p, fi.Name()), filepath.Join(filename, fi.Name()), fi)
+		if err != nil {
+			return err
+		}
+		resp.Files = append(resp.Files, file)
+	}
+	return nil
+}
+
+// imageFilesRequest streams the contents of a file within the image's root
+// filesystem.
+func (s *Server) imageFilesRequest(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	p, filename, err := s.imageFilePath(query.Get("hash"), query.Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	fi, err := os.Lstat(filename)
+	if err != nil {
+		http.Error(w, "Not Found", 404)
+		return
+	}
+	if !fi.Mode().IsRegular() {
+		http.Error(w, fmt.Sprintf("%q is not a regular file", p), 400)
+		return
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err := io.Copy(w, f); err != nil {
+		s.log.Errorf("Failed to read %q from image: %v", p, err)
+	}
+}
+
+// imageFilePath returns the cleaned path within the image's root filesystem
+// along with where it is on disk. Symlinks are not followed, so any path
+// within a symlinked directory is rejected rather than possibly resolving to
+// somewhere outside of the image.
+func (s *Server) imageFilePath(hash, p string) (string, string, error) {
+	if s.options.ImageManager.GetImage(hash) == nil {
+		return "", "", fmt.Errorf("specified image not found")
+	}
+	tree, err := s.options.ImageManager.ResolveTree(hash)
+	if err != nil {
+		return "", "", err
+	}
+	rootfs := tree.Paths[hash]
+
+	p = path.Clean("/" + p)
+	filename := rootfs
+	for _, part := range strings.Split(strings.TrimPrefix(path.Dir(p), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		filename = filepath.Join(filename, part)
+		fi, err := os.Lstat(filename)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read %q: %v", p, err)
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", "", fmt.Errorf("%q is within a symlink", p)
+		}
+	}
+	return p, filepath.Join(rootfs, p), nil
+}
+
+// imageFile describes the file at the path within the image.
+func imageFile(p, filename string, fi os.FileInfo) (*apiclient.ImageFile, error) {
+	file := &apiclient.ImageFile{
+		Path:    p,
+		Mode:    fi.Mode(),
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read symlink %q: %v", p, err)
+		}
+		file.Target = target
+	}
+	return file, nil
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 
 	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/kurma/pkg/backend"
 )
 
 type ImageService struct {
@@ -30,6 +32,7 @@ func (s *Server) imageCreateRequest(w http.ResponseWriter, req *http.Request) {
 
 func (s *ImageService) List(r *http.Request, args *apiclient.None, resp *apiclient.ImageListResponse) error {
 	images := s.server.options.ImageManager.ListImages()
+	tags := imageTags(s.server.options.ImageManager)
 	resp.Images = make([]*apiclient.Image, 0, len(images))
 	for hash, image := range images {
 		imageSize, err := s.server.options.ImageManager.GetImageSize(hash)
@@ -37,7 +40,14 @@ func (s *ImageService) List(r *http.Request, args *apiclient.None, resp *apiclie
 			s.server.log.Warnf("Failed to get image size %s: %v", hash, err)
 			continue
 		}
-		resp.Images = append(resp.Images, &apiclient.Image{Hash: hash, Manifest: image, Size: imageSize})
+		resp.Images = append(resp.Images, &apiclient.Image{
+			Hash:           hash,
+			Manifest:       image,
+			Size:           imageSize.Size,
+			CompressedSize: imageSize.CompressedSize,
+			TotalSize:      imageSize.TotalSize,
+			Tags:           tags[hash],
+		})
 	}
 	return nil
 }
@@ -54,7 +64,14 @@ func (s *ImageService) Get(r *http.Request, hash *string, resp *apiclient.ImageR
 	if err != nil {
 		return err
 	}
-	resp.Image = &apiclient.Image{Hash: *hash, Manifest: image, Size: imageSize}
+	resp.Image = &apiclient.Image{
+		Hash:           *hash,
+		Manifest:       image,
+		Size:           imageSize.Size,
+		CompressedSize: imageSize.CompressedSize,
+		TotalSize:      imageSize.TotalSize,
+		Tags:           imageTags(s.server.options.ImageManager)[*hash],
+	}
 	return nil
 }
 
@@ -64,3 +81,29 @@ func (s *ImageService) Delete(r *http.Request, hash *string, resp *apiclient.Ima
 	}
 	return s.server.options.ImageManager.DeleteImage(*hash)
 }
+
+func (s *ImageService) Prune(r *http.Request, req *apiclient.ImagePruneRequest, resp *apiclient.ImagePruneResponse) error {
+	images, err := s.server.options.ImageManager.Prune(req.DryRun)
+	resp.Images = images
+	return err
+}
+
+func (s *ImageService) Tag(r *http.Request, req *apiclient.ImageTagRequest, resp *apiclient.None) error {
+	return s.server.options.ImageManager.TagImage(req.Hash, req.Name, req.Tag)
+}
+
+func (s *ImageService) Untag(r *http.Request, req *apiclient.ImageTagRequest, resp *apiclient.None) error {
+	return s.server.options.ImageManager.UntagImage(req.Name, req.Tag)
+}
+
+// imageTags returns the sorted tags on each image, keyed by the image hash.
+func imageTags(imageManager backend.ImageManager) map[string][]string {
+	tags := make(map[string][]string)
+	for tag, hash := range imageManager.ListTags() {
+		tags[hash] = append(tags[hash], tag)
+	}
+	for _, t := range tags {
+		sort.Strings(t)
+	}
+	return tags
+}
@@ -0,0 +1,196 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/apcera/kurma/pkg/aciremote"
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/kurma/pkg/backend"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+
+	ntypes "github.com/apcera/kurma/pkg/networkmanager/types"
+)
+
+type NetworkService struct {
+	server *Server
+}
+
+func (s *NetworkService) networkManager() (backend.NetworkManager, error) {
+	if s.server.options.NetworkManager == nil {
+		return nil, fmt.Errorf("networking is not available")
+	}
+	return s.server.options.NetworkManager, nil
+}
+
+func (s *NetworkService) List(r *http.Request, args *apiclient.None, resp *apiclient.NetworkListResponse) error {
+	nm, err := s.networkManager()
+	if err != nil {
+		return err
+	}
+	networks := nm.Networks()
+	resp.Networks = make([]*apiclient.Network, 0, len(networks))
+	for _, network := range networks {
+		resp.Networks = append(resp.Networks, s.exportNetwork(network))
+	}
+	return nil
+}
+
+func (s *NetworkService) Get(r *http.Request, name *string, resp *apiclient.NetworkResponse) error {
+	if name == nil {
+		return fmt.Errorf("no network name was specified")
+	}
+	nm, err := s.networkManager()
+	if err != nil {
+		return err
+	}
+	resp.Network = s.findNetwork(nm, *name)
+	if resp.Network == nil {
+		return fmt.Errorf("specified network not found")
+	}
+	return nil
+}
+
+func (s *NetworkService) Add(r *http.Request, req *apiclient.NetworkConfigRequest, resp *apiclient.NetworkResponse) error {
+	nm, err := s.networkManager()
+	if err != nil {
+		return err
+	}
+	driver, err := s.loadDriver(req.Config)
+	if err != nil {
+		return err
+	}
+	if err := nm.AddNetwork(driver); err != nil {
+		return err
+	}
+
+	name := driver.Configuration.Name
+	s.server.options.ImageManager.AddReference(driver.Image.ID.String(), networkOwner(name))
+	s.server.log.Infof("Added network %q", name)
+	resp.Network = s.findNetwork(nm, name)
+	return nil
+}
+
+func (s *NetworkService) Update(r *http.Request, req *apiclient.NetworkConfigRequest, resp *apiclient.NetworkResponse) error {
+	nm, err := s.networkManager()
+	if err != nil {
+		return err
+	}
+	driver, err := s.loadDriver(req.Config)
+	if err != nil {
+		return err
+	}
+	if err := nm.UpdateNetwork(driver); err != nil {
+		return err
+	}
+
+	// move the network's reference over to the image it is now using
+	name := driver.Configuration.Name
+	s.server.options.ImageManager.RemoveReferences(networkOwner(name))
+	s.server.options.ImageManager.AddReference(driver.Image.ID.String(), networkOwner(name))
+	s.server.log.Infof("Updated network %q", name)
+	resp.Network = s.findNetwork(nm, name)
+	return nil
+}
+
+func (s *NetworkService) Remove(r *http.Request, name *string, resp *apiclient.None) error {
+	if name == nil {
+		return fmt.Errorf("no network name was specified")
+	}
+	nm, err := s.networkManager()
+	if err != nil {
+		return err
+	}
+	if err := nm.RemoveNetwork(*name); err != nil {
+		return err
+	}
+	s.server.options.ImageManager.RemoveReferences(networkOwner(*name))
+	s.server.log.Infof("Removed network %q", *name)
+	return nil
+}
+
+// loadDriver parses the network's configuration and loads the image for its
+// plugin, ensuring the image is trusted.
+func (s *NetworkService) loadDriver(config json.RawMessage) (*backend.NetworkDriver, error) {
+	var conf *ntypes.NetConf
+	if err := json.Unmarshal(config, &conf); err != nil {
+		return nil, fmt.Errorf("failed to parse the network configuration: %v", err)
+	}
+	if conf == nil || conf.Name == "" {
+		return nil, fmt.Errorf("the network configuration must specify a name")
+	}
+	if conf.ACI == "" {
+		return nil, fmt.Errorf("the network configuration must specify an aci")
+	}
+
+	imageManager := s.server.options.ImageManager
+	hash, _, err := aciremote.LoadImage(conf.ACI, s.server.options.FetchOptions, imageManager)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image for network %q: %v", conf.Name, err)
+	}
+	if err := s.server.options.Keystore.CheckImage(hash, imageManager); err != nil {
+		return nil, fmt.Errorf("refusing to use image for network %q: %v", conf.Name, err)
+	}
+	imageID, err := types.NewHash(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate image hash for %q: %v", conf.Name, err)
+	}
+
+	return &backend.NetworkDriver{
+		Image:         schema.RuntimeImage{ID: *imageID},
+		Configuration: conf,
+	}, nil
+}
+
+// findNetwork returns the named network, or nil if it isn't configured.
+func (s *NetworkService) findNetwork(nm backend.NetworkManager, name string) *apiclient.Network {
+	for _, network := range nm.Networks() {
+		if network.Driver.Configuration.Name == name {
+			return s.exportNetwork(network)
+		}
+	}
+	return nil
+}
+
+func (s *NetworkService) exportNetwork(network *backend.Network) *apiclient.Network {
+	conf := network.Driver.Configuration
+	n := &apiclient.Network{
+		Name:    conf.Name,
+		ACI:     conf.ACI,
+		Image:   network.Driver.Image.ID.String(),
+		Default: conf.Default,
+		Config:  conf.RawConfig,
+		Pods:    make([]*apiclient.NetworkPod, 0, len(network.PodInterfaces)),
+	}
+	for uuid, iface := range network.PodInterfaces {
+		pod := &apiclient.NetworkPod{UUID: uuid, Interface: iface}
+		if p := s.server.options.PodManager.Pod(uuid); p != nil {
+			pod.Name = p.Name()
+		}
+		n.Pods = append(n.Pods, pod)
+	}
+	sort.Sort(networkPodsByName(n.Pods))
+	return n
+}
+
+// networkOwner returns the owner of the reference a network holds on its
+// plugin's image.
+func networkOwner(name string) string {
+	return fmt.Sprintf("network %q", name)
+}
+
+type networkPodsByName []*apiclient.NetworkPod
+
+func (p networkPodsByName) Len() int      { return len(p) }
+func (p networkPodsByName) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p networkPodsByName) Less(i, j int) bool {
+	if p[i].Name != p[j].Name {
+		return p[i].Name < p[j].Name
+	}
+	return p[i].UUID < p[j].UUID
+}
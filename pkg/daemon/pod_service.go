@@ -15,7 +15,12 @@ type PodService struct {
 }
 
 func (s *PodService) Create(r *http.Request, req *apiclient.PodCreateRequest, resp *apiclient.PodResponse) error {
-	c, err := s.server.options.PodManager.Create(req.Name, req.Pod, nil)
+	options := &backend.PodOptions{
+		StagerHash:   req.StagerImageHash,
+		Networks:     req.Networks,
+		PortMappings: req.PortMappings,
+	}
+	c, err := s.server.options.PodManager.Create(req.Name, req.Pod, options)
 	if err != nil {
 		return err
 	}
@@ -44,6 +49,43 @@ func (s *PodService) Get(r *http.Request, uuid *string, resp *apiclient.PodRespo
 	return nil
 }
 
+func (s *PodService) Status(r *http.Request, uuid *string, resp *apiclient.PodStatusResponse) error {
+	if uuid == nil {
+		return fmt.Errorf("no pod UUID was specified")
+	}
+	pod := s.server.options.PodManager.Pod(*uuid)
+	if pod == nil {
+		return fmt.Errorf("specified pod was not found")
+	}
+	apps, err := pod.AppStatus()
+	if err != nil {
+		return err
+	}
+	resp.Status = exportPodStatus(pod, apps)
+	return nil
+}
+
+func (s *PodService) Wait(r *http.Request, uuid *string, resp *apiclient.PodStatusResponse) error {
+	if uuid == nil {
+		return fmt.Errorf("no pod UUID was specified")
+	}
+	pod := s.server.options.PodManager.Pod(*uuid)
+	if pod == nil {
+		return fmt.Errorf("specified pod was not found")
+	}
+	pod.Wait()
+	if pod.State() == backend.ERRORED {
+		return fmt.Errorf("pod failed to start: %v", pod.StartupError())
+	}
+
+	apps, err := pod.AppStatus()
+	if err != nil {
+		return err
+	}
+	resp.Status = exportPodStatus(pod, apps)
+	return nil
+}
+
 func (s *PodService) Destroy(r *http.Request, uuid *string, ret *apiclient.None) error {
 	if uuid == nil {
 		return fmt.Errorf("no container UUID was specified")
@@ -55,12 +97,76 @@ func (s *PodService) Destroy(r *http.Request, uuid *string, ret *apiclient.None)
 	return pod.Stop()
 }
 
+func (s *PodService) CheckNetworks(r *http.Request, uuid *string, ret *apiclient.None) error {
+	if uuid == nil {
+		return fmt.Errorf("no pod UUID was specified")
+	}
+	pod := s.server.options.PodManager.Pod(*uuid)
+	if pod == nil {
+		return fmt.Errorf("specified pod was not found")
+	}
+	if s.server.options.NetworkManager == nil {
+		return fmt.Errorf("networking is not available")
+	}
+	return s.server.options.NetworkManager.Check(pod)
+}
+
+func (s *PodService) AttachNetwork(r *http.Request, req *apiclient.PodNetworkRequest, resp *apiclient.PodResponse) error {
+	pod := s.server.options.PodManager.Pod(req.UUID)
+	if pod == nil {
+		return fmt.Errorf("specified pod was not found")
+	}
+	if err := pod.AttachNetwork(req.Network); err != nil {
+		return err
+	}
+	resp.Pod = exportPod(pod)
+	return nil
+}
+
+func (s *PodService) DetachNetwork(r *http.Request, req *apiclient.PodNetworkRequest, resp *apiclient.PodResponse) error {
+	pod := s.server.options.PodManager.Pod(req.UUID)
+	if pod == nil {
+		return fmt.Errorf("specified pod was not found")
+	}
+	if err := pod.DetachNetwork(req.Network); err != nil {
+		return err
+	}
+	resp.Pod = exportPod(pod)
+	return nil
+}
+
 func exportPod(c backend.Pod) *apiclient.Pod {
-	return &apiclient.Pod{
-		UUID:     c.UUID(),
-		Name:     c.Name(),
-		Pod:      c.PodManifest(),
-		Networks: c.Networks(),
-		State:    apiclient.State(c.State().String()),
+	pod := &apiclient.Pod{
+		UUID:         c.UUID(),
+		Name:         c.Name(),
+		Pod:          c.PodManifest(),
+		Networks:     c.Networks(),
+		PortMappings: c.PortMappings(),
+		State:        apiclient.State(c.State().String()),
+	}
+	if err := c.StartupError(); err != nil {
+		pod.Error = err.Error()
+	}
+	return pod
+}
+
+func exportPodStatus(pod backend.Pod, apps map[string]*backend.AppStatus) *apiclient.PodStatus {
+	status := &apiclient.PodStatus{
+		UUID:  pod.UUID(),
+		State: apiclient.State(pod.State().String()),
+		Apps:  make(map[string]*apiclient.AppStatus, len(apps)),
+	}
+	if exitCode, err := pod.ExitCode(); err == nil {
+		status.ExitCode = &exitCode
+	}
+	for name, app := range apps {
+		status.Apps[name] = &apiclient.AppStatus{
+			Pid:          app.Pid,
+			Exited:       app.Exited,
+			ExitCode:     app.ExitCode,
+			ExitReason:   app.ExitReason,
+			RestartCount: app.RestartCount,
+		}
 	}
+	return status
 }
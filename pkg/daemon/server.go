@@ -7,8 +7,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 
+	"github.com/apcera/kurma/pkg/aciremote"
 	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/keystore"
 	"github.com/apcera/logray"
 	"github.com/gorilla/mux"
 	rpc "github.com/gorilla/rpc/v2"
@@ -20,6 +23,9 @@ import (
 type Options struct {
 	ImageManager         backend.ImageManager
 	PodManager           backend.PodManager
+	NetworkManager       backend.NetworkManager
+	Keystore             *keystore.Keystore
+	FetchOptions         *aciremote.FetchOptions
 	SocketRemoveIfExists bool
 	SocketFile           string
 	SocketGroup          *int
@@ -31,6 +37,9 @@ type Options struct {
 type Server struct {
 	log     *logray.Logger
 	options *Options
+
+	fetches     map[string]*imageFetch
+	fetchesLock sync.Mutex
 }
 
 // New creates and returns a new Server object with the provided Options as
@@ -39,6 +48,7 @@ func New(options *Options) *Server {
 	s := &Server{
 		log:     logray.New(),
 		options: options,
+		fetches: make(map[string]*imageFetch),
 	}
 	return s
 }
@@ -79,12 +89,19 @@ func (s *Server) Start() error {
 	svr.RegisterCodec(json2.NewCodec(), "application/json")
 	svr.RegisterService(&PodService{server: s}, "Pods")
 	svr.RegisterService(&ImageService{server: s}, "Images")
+	svr.RegisterService(&TrustService{server: s}, "Trust")
+	svr.RegisterService(&NetworkService{server: s}, "Networks")
 
 	router := mux.NewRouter()
 	router.Handle("/rpc", svr)
 	router.HandleFunc("/info", s.infoRequest).Methods("GET")
+	router.HandleFunc("/containers/attach", s.containerAttachRequest).Methods("GET")
 	router.HandleFunc("/containers/enter", s.containerEnterRequest).Methods("GET")
+	router.HandleFunc("/containers/logs", s.containerLogsRequest).Methods("GET")
 	router.HandleFunc("/images/create", s.imageCreateRequest).Methods("POST")
+	router.HandleFunc("/images/fetch", s.imageFetchRequest).Methods("GET")
+	router.HandleFunc("/images/export", s.imageExportRequest).Methods("GET")
+	router.HandleFunc("/images/files", s.imageFilesRequest).Methods("GET")
 
 	s.log.Debug("Server is ready")
 	go func() {
@@ -0,0 +1,73 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/apcera/kurma/pkg/apiclient"
+	"github.com/apcera/kurma/pkg/keystore"
+)
+
+type TrustService struct {
+	server *Server
+}
+
+func (s *TrustService) keystore() (*keystore.Keystore, error) {
+	if s.server.options.Keystore == nil {
+		return nil, fmt.Errorf("no keystore has been configured")
+	}
+	return s.server.options.Keystore, nil
+}
+
+func (s *TrustService) Add(r *http.Request, req *apiclient.TrustAddRequest, resp *apiclient.TrustListResponse) error {
+	ks, err := s.keystore()
+	if err != nil {
+		return err
+	}
+	keys, err := ks.AddKey(req.Prefix, strings.NewReader(req.Key))
+	if err != nil {
+		return err
+	}
+	resp.Keys = convertKeys(keys)
+	return nil
+}
+
+func (s *TrustService) List(r *http.Request, args *apiclient.None, resp *apiclient.TrustListResponse) error {
+	ks, err := s.keystore()
+	if err != nil {
+		return err
+	}
+	keys, err := ks.Keys()
+	if err != nil {
+		return err
+	}
+	resp.Keys = convertKeys(keys)
+	return nil
+}
+
+func (s *TrustService) Remove(r *http.Request, fingerprint *string, resp *apiclient.None) error {
+	if fingerprint == nil {
+		return fmt.Errorf("no key fingerprint was specified")
+	}
+	ks, err := s.keystore()
+	if err != nil {
+		return err
+	}
+	return ks.RemoveKey(*fingerprint)
+}
+
+func convertKeys(keys []*keystore.Key) []*apiclient.TrustedKey {
+	result := make([]*apiclient.TrustedKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, &apiclient.TrustedKey{
+			Prefix:      key.Prefix,
+			Fingerprint: key.Fingerprint,
+			Identities:  key.Identities,
+			System:      key.System,
+		})
+	}
+	return result
+}
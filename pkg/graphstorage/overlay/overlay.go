@@ -34,8 +34,10 @@ func New() (graphstorage.StorageProvisioner, error) {
 }
 
 // Create will trigger the creation of an overlay mount at the specified
-// location and with the included base image paths. It will return an error on
-// any failures.
+// location and with the included base image paths. The aufs whiteouts within
+// the images are converted to overlay whiteouts placed just above each image,
+// since the images themselves are read only. It will return an error on any
+// failures.
 func (o *overlayProvisioner) Create(target string, imagedefinition []string) error {
 	upper, err := ioutil.TempDir(os.TempDir(), "upper")
 	if err != nil {
@@ -46,7 +48,25 @@ func (o *overlayProvisioner) Create(target string, imagedefinition []string) err
 		return err
 	}
 
-	lower := strings.Join(imagedefinition, ":")
+	lowers := make([]string, 0, len(imagedefinition))
+	for _, image := range imagedefinition {
+		whiteouts, err := ioutil.TempDir(os.TempDir(), "whiteouts")
+		if err != nil {
+			return err
+		}
+		found, err := graphstorage.OverlayWhiteouts(image, whiteouts)
+		if err != nil {
+			return fmt.Errorf("failed to convert whiteouts in %q: %v", image, err)
+		}
+		if found {
+			lowers = append(lowers, whiteouts)
+		} else {
+			os.Remove(whiteouts)
+		}
+		lowers = append(lowers, image)
+	}
+
+	lower := strings.Join(lowers, ":")
 	opts := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", lower, upper, work)
 	if err := syscall.Mount("overlay", target, "overlay", 0, opts); err != nil {
 		return fmt.Errorf("failed to mount storage: %v", err)
@@ -0,0 +1,66 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package graphstorage
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// ShiftOwnership walks the filesystem at the specified root and changes the
+// ownership of everything within it so that ids on disk are translated through
+// the provided user namespace mappings. A file owned by uid 0 will be owned by
+// the host id that uid 0 is mapped to, so the files appear with the same
+// ownership from within the namespace. Ids that aren't mapped are left as is.
+//
+// This should be called on a union filesystem before anything is mounted within
+// it, so the changes are copied up and the underlying layers are untouched.
+func ShiftOwnership(root string, uidMappings, gidMappings []configs.IDMap) error {
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		stat, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+
+		uid, uidMapped := MapID(int(stat.Uid), uidMappings)
+		gid, gidMapped := MapID(int(stat.Gid), gidMappings)
+		if !uidMapped && !gidMapped {
+			return nil
+		}
+		if !uidMapped {
+			uid = int(stat.Uid)
+		}
+		if !gidMapped {
+			gid = int(stat.Gid)
+		}
+
+		if err := os.Lchown(path, uid, gid); err != nil {
+			return err
+		}
+
+		// Changing the owner clears the setuid and setgid bits, so restore them.
+		if fi.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 && fi.Mode()&os.ModeSymlink == 0 {
+			if err := os.Chmod(path, fi.Mode()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MapID returns the host id that the id within a user namespace is mapped to.
+// The second return value is false if the id isn't mapped.
+func MapID(id int, mappings []configs.IDMap) (int, bool) {
+	for _, m := range mappings {
+		if id >= m.ContainerID && id < m.ContainerID+m.Size {
+			return m.HostID + id - m.ContainerID, true
+		}
+	}
+	return 0, false
+}
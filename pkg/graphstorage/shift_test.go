@@ -0,0 +1,36 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package graphstorage
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestMapID(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	mappings := []configs.IDMap{
+		{ContainerID: 0, HostID: 100000, Size: 1000},
+		{ContainerID: 1000, HostID: 300000, Size: 10},
+	}
+
+	id, ok := MapID(0, mappings)
+	tt.TestEqual(t, ok, true)
+	tt.TestEqual(t, id, 100000)
+
+	id, ok = MapID(999, mappings)
+	tt.TestEqual(t, ok, true)
+	tt.TestEqual(t, id, 100999)
+
+	id, ok = MapID(1005, mappings)
+	tt.TestEqual(t, ok, true)
+	tt.TestEqual(t, id, 300005)
+
+	_, ok = MapID(1010, mappings)
+	tt.TestEqual(t, ok, false)
+}
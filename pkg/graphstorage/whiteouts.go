@@ -0,0 +1,98 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package graphstorage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	// WhiteoutPrefix marks a file within an image which removes the file of the
+	// same name, without the prefix, from the images beneath it. Images are
+	// stored with the whiteouts used by aufs so that they can be used with either
+	// provisioner.
+	WhiteoutPrefix = ".wh."
+
+	// whiteoutMetaPrefix marks the files aufs uses for its own bookkeeping,
+	// which aren't whiteouts of a file.
+	whiteoutMetaPrefix = WhiteoutPrefix + WhiteoutPrefix
+)
+
+// OverlayWhiteouts creates the overlay form of the aufs whiteouts within the
+// image at root under target, which can then be placed above the image when it
+// is mounted with overlay. Overlay whiteouts are character devices with a device
+// number of 0/0, and one is created for both the removed file and the aufs
+// whiteout itself, so it is hidden as well. The directories leading to them are
+// created with the same mode and ownership as within the image, so they don't
+// change how the directories appear. It returns whether the image had any
+// whiteouts.
+func OverlayWhiteouts(root, target string) (bool, error) {
+	found := false
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		base := fi.Name()
+		if strings.HasPrefix(base, whiteoutMetaPrefix) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(base, WhiteoutPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if err := copyDirectories(root, target, rel); err != nil {
+			return err
+		}
+		dir := filepath.Join(target, rel)
+		for _, name := range []string{base, strings.TrimPrefix(base, WhiteoutPrefix)} {
+			if err := syscall.Mknod(filepath.Join(dir, name), syscall.S_IFCHR, 0); err != nil {
+				return err
+			}
+		}
+		found = true
+		return nil
+	})
+	return found, err
+}
+
+// copyDirectories creates each of the directories leading to rel under target,
+// with the same mode, ownership, and modification time they have under root.
+func copyDirectories(root, target, rel string) error {
+	if rel == "." {
+		return nil
+	}
+	if err := copyDirectories(root, target, filepath.Dir(rel)); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(target, rel)
+	if _, err := os.Lstat(dst); err == nil {
+		return nil
+	}
+	fi, err := os.Lstat(filepath.Join(root, rel))
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(dst, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, fi.Mode()); err != nil {
+		return err
+	}
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if err := os.Lchown(dst, int(stat.Uid), int(stat.Gid)); err != nil {
+			return err
+		}
+	}
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
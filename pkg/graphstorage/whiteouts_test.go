@@ -0,0 +1,59 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package graphstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestOverlayWhiteouts(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	if os.Getuid() != 0 {
+		t.Skip("creating overlay whiteouts requires root")
+	}
+
+	root := tt.TempDir(t)
+	tt.TestExpectSuccess(t, os.MkdirAll(filepath.Join(root, "var", "tmp"), os.FileMode(0755)))
+	tt.TestExpectSuccess(t, os.Chmod(filepath.Join(root, "var", "tmp"), os.FileMode(0777)|os.ModeSticky))
+	tt.TestExpectSuccess(t, os.MkdirAll(filepath.Join(root, ".wh..wh.plnk"), os.FileMode(0700)))
+	for _, p := range []string{"bin/sh", ".wh.etc", "var/tmp/.wh.cache", ".wh..wh.plnk/.wh.x"} {
+		tt.TestExpectSuccess(t, os.MkdirAll(filepath.Dir(filepath.Join(root, p)), os.FileMode(0755)))
+		tt.TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(root, p), nil, os.FileMode(0644)))
+	}
+
+	target := tt.TempDir(t)
+	found, err := OverlayWhiteouts(root, target)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, found, true)
+
+	// both the removed path and the aufs whiteout are hidden
+	for _, p := range []string{"etc", ".wh.etc", "var/tmp/cache", "var/tmp/.wh.cache"} {
+		var st syscall.Stat_t
+		tt.TestExpectSuccess(t, syscall.Lstat(filepath.Join(target, p), &st))
+		tt.TestEqual(t, st.Mode&syscall.S_IFMT, uint32(syscall.S_IFCHR))
+		tt.TestEqual(t, st.Rdev, uint64(0))
+	}
+
+	// the directories leading to them keep their mode
+	fi, err := os.Lstat(filepath.Join(target, "var", "tmp"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, fi.Mode(), os.ModeDir|os.ModeSticky|os.FileMode(0777))
+
+	// paths without whiteouts and aufs' own files are left out
+	for _, p := range []string{"bin", ".wh..wh.plnk"} {
+		_, err = os.Lstat(filepath.Join(target, p))
+		tt.TestEqual(t, os.IsNotExist(err), true)
+	}
+
+	found, err = OverlayWhiteouts(filepath.Join(root, "bin"), tt.TempDir(t))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, found, false)
+}
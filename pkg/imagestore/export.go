@@ -0,0 +1,151 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"archive/tar"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/appc/spec/schema/types"
+)
+
+// ExportImage writes the image to w as an ACI, returning the hash of the
+// written ACI. The same image is always exported identically. Dependencies
+// which reference images by ID are rewritten to the hash of that image's own
+// export, so an image exported along with its dependencies can be imported
+// elsewhere with its dependency chain intact.
+func (m *Manager) ExportImage(hash string, w io.Writer) (string, error) {
+	manifest := m.GetImage(hash)
+	if manifest == nil {
+		return "", fmt.Errorf("unable to locate hash %q", hash)
+	}
+
+	exported := *manifest
+	exported.Dependencies = make(types.Dependencies, len(manifest.Dependencies))
+	for i, dep := range manifest.Dependencies {
+		if dep.ImageID != nil {
+			id, err := m.exportedHash(dep.ImageID.String())
+			if err != nil {
+				return "", fmt.Errorf("failed to export dependency %s: %v", dep.ImageName, err)
+			}
+			if dep.ImageID, err = types.NewHash(id); err != nil {
+				return "", err
+			}
+		}
+		exported.Dependencies[i] = dep
+	}
+	b, err := json.Marshal(exported)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+
+	h := sha512.New()
+	tw := tar.NewWriter(io.MultiWriter(w, h))
+	header := &tar.Header{
+		Name:     "manifest",
+		Mode:     0644,
+		Size:     int64(len(b)),
+		ModTime:  time.Unix(0, 0),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return "", err
+	}
+	if _, err := tw.Write(b); err != nil {
+		return "", err
+	}
+	if err := writeTree(tw, filepath.Join(m.Options.Directory, hash, "rootfs"), "rootfs"); err != nil {
+		return "", fmt.Errorf("failed to export image filesystem: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+
+	exportedHash := fmt.Sprintf("sha512-%x", h.Sum(nil))
+	m.imagesLock.Lock()
+	m.exports[hash] = exportedHash
+	m.imagesLock.Unlock()
+	return exportedHash, nil
+}
+
+// exportedHash returns the hash the image has when it is exported.
+func (m *Manager) exportedHash(hash string) (string, error) {
+	m.imagesLock.RLock()
+	exportedHash, exists := m.exports[hash]
+	m.imagesLock.RUnlock()
+	if exists {
+		return exportedHash, nil
+	}
+	return m.ExportImage(hash, ioutil.Discard)
+}
+
+// writeTree writes the contents of the directory to the archive beneath the
+// prefix. Entries are written in order with their ownership, permissions, and
+// modification times, but without access or change times, so the archive
+// doesn't vary between exports.
+func writeTree(tw *tar.Writer, dir, prefix string) error {
+	links := make(map[uint64]string)
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(prefix, rel))
+
+		var target string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if target, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(fi, target)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if fi.IsDir() {
+			header.Name += "/"
+		}
+		header.Uname = ""
+		header.Gname = ""
+		header.AccessTime = time.Time{}
+		header.ChangeTime = time.Time{}
+
+		// files with several links are written once, with the other paths
+		// linking to it
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok && fi.Mode().IsRegular() && st.Nlink > 1 {
+			if first, exists := links[st.Ino]; exists {
+				header.Typeflag = tar.TypeLink
+				header.Linkname = first
+				header.Size = 0
+				return tw.WriteHeader(header)
+			}
+			links[st.Ino] = name
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
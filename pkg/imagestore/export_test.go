@@ -0,0 +1,66 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestExportImage(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager, err := New(&Options{Directory: tt.TempDir(t)})
+	tt.TestExpectSuccess(t, err)
+
+	base := schema.BlankImageManifest()
+	base.Name = types.ACIdentifier("example.com/base")
+	baseHash, _, err := manager.CreateImage(createImage(t, base))
+	tt.TestExpectSuccess(t, err)
+
+	app := schema.BlankImageManifest()
+	app.Name = types.ACIdentifier("example.com/app")
+	baseID, err := types.NewHash(baseHash)
+	tt.TestExpectSuccess(t, err)
+	app.Dependencies = types.Dependencies{{ImageName: base.Name, ImageID: baseID}}
+	appHash, _, err := manager.CreateImage(createImage(t, app))
+	tt.TestExpectSuccess(t, err)
+
+	// exports are reproducible
+	var export1, export2 bytes.Buffer
+	exportedBase, err := manager.ExportImage(baseHash, &export1)
+	tt.TestExpectSuccess(t, err)
+	_, err = manager.ExportImage(baseHash, &export2)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, export1.Bytes(), export2.Bytes())
+
+	var appExport bytes.Buffer
+	exportedApp, err := manager.ExportImage(appHash, &appExport)
+	tt.TestExpectSuccess(t, err)
+
+	// the exports can be imported elsewhere with the dependency intact
+	other, err := New(&Options{Directory: tt.TempDir(t)})
+	tt.TestExpectSuccess(t, err)
+	hash, _, err := other.CreateImage(&export1)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, hash, exportedBase)
+	hash, manifest, err := other.CreateImage(&appExport)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, hash, exportedApp)
+	tt.TestEqual(t, manifest.Dependencies[0].ImageID.String(), exportedBase)
+
+	tree, err := other.ResolveTree(hash)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, tree.Order, []string{exportedApp, exportedBase})
+	b, err := ioutil.ReadFile(filepath.Join(tree.Paths[exportedBase], "blah"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(b), "blah")
+}
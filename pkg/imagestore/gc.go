@@ -0,0 +1,225 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// GCPolicy controls which images are garbage collected and when.
+type GCPolicy struct {
+	// HighWatermark is the percentage of disk usage on the images directory's
+	// filesystem at which garbage collection is triggered. Automatic garbage
+	// collection is disabled if it is 0.
+	HighWatermark int `json:"highWatermark,omitempty"`
+
+	// LowWatermark is the percentage of disk usage that garbage collection
+	// will try to bring usage back down to. It defaults to the high watermark.
+	LowWatermark int `json:"lowWatermark,omitempty"`
+
+	// KeepLast is the number of the most recently created images to keep for
+	// each image name, even if they are not in use.
+	KeepLast int `json:"keepLast,omitempty"`
+
+	// Pinned is a list of image names or hashes which are never removed.
+	Pinned []string `json:"pinned,omitempty"`
+}
+
+// diskUsagePercent returns the percentage of the filesystem containing the
+// path which is in use. It can be replaced in tests.
+var diskUsagePercent = func(path string) (int, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, fmt.Errorf("failed to check disk usage: %v", err)
+	}
+	used := st.Blocks - st.Bfree
+	if used+st.Bavail == 0 {
+		return 0, nil
+	}
+	return int(used * 100 / (used + st.Bavail)), nil
+}
+
+// loadImageCreated reads the recorded creation time for the image in the
+// provided directory. Images created before it was recorded use the provided
+// time, which is persisted so later writes to the image's directory don't
+// change it.
+func loadImageCreated(dir string, modTime time.Time) (time.Time, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, imageCreatedFile))
+	if err == nil {
+		created, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(b)))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse image creation time: %v", err)
+		}
+		return created, nil
+	} else if !os.IsNotExist(err) {
+		return time.Time{}, err
+	}
+
+	if err := writeImageCreated(dir, modTime); err != nil {
+		return time.Time{}, err
+	}
+	return modTime, nil
+}
+
+// writeImageCreated persists the creation time within the image's directory.
+func writeImageCreated(dir string, created time.Time) error {
+	b := []byte(created.UTC().Format(time.RFC3339Nano))
+	if err := ioutil.WriteFile(filepath.Join(dir, imageCreatedFile), b, os.FileMode(0644)); err != nil {
+		return fmt.Errorf("failed to write image creation time: %v", err)
+	}
+	return nil
+}
+
+// Prune removes all of the images which aren't in use, pinned, tagged, a
+// dependency of another image, or among the most recent kept for their name.
+// If dryRun is true, the images which would be removed are returned without
+// removing them.
+func (m *Manager) Prune(dryRun bool) ([]string, error) {
+	return m.collect(dryRun, func() (bool, error) { return false, nil })
+}
+
+// GarbageCollect removes images under the garbage collection policy, oldest
+// first, once disk usage has reached the high watermark and until it is back
+// down to the low watermark. It returns the images that were removed.
+func (m *Manager) GarbageCollect() ([]string, error) {
+	policy := m.Options.GCPolicy
+	if policy == nil || policy.HighWatermark <= 0 {
+		return nil, nil
+	}
+	low := policy.LowWatermark
+	if low <= 0 || low > policy.HighWatermark {
+		low = policy.HighWatermark
+	}
+
+	usage, err := diskUsagePercent(m.Options.Directory)
+	if err != nil {
+		return nil, err
+	}
+	if usage < policy.HighWatermark {
+		return nil, nil
+	}
+	m.log.Infof("Image disk usage is at %d%%, garbage collecting images", usage)
+
+	return m.collect(false, func() (bool, error) {
+		usage, err := diskUsagePercent(m.Options.Directory)
+		if err != nil {
+			return true, err
+		}
+		return usage <= low, nil
+	})
+}
+
+// collect removes the garbage collection candidates in order from oldest to
+// newest, checking done before each one. Images that other images depend on
+// are only removed once those images have been removed.
+func (m *Manager) collect(dryRun bool, done func() (bool, error)) ([]string, error) {
+	m.refsLock.Lock()
+	defer m.refsLock.Unlock()
+
+	trees := m.resolveAll()
+	candidates := m.candidates(trees)
+	removed := make(map[string]bool)
+	var result []string
+
+	for progress := true; progress; {
+		progress = false
+		for _, hash := range candidates {
+			if removed[hash] || m.checkRemovable(hash, trees, removed) != nil {
+				continue
+			}
+			if finished, err := done(); err != nil {
+				return result, err
+			} else if finished {
+				return result, nil
+			}
+
+			if !dryRun {
+				if err := m.removeImage(hash); err != nil {
+					return result, fmt.Errorf("failed to remove image %s: %v", hash, err)
+				}
+				m.log.Infof("Garbage collected image %s", hash)
+			}
+			removed[hash] = true
+			result = append(result, hash)
+			progress = true
+		}
+	}
+	return result, nil
+}
+
+// candidates returns the images which are eligible for removal under the
+// policy, ordered from oldest to newest. The refsLock must be held by the
+// caller.
+func (m *Manager) candidates(trees map[string][]string) []string {
+	policy := m.Options.GCPolicy
+	if policy == nil {
+		policy = &GCPolicy{}
+	}
+	pinned := make(map[string]bool, len(policy.Pinned))
+	for _, p := range policy.Pinned {
+		pinned[p] = true
+	}
+
+	m.imagesLock.RLock()
+	images := make([]*gcImage, 0, len(m.images))
+	for hash, manifest := range m.images {
+		images = append(images, &gcImage{hash: hash, name: manifest.Name.String(), created: m.created[hash], tagged: m.tagged(hash)})
+	}
+	m.imagesLock.RUnlock()
+	sort.Sort(newestImages(images))
+
+	// Walk the images from newest to oldest, keeping the most recent for each
+	// name along with anything pinned, tagged, or in use.
+	keep := make(map[string]bool)
+	kept := make(map[string]int)
+	for _, img := range images {
+		if pinned[img.hash] || pinned[img.name] || img.tagged || len(m.refs[img.hash]) > 0 {
+			keep[img.hash] = true
+		} else if kept[img.name] < policy.KeepLast {
+			kept[img.name]++
+			keep[img.hash] = true
+		}
+	}
+
+	// Anything a kept image depends on must be kept as well.
+	for hash := range keep {
+		for _, layer := range trees[hash] {
+			keep[layer] = true
+		}
+	}
+
+	var candidates []string
+	for i := len(images) - 1; i >= 0; i-- {
+		if !keep[images[i].hash] {
+			candidates = append(candidates, images[i].hash)
+		}
+	}
+	return candidates
+}
+
+// gcImage is the information used to order images for garbage collection.
+type gcImage struct {
+	hash    string
+	name    string
+	created time.Time
+	tagged  bool
+}
+
+// newestImages sorts images from newest to oldest.
+type newestImages []*gcImage
+
+func (a newestImages) Len() int      { return len(a) }
+func (a newestImages) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a newestImages) Less(i, j int) bool {
+	if !a[i].created.Equal(a[j].created) {
+		return a[i].created.After(a[j].created)
+	}
+	return a[i].hash < a[j].hash
+}
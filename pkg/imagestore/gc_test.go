@@ -0,0 +1,158 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// createGCImage adds an image with the name and version to the manager,
+// recording it as created the specified number of minutes ago.
+func createGCImage(t *testing.T, manager *Manager, name, version string, age int, deps ...types.Dependency) string {
+	manifest := schema.BlankImageManifest()
+	manifest.Name = types.ACIdentifier(name)
+	manifest.Labels = types.Labels{{Name: "version", Value: version}}
+	manifest.Dependencies = deps
+
+	hash, _, err := manager.CreateImage(createImage(t, manifest))
+	tt.TestExpectSuccess(t, err)
+	manager.created[hash] = time.Now().Add(-time.Duration(age) * time.Minute)
+	return hash
+}
+
+func dependency(name, version string) types.Dependency {
+	return types.Dependency{
+		ImageName: types.ACIdentifier(name),
+		Labels:    types.Labels{{Name: "version", Value: version}},
+	}
+}
+
+func createGCManager(t *testing.T, policy *GCPolicy) *Manager {
+	manager, err := New(&Options{Directory: tt.TempDir(t), GCPolicy: policy})
+	tt.TestExpectSuccess(t, err)
+	return manager.(*Manager)
+}
+
+func TestDeleteImageInUse(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createGCManager(t, nil)
+	base := createGCImage(t, manager, "base", "1", 2)
+	app := createGCImage(t, manager, "app", "1", 1, dependency("base", "1"))
+
+	// an image another image depends on can't be removed
+	tt.TestExpectError(t, manager.DeleteImage(base))
+
+	// nor can an image that is referenced
+	manager.AddReference(app, "pod 1234")
+	tt.TestExpectError(t, manager.DeleteImage(app))
+
+	// once the references are released, it can be removed, followed by its
+	// dependency
+	manager.RemoveReferences("pod 1234")
+	tt.TestExpectSuccess(t, manager.DeleteImage(app))
+	tt.TestExpectSuccess(t, manager.DeleteImage(base))
+	tt.TestEqual(t, len(manager.ListImages()), 0)
+}
+
+func TestPrune(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createGCManager(t, &GCPolicy{KeepLast: 1, Pinned: []string{"pinned"}})
+	base := createGCImage(t, manager, "base", "1", 10)
+	app1 := createGCImage(t, manager, "app", "1", 9, dependency("base", "1"))
+	app2 := createGCImage(t, manager, "app", "2", 8)
+	base2 := createGCImage(t, manager, "base", "2", 7)
+	app3 := createGCImage(t, manager, "app", "3", 6)
+	used := createGCImage(t, manager, "used", "1", 5)
+	used2 := createGCImage(t, manager, "used", "2", 4)
+	pinned := createGCImage(t, manager, "pinned", "1", 3)
+	pinned2 := createGCImage(t, manager, "pinned", "2", 2)
+	manager.AddReference(used, "pod 1234")
+
+	// The oldest app images go, with the base removed only after the app image
+	// depending on it. The newest of each name and anything in use or pinned is
+	// kept.
+	removed, err := manager.Prune(true)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, removed, []string{app1, app2, base})
+	tt.TestEqual(t, len(manager.ListImages()), 9)
+
+	removed, err = manager.Prune(false)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, removed, []string{app1, app2, base})
+
+	images := manager.ListImages()
+	tt.TestEqual(t, len(images), 6)
+	for _, hash := range []string{base2, app3, used, used2, pinned, pinned2} {
+		tt.TestNotEqual(t, images[hash], nil)
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	usage := 90
+	origUsage := diskUsagePercent
+	diskUsagePercent = func(string) (int, error) { return usage, nil }
+	defer func() { diskUsagePercent = origUsage }()
+
+	manager := createGCManager(t, &GCPolicy{HighWatermark: 85, LowWatermark: 80})
+	oldest := createGCImage(t, manager, "app", "1", 3)
+	createGCImage(t, manager, "app", "2", 2)
+	createGCImage(t, manager, "app", "3", 1)
+
+	// Simulate each removal freeing up 5% of the disk.
+	diskUsagePercent = func(string) (int, error) {
+		usage -= 5
+		return usage + 5, nil
+	}
+	removed, err := manager.GarbageCollect()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, removed, []string{oldest})
+	tt.TestEqual(t, len(manager.ListImages()), 2)
+
+	// Nothing is removed below the high watermark.
+	diskUsagePercent = func(string) (int, error) { return 50, nil }
+	removed, err = manager.GarbageCollect()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(removed), 0)
+	tt.TestEqual(t, len(manager.ListImages()), 2)
+}
+
+func TestImageCreatedTime(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createGCManager(t, nil)
+	hash := createGCImage(t, manager, "app", "1", 0)
+	tt.TestExpectSuccess(t, manager.Rescan())
+	created := manager.created[hash]
+	dir := filepath.Join(manager.Options.Directory, hash)
+
+	// later writes to the image's directory don't change when it was created
+	later := created.Add(time.Hour)
+	tt.TestExpectSuccess(t, os.Chtimes(dir, later, later))
+	tt.TestExpectSuccess(t, manager.Rescan())
+	tt.TestEqual(t, manager.created[hash].Equal(created), true)
+
+	// images created before it was recorded use the directory's time, which is
+	// then recorded
+	tt.TestExpectSuccess(t, os.Remove(filepath.Join(dir, imageCreatedFile)))
+	tt.TestExpectSuccess(t, os.Chtimes(dir, later, later))
+	tt.TestExpectSuccess(t, manager.Rescan())
+	tt.TestEqual(t, manager.created[hash].Equal(later), true)
+	_, err := os.Stat(filepath.Join(dir, imageCreatedFile))
+	tt.TestExpectSuccess(t, err)
+}
@@ -71,6 +71,5 @@ func (lp *layerProcessor) findImageDependency(dep types.Dependency) (string, *sc
 		return dephash, lp.manager.GetImage(dephash)
 	}
 
-	version, _ := dep.Labels.Get("version")
-	return lp.manager.FindImage(dep.ImageName.String(), version)
+	return lp.manager.findImage(dep.ImageName.String(), dep.Labels)
 }
@@ -9,7 +9,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/apcera/kurma/pkg/backend"
 	"github.com/apcera/logray"
@@ -20,11 +23,21 @@ import (
 	"github.com/appc/spec/schema/types"
 )
 
+const (
+	// imageCreatedFile is the name of the file stored next to an image's
+	// manifest which records when the image was created.
+	imageCreatedFile = "created"
+)
+
 // Options contains settings that are used by the Image Manager and
 // Containers running on the host.
 type Options struct {
 	Directory string
 	Log       *logray.Logger
+
+	// GCPolicy controls which images are removed when pruning or garbage
+	// collecting images.
+	GCPolicy *GCPolicy
 }
 
 // Manager handles the management of the containers running and available on the
@@ -35,7 +48,15 @@ type Manager struct {
 	Options *Options
 
 	images     map[string]*schema.ImageManifest
+	sizes      map[string]*imageSize
+	created    map[string]time.Time
+	signers    map[string]string
+	exports    map[string]string
+	tags       map[string]string
 	imagesLock sync.RWMutex
+
+	refs     map[string]map[string]bool
+	refsLock sync.Mutex
 }
 
 // New will create and return a new Manager for managing images.
@@ -43,12 +64,18 @@ func New(options *Options) (backend.ImageManager, error) {
 	m := &Manager{
 		log:     options.Log,
 		Options: options,
+		refs:    make(map[string]map[string]bool),
 	}
 
 	if m.log == nil {
 		m.log = logray.New()
 	}
 
+	// remove any images left partially extracted
+	if err := m.removeTempDirs(); err != nil {
+		return nil, err
+	}
+
 	// load the list of existing image manifests
 	if err := m.Rescan(); err != nil {
 		return nil, err
@@ -61,6 +88,9 @@ func New(options *Options) (backend.ImageManager, error) {
 func (m *Manager) Rescan() error {
 	m.imagesLock.Lock()
 	m.images = make(map[string]*schema.ImageManifest)
+	m.sizes = make(map[string]*imageSize)
+	m.created = make(map[string]time.Time)
+	m.exports = make(map[string]string)
 	m.imagesLock.Unlock()
 
 	contents, err := ioutil.ReadDir(m.Options.Directory)
@@ -69,7 +99,8 @@ func (m *Manager) Rescan() error {
 	}
 
 	for _, fi := range contents {
-		if !fi.IsDir() {
+		// skip images which are still being extracted
+		if !fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
 			continue
 		}
 		if _, err := m.loadFile(fi); err != nil {
@@ -78,6 +109,32 @@ func (m *Manager) Rescan() error {
 		}
 	}
 
+	m.imagesLock.Lock()
+	defer m.imagesLock.Unlock()
+	if err := m.loadTags(); err != nil {
+		return err
+	}
+	if err := m.loadSigners(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// removeTempDirs removes the temporary directories left behind by images
+// which were never fully extracted.
+func (m *Manager) removeTempDirs() error {
+	contents, err := ioutil.ReadDir(m.Options.Directory)
+	if err != nil {
+		return err
+	}
+	for _, fi := range contents {
+		if fi.IsDir() && strings.HasPrefix(fi.Name(), ".") {
+			if err := os.RemoveAll(filepath.Join(m.Options.Directory, fi.Name())); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -85,7 +142,8 @@ func (m *Manager) Rescan() error {
 // available for containers. It will return the image hash ID, image manifest
 // from within the image, or an error on any failures.
 func (m *Manager) CreateImage(reader io.Reader) (string, *schema.ImageManifest, error) {
-	hr := hashutil.NewSha512(reader)
+	cr := &countingReader{reader: reader}
+	hr := hashutil.NewSha512(cr)
 	f, err := tempfile.New(hr)
 	if err != nil {
 		return "", nil, err
@@ -102,42 +160,77 @@ func (m *Manager) CreateImage(reader io.Reader) (string, *schema.ImageManifest,
 		return hash, manifest, nil
 	}
 
-	dest := filepath.Join(m.Options.Directory, hash)
-	if err := os.Mkdir(dest, os.FileMode(0755)); err != nil {
+	// The image is extracted to a temporary directory which is renamed into
+	// place once it is complete, so concurrent creates of the same image don't
+	// collide and a partially extracted image is never loaded.
+	tmpdir, err := ioutil.TempDir(m.Options.Directory, "."+hash+"-")
+	if err != nil {
 		return "", nil, err
 	}
-
-	successful := false
-	defer func() {
-		if !successful {
-			os.RemoveAll(dest)
-		}
-	}()
-
-	fi, err := os.Stat(dest)
-	if err != nil {
+	defer os.RemoveAll(tmpdir)
+	if err := os.Chmod(tmpdir, os.FileMode(0755)); err != nil {
 		return "", nil, err
 	}
 
 	// untar the file
-	tarfile := tarhelper.NewUntar(f, dest)
+	tarfile := tarhelper.NewUntar(f, tmpdir)
 	tarfile.PreserveOwners = true
 	tarfile.PreservePermissions = true
 	tarfile.Compression = tarhelper.DETECT
-	tarfile.AbsoluteRoot = dest
+	tarfile.AbsoluteRoot = tmpdir
 	if err := tarfile.Extract(); err != nil {
 		return "", nil, fmt.Errorf("failed to extract image filesystem: %v", err)
 	}
 
+	// record the sizes so they don't need to be computed again
+	size, err := diskUsage(filepath.Join(tmpdir, "rootfs"))
+	if err != nil {
+		return "", nil, err
+	}
+	if err := writeImageSize(tmpdir, &imageSize{Size: size, CompressedSize: cr.count}); err != nil {
+		return "", nil, err
+	}
+	if err := writeImageCreated(tmpdir, time.Now()); err != nil {
+		return "", nil, err
+	}
+
+	// Move the image into place. If the rename fails because the image already
+	// exists, it was created concurrently and that copy is used.
+	dest := filepath.Join(m.Options.Directory, hash)
+	if err := os.Rename(tmpdir, dest); err != nil && !os.IsExist(err) && !isNotEmpty(err) {
+		return "", nil, err
+	}
+
+	m.imagesLock.RLock()
+	manifest, exists = m.images[hash]
+	m.imagesLock.RUnlock()
+	if exists {
+		return hash, manifest, nil
+	}
+
+	fi, err := os.Stat(dest)
+	if err != nil {
+		return "", nil, err
+	}
+
 	// load the manifest and return it
 	manifest, err = m.loadFile(fi)
 	if err != nil {
+		os.RemoveAll(dest)
 		return "", nil, err
 	}
-	successful = true
 	return hash, manifest, nil
 }
 
+// isNotEmpty returns whether the error is because a directory isn't empty,
+// which is returned when renaming over an existing directory.
+func isNotEmpty(err error) bool {
+	if lerr, ok := err.(*os.LinkError); ok {
+		return lerr.Err == syscall.ENOTEMPTY
+	}
+	return false
+}
+
 // ListImages returns a map of the image hash to image manifest for all images
 // that are available.
 func (m *Manager) ListImages() map[string]*schema.ImageManifest {
@@ -153,46 +246,72 @@ func (m *Manager) GetImage(hash string) *schema.ImageManifest {
 	return m.images[hash]
 }
 
-// FindImage will find the image manifest and hash for the specified name and
-// version label.
-func (m *Manager) FindImage(name, version string) (string, *schema.ImageManifest) {
+// GetImageSize will return the on disk size of the image, the size it was
+// provided as, and the total on disk size including its dependencies.
+func (m *Manager) GetImageSize(hash string) (*backend.ImageSize, error) {
 	m.imagesLock.RLock()
-	defer m.imagesLock.RUnlock()
-
-	for hash, manifest := range m.images {
-		if manifest.Name.String() != name {
-			continue
-		}
-
-		v, _ := manifest.Labels.Get("version")
-		if v == version || version == "" {
-			return hash, manifest
-		}
+	size, exists := m.sizes[hash]
+	m.imagesLock.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("specified image not found")
 	}
 
-	return "", nil
-}
-
-// GetImageSize will return the on disk size of the image.
-func (m *Manager) GetImageSize(hash string) (int64, error) {
-	path := filepath.Join(m.Options.Directory, hash)
-	if _, err := os.Stat(path); err != nil {
-		return 0, fmt.Errorf("failed to locate image path: %v", err)
+	// Walk the dependency tree for the total size. If the dependencies can't be
+	// resolved, only the image itself is counted.
+	total := size.Size
+	tree, err := m.ResolveTree(hash)
+	if err != nil {
+		m.log.Warnf("Failed to resolve dependencies for image %s: %v", hash, err)
+	} else {
+		total = 0
+		m.imagesLock.RLock()
+		for _, layer := range tree.Order {
+			if s := m.sizes[layer]; s != nil {
+				total += s.Size
+			}
+		}
+		m.imagesLock.RUnlock()
 	}
 
-	// FIXME need a real way to do this
-	return 0, nil
+	return &backend.ImageSize{
+		Size:           size.Size,
+		CompressedSize: size.CompressedSize,
+		TotalSize:      total,
+	}, nil
 }
 
-// DeleteImage will remove the specified image hash from disk.
+// DeleteImage will remove the specified image hash from disk. It returns an
+// error if the image is in use or another image depends on it.
 func (m *Manager) DeleteImage(hash string) error {
 	if hash == "" {
 		return nil
 	}
+
+	m.refsLock.Lock()
+	defer m.refsLock.Unlock()
+	if err := m.checkRemovable(hash, m.resolveAll(), nil); err != nil {
+		return err
+	}
+	return m.removeImage(hash)
+}
+
+// removeImage removes the image from the manager and from disk.
+func (m *Manager) removeImage(hash string) error {
 	m.imagesLock.Lock()
 	delete(m.images, hash)
+	delete(m.sizes, hash)
+	delete(m.created, hash)
+	delete(m.exports, hash)
+	tagsErr := m.removeTags(hash)
+	signersErr := m.removeSigner(hash)
 	m.imagesLock.Unlock()
-	return os.RemoveAll(filepath.Join(m.Options.Directory, hash))
+	if err := os.RemoveAll(filepath.Join(m.Options.Directory, hash)); err != nil {
+		return err
+	}
+	if tagsErr != nil {
+		return tagsErr
+	}
+	return signersErr
 }
 
 // ResolveTree will resolve the dependency tree for the specified image. It
@@ -241,8 +360,19 @@ func (m *Manager) loadFile(fi os.FileInfo) (*schema.ImageManifest, error) {
 		return nil, err
 	}
 
+	size, err := loadImageSize(filepath.Join(m.Options.Directory, fi.Name()))
+	if err != nil {
+		return nil, err
+	}
+	created, err := loadImageCreated(filepath.Join(m.Options.Directory, fi.Name()), fi.ModTime())
+	if err != nil {
+		return nil, err
+	}
+
 	m.imagesLock.Lock()
 	m.images[fi.Name()] = manifest
+	m.sizes[fi.Name()] = size
+	m.created[fi.Name()] = created
 	m.imagesLock.Unlock()
 	return manifest, nil
 }
@@ -7,6 +7,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -153,6 +156,55 @@ func TestListAndRescanImages(t *testing.T) {
 	tt.TestEqual(t, len(images), 0)
 }
 
+func TestImageSize(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	tempdir := tt.TempDir(t)
+	manager, err := New(&Options{Directory: tempdir})
+	tt.TestExpectSuccess(t, err)
+
+	base := schema.BlankImageManifest()
+	base.Name = types.ACIdentifier("base")
+	baseReader := createImage(t, base).(*bytes.Buffer)
+	baseCompressed := int64(baseReader.Len())
+	baseHash, _, err := manager.CreateImage(baseReader)
+	tt.TestExpectSuccess(t, err)
+
+	top := schema.BlankImageManifest()
+	top.Name = types.ACIdentifier("top")
+	top.Dependencies = types.Dependencies{{ImageName: types.ACIdentifier("base")}}
+	topHash, _, err := manager.CreateImage(createImage(t, top))
+	tt.TestExpectSuccess(t, err)
+
+	baseSize, err := manager.GetImageSize(baseHash)
+	tt.TestExpectSuccess(t, err)
+	tt.TestNotEqual(t, baseSize.Size, int64(0))
+	tt.TestEqual(t, baseSize.CompressedSize, baseCompressed)
+	tt.TestEqual(t, baseSize.TotalSize, baseSize.Size)
+
+	topSize, err := manager.GetImageSize(topHash)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, topSize.TotalSize, topSize.Size+baseSize.Size)
+
+	// the sizes are persisted and reloaded on a rescan
+	tt.TestExpectSuccess(t, manager.Rescan())
+	reloaded, err := manager.GetImageSize(baseHash)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, reloaded, baseSize)
+
+	// images without recorded sizes have them computed
+	tt.TestExpectSuccess(t, os.Remove(filepath.Join(tempdir, baseHash, imageSizeFile)))
+	tt.TestExpectSuccess(t, manager.Rescan())
+	reloaded, err = manager.GetImageSize(baseHash)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, reloaded.Size, baseSize.Size)
+	tt.TestEqual(t, reloaded.CompressedSize, int64(0))
+
+	_, err = manager.GetImageSize("sha512-missing")
+	tt.TestExpectError(t, err)
+}
+
 func writeDirectory(t *testing.T, archive *tar.Writer, name string) {
 	header := &tar.Header{
 		Name:     name + "/",
@@ -180,3 +232,42 @@ func writeFile(t *testing.T, archive *tar.Writer, name, contents string) {
 	tt.TestExpectSuccess(t, err)
 	tt.TestExpectSuccess(t, archive.Flush())
 }
+
+func TestCreateImageConcurrently(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	tempdir := tt.TempDir(t)
+	manager, err := New(&Options{Directory: tempdir})
+	tt.TestExpectSuccess(t, err)
+
+	manifest := schema.BlankImageManifest()
+	manifest.Name = types.ACIdentifier("example")
+	image := createImage(t, manifest).(*bytes.Buffer).Bytes()
+
+	// each create of the same image succeeds with the same hash
+	var wg sync.WaitGroup
+	hashes := make([]string, 10)
+	errs := make([]error, 10)
+	for i := range hashes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			hashes[i], _, errs[i] = manager.CreateImage(bytes.NewReader(image))
+		}(i)
+	}
+	wg.Wait()
+	for i := range hashes {
+		tt.TestExpectSuccess(t, errs[i])
+		tt.TestEqual(t, hashes[i], hashes[0])
+	}
+	tt.TestEqual(t, len(manager.ListImages()), 1)
+
+	// no temporary directories are left behind
+	f, err := os.Open(tempdir)
+	tt.TestExpectSuccess(t, err)
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, names, []string{hashes[0]})
+}
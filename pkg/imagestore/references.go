@@ -0,0 +1,98 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AddReference records that the owner is using the image, which keeps it from
+// being deleted or garbage collected.
+func (m *Manager) AddReference(hash, owner string) {
+	m.refsLock.Lock()
+	defer m.refsLock.Unlock()
+
+	if m.refs[hash] == nil {
+		m.refs[hash] = make(map[string]bool)
+	}
+	m.refs[hash][owner] = true
+}
+
+// RemoveReferences removes all of the references held by the owner.
+func (m *Manager) RemoveReferences(owner string) {
+	m.refsLock.Lock()
+	defer m.refsLock.Unlock()
+
+	for hash, owners := range m.refs {
+		delete(owners, owner)
+		if len(owners) == 0 {
+			delete(m.refs, hash)
+		}
+	}
+}
+
+// referenceOwners returns the sorted list of owners referencing the image. The
+// refsLock must be held by the caller.
+func (m *Manager) referenceOwners(hash string) []string {
+	owners := make([]string, 0, len(m.refs[hash]))
+	for owner := range m.refs[hash] {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// resolveAll resolves the dependency tree of every image. The returned map is
+// keyed by the image hash and contains its dependencies, excluding the image
+// itself. Images whose dependencies can't be resolved are left out.
+func (m *Manager) resolveAll() map[string][]string {
+	m.imagesLock.RLock()
+	hashes := make([]string, 0, len(m.images))
+	for hash := range m.images {
+		hashes = append(hashes, hash)
+	}
+	m.imagesLock.RUnlock()
+
+	trees := make(map[string][]string, len(hashes))
+	for _, hash := range hashes {
+		layers, err := m.processLayers(hash)
+		if err != nil {
+			continue
+		}
+		trees[hash] = layers[1:]
+	}
+	return trees
+}
+
+// dependents returns the sorted list of images which depend on the specified
+// image, skipping any images in the ignore set.
+func dependents(hash string, trees map[string][]string, ignore map[string]bool) []string {
+	var deps []string
+	for image, layers := range trees {
+		if ignore[image] {
+			continue
+		}
+		for _, layer := range layers {
+			if layer == hash {
+				deps = append(deps, image)
+				break
+			}
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// checkRemovable returns an error if the image is in use or another image
+// depends on it. The refsLock must be held by the caller.
+func (m *Manager) checkRemovable(hash string, trees map[string][]string, ignore map[string]bool) error {
+	if owners := m.referenceOwners(hash); len(owners) > 0 {
+		return fmt.Errorf("image %s is in use by %s", hash, strings.Join(owners, ", "))
+	}
+	if deps := dependents(hash, trees, ignore); len(deps) > 0 {
+		return fmt.Errorf("image %s is a dependency of %s", hash, strings.Join(deps, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+	"github.com/coreos/go-semver/semver"
+)
+
+// FindImage will find the image manifest and hash for the specified name and
+// version label. A local tag on the name matching the version takes precedence
+// over the images' version labels. An empty version is the same as "latest",
+// which resolves to the image tagged or labeled as "latest" if there is one,
+// and otherwise to the image with the highest version.
+func (m *Manager) FindImage(name, version string) (string, *schema.ImageManifest) {
+	var labels types.Labels
+	if version != "" {
+		labels = types.Labels{{Name: "version", Value: version}}
+	}
+	return m.findImage(name, labels)
+}
+
+// findImage finds the image with the name that best matches the labels. Images
+// must match every label they have in common with the labels, such as os and
+// arch, while the version is resolved as by FindImage. When several images
+// match, the one with the highest version is chosen, followed by the most
+// recently created, so the same image is always chosen.
+func (m *Manager) findImage(name string, labels types.Labels) (string, *schema.ImageManifest) {
+	m.imagesLock.RLock()
+	defer m.imagesLock.RUnlock()
+
+	version, _ := labels.Get("version")
+	if version == "" {
+		version = latestVersion
+	}
+	if hash, exists := m.tags[tagKey(name, version)]; exists && m.images[hash] != nil {
+		return hash, m.images[hash]
+	}
+
+	var candidates []*versionedImage
+	for hash, manifest := range m.images {
+		if manifest.Name.String() != name || !matchLabels(manifest.Labels, labels) {
+			continue
+		}
+		v, _ := manifest.Labels.Get("version")
+		candidates = append(candidates, &versionedImage{
+			hash:     hash,
+			manifest: manifest,
+			version:  v,
+			semver:   parseVersion(v),
+			created:  m.created[hash],
+		})
+	}
+	sort.Sort(byVersion(candidates))
+
+	for _, c := range candidates {
+		if c.version == version {
+			return c.hash, c.manifest
+		}
+	}
+	if version == latestVersion && len(candidates) > 0 {
+		return candidates[0].hash, candidates[0].manifest
+	}
+	return "", nil
+}
+
+// matchLabels returns whether the image's labels match all of the labels other
+// than the version which the image also has.
+func matchLabels(imageLabels, labels types.Labels) bool {
+	for _, l := range labels {
+		if l.Name == "version" {
+			continue
+		}
+		if v, exists := imageLabels.Get(l.Name.String()); exists && v != l.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// parseVersion parses the version as a semantic version, allowing a leading
+// "v" and a missing minor or patch number. It returns nil if the version isn't
+// a semantic version.
+func parseVersion(version string) *semver.Version {
+	version = strings.TrimPrefix(version, "v")
+	core, suffix := version, ""
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		core, suffix = version[:i], version[i:]
+	}
+	for n := strings.Count(core, "."); n < 2; n++ {
+		core += ".0"
+	}
+	v, err := semver.NewVersion(core + suffix)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// versionedImage is an image being considered by findImage.
+type versionedImage struct {
+	hash     string
+	manifest *schema.ImageManifest
+	version  string
+	semver   *semver.Version
+	created  time.Time
+}
+
+// byVersion sorts images from the highest semantic version down, with images
+// whose versions aren't semantic versions last. Images with the same version
+// are sorted from newest to oldest.
+type byVersion []*versionedImage
+
+func (a byVersion) Len() int      { return len(a) }
+func (a byVersion) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byVersion) Less(i, j int) bool {
+	vi, vj := a[i].semver, a[j].semver
+	switch {
+	case vi != nil && vj == nil:
+		return true
+	case vi == nil && vj != nil:
+		return false
+	case vi != nil && vj != nil && vi.LessThan(*vj) != vj.LessThan(*vi):
+		return vj.LessThan(*vi)
+	}
+	if !a[i].created.Equal(a[j].created) {
+		return a[i].created.After(a[j].created)
+	}
+	return a[i].hash < a[j].hash
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// signersFile is the name of the file within the images directory which
+// records the fingerprint of the key that signed each image. It is kept apart
+// from the image directories, since their contents come from the images.
+const signersFile = "signers"
+
+// SetImageSigner records the fingerprint of the key whose signature on the
+// image was verified when it was retrieved.
+func (m *Manager) SetImageSigner(hash, fingerprint string) error {
+	m.imagesLock.Lock()
+	defer m.imagesLock.Unlock()
+
+	if _, exists := m.images[hash]; !exists {
+		return fmt.Errorf("specified image not found")
+	}
+	previous, signed := m.signers[hash]
+	m.signers[hash] = fingerprint
+	if err := m.saveSigners(); err != nil {
+		if signed {
+			m.signers[hash] = previous
+		} else {
+			delete(m.signers, hash)
+		}
+		return err
+	}
+	return nil
+}
+
+// ImageSigner returns the fingerprint of the key that signed the image, or an
+// empty string if the image's signature was never verified.
+func (m *Manager) ImageSigner(hash string) string {
+	m.imagesLock.RLock()
+	defer m.imagesLock.RUnlock()
+	return m.signers[hash]
+}
+
+// removeSigner removes the record of the image's signer. The imagesLock must be
+// held by the caller.
+func (m *Manager) removeSigner(hash string) error {
+	if _, signed := m.signers[hash]; !signed {
+		return nil
+	}
+	delete(m.signers, hash)
+	return m.saveSigners()
+}
+
+// loadSigners reads the image signers from disk, dropping any for images which
+// no longer exist. The imagesLock must be held by the caller.
+func (m *Manager) loadSigners() error {
+	m.signers = make(map[string]string)
+	b, err := ioutil.ReadFile(filepath.Join(m.Options.Directory, signersFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &m.signers); err != nil {
+		return fmt.Errorf("failed to parse image signers: %v", err)
+	}
+	for hash := range m.signers {
+		if m.images[hash] == nil {
+			delete(m.signers, hash)
+		}
+	}
+	return nil
+}
+
+// saveSigners writes the image signers to disk, replacing the previous file
+// atomically. The imagesLock must be held by the caller.
+func (m *Manager) saveSigners() error {
+	b, err := json.Marshal(m.signers)
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(m.Options.Directory, signersFile)
+	if err := ioutil.WriteFile(filename+".tmp", b, os.FileMode(0600)); err != nil {
+		return fmt.Errorf("failed to write image signers: %v", err)
+	}
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		return fmt.Errorf("failed to write image signers: %v", err)
+	}
+	return nil
+}
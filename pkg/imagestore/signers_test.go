@@ -0,0 +1,41 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestImageSigner(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createGCManager(t, nil)
+	signed := createGCImage(t, manager, "signed", "1", 0)
+	unsigned := createGCImage(t, manager, "unsigned", "1", 0)
+
+	tt.TestExpectError(t, manager.SetImageSigner("sha512-missing", "abcd"))
+	tt.TestExpectSuccess(t, manager.SetImageSigner(signed, "abcd"))
+	tt.TestEqual(t, manager.ImageSigner(signed), "abcd")
+
+	// the signer is kept across a rescan
+	tt.TestExpectSuccess(t, manager.Rescan())
+	tt.TestEqual(t, manager.ImageSigner(signed), "abcd")
+
+	// a file within the image's directory can't mark it as signed
+	dir := filepath.Join(manager.Options.Directory, unsigned)
+	tt.TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "signer"), []byte("abcd"), os.FileMode(0644)))
+	tt.TestExpectSuccess(t, manager.Rescan())
+	tt.TestEqual(t, manager.ImageSigner(unsigned), "")
+
+	// removing the image removes its signer
+	tt.TestExpectSuccess(t, manager.DeleteImage(signed))
+	tt.TestEqual(t, manager.ImageSigner(signed), "")
+	tt.TestExpectSuccess(t, manager.Rescan())
+	tt.TestEqual(t, len(manager.signers), 0)
+}
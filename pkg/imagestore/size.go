@@ -0,0 +1,109 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	// imageSizeFile is the name of the file stored next to an image's manifest
+	// which records the sizes computed when the image was created.
+	imageSizeFile = "size.json"
+)
+
+// imageSize contains the sizes recorded for a single image.
+type imageSize struct {
+	// Size is the number of bytes the extracted image uses on disk.
+	Size int64 `json:"size"`
+
+	// CompressedSize is the size of the image as it was provided. It will be 0
+	// for images that were extracted before sizes were recorded.
+	CompressedSize int64 `json:"compressedSize"`
+}
+
+// countingReader wraps a reader and tracks the number of bytes read from it.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// loadImageSize reads the recorded sizes for the image in the provided
+// directory. If they were never recorded, the extracted size is computed and
+// persisted so it doesn't need to be walked again.
+func loadImageSize(dir string) (*imageSize, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, imageSizeFile))
+	if err == nil {
+		var size *imageSize
+		if err := json.Unmarshal(b, &size); err != nil {
+			return nil, fmt.Errorf("failed to parse image size: %v", err)
+		}
+		return size, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	size, err := diskUsage(filepath.Join(dir, "rootfs"))
+	if err != nil {
+		return nil, err
+	}
+	s := &imageSize{Size: size}
+	if err := writeImageSize(dir, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeImageSize persists the sizes within the image's directory.
+func writeImageSize(dir string, size *imageSize) error {
+	b, err := json.Marshal(size)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, imageSizeFile), b, os.FileMode(0644)); err != nil {
+		return fmt.Errorf("failed to write image size: %v", err)
+	}
+	return nil
+}
+
+// diskUsage returns the number of bytes allocated on disk for everything under
+// the provided path. Hard linked files are only counted once.
+func diskUsage(root string) (int64, error) {
+	var total int64
+	seen := make(map[uint64]bool)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			total += info.Size()
+			return nil
+		}
+		if st.Nlink > 1 && !info.IsDir() {
+			if seen[st.Ino] {
+				return nil
+			}
+			seen[st.Ino] = true
+		}
+		total += st.Blocks * 512
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute image size: %v", err)
+	}
+	return total, nil
+}
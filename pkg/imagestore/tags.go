@@ -0,0 +1,150 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	// tagsFile is the name of the file within the images directory which
+	// records the local tags.
+	tagsFile = "tags"
+
+	// latestVersion is the version which resolves to the newest version of an
+	// image when it isn't tagged or labeled with it explicitly.
+	latestVersion = "latest"
+)
+
+// tagKey returns the key a tag is stored under.
+func tagKey(name, tag string) string {
+	return name + ":" + tag
+}
+
+// TagImage tags the image, so that looking up the name with the tag as its
+// version resolves to the image. If the tag is already on another image, it is
+// moved to this one.
+func (m *Manager) TagImage(hash, name, tag string) error {
+	if _, err := types.NewACIdentifier(name); err != nil {
+		return fmt.Errorf("invalid image name %q: %v", name, err)
+	}
+	if tag == "" || strings.ContainsAny(tag, ": \t\n") {
+		return fmt.Errorf("invalid tag %q", tag)
+	}
+
+	m.imagesLock.Lock()
+	defer m.imagesLock.Unlock()
+	if m.images[hash] == nil {
+		return fmt.Errorf("specified image not found")
+	}
+	previous, existed := m.tags[tagKey(name, tag)]
+	m.tags[tagKey(name, tag)] = hash
+	if err := m.saveTags(); err != nil {
+		if existed {
+			m.tags[tagKey(name, tag)] = previous
+		} else {
+			delete(m.tags, tagKey(name, tag))
+		}
+		return err
+	}
+	return nil
+}
+
+// UntagImage removes the tag.
+func (m *Manager) UntagImage(name, tag string) error {
+	m.imagesLock.Lock()
+	defer m.imagesLock.Unlock()
+	hash, exists := m.tags[tagKey(name, tag)]
+	if !exists {
+		return fmt.Errorf("tag %s not found", tagKey(name, tag))
+	}
+	delete(m.tags, tagKey(name, tag))
+	if err := m.saveTags(); err != nil {
+		m.tags[tagKey(name, tag)] = hash
+		return err
+	}
+	return nil
+}
+
+// ListTags returns a map of each tag, in the form name:tag, to the hash of the
+// image it is on.
+func (m *Manager) ListTags() map[string]string {
+	m.imagesLock.RLock()
+	defer m.imagesLock.RUnlock()
+	tags := make(map[string]string, len(m.tags))
+	for tag, hash := range m.tags {
+		tags[tag] = hash
+	}
+	return tags
+}
+
+// tagged returns whether the image has any tags. The imagesLock must be held
+// by the caller.
+func (m *Manager) tagged(hash string) bool {
+	for _, h := range m.tags {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// removeTags removes the tags on the image. The imagesLock must be held by the
+// caller.
+func (m *Manager) removeTags(hash string) error {
+	if !m.tagged(hash) {
+		return nil
+	}
+	for tag, h := range m.tags {
+		if h == hash {
+			delete(m.tags, tag)
+		}
+	}
+	return m.saveTags()
+}
+
+// loadTags reads the tags from disk, dropping any on images which no longer
+// exist. The imagesLock must be held by the caller.
+func (m *Manager) loadTags() error {
+	m.tags = make(map[string]string)
+	b, err := ioutil.ReadFile(filepath.Join(m.Options.Directory, tagsFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &m.tags); err != nil {
+		return fmt.Errorf("failed to parse tags: %v", err)
+	}
+	for tag, hash := range m.tags {
+		if m.images[hash] == nil {
+			m.log.Warnf("Removing tag %s on missing image %s", tag, hash)
+			delete(m.tags, tag)
+		}
+	}
+	return nil
+}
+
+// saveTags writes the tags to disk, replacing the previous file atomically.
+// The imagesLock must be held by the caller.
+func (m *Manager) saveTags() error {
+	b, err := json.Marshal(m.tags)
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(m.Options.Directory, tagsFile)
+	if err := ioutil.WriteFile(filename+".tmp", b, os.FileMode(0644)); err != nil {
+		return fmt.Errorf("failed to write tags: %v", err)
+	}
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		return fmt.Errorf("failed to write tags: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package imagestore
+
+import (
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestFindImageVersions(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createGCManager(t, nil)
+	v19 := createGCImage(t, manager, "kurma.io/api", "1.9.0", 1)
+	v110 := createGCImage(t, manager, "kurma.io/api", "v1.10", 3)
+	createGCImage(t, manager, "kurma.io/api", "1.10.0-rc1", 0)
+	createGCImage(t, manager, "kurma.io/api", "nightly", 0)
+	createGCImage(t, manager, "kurma.io/other", "2.0.0", 0)
+
+	// the highest version is chosen regardless of when it was created
+	for i := 0; i < 10; i++ {
+		hash, _ := manager.FindImage("kurma.io/api", "")
+		tt.TestEqual(t, hash, v110)
+	}
+	hash, _ := manager.FindImage("kurma.io/api", "latest")
+	tt.TestEqual(t, hash, v110)
+	hash, _ = manager.FindImage("kurma.io/api", "1.9.0")
+	tt.TestEqual(t, hash, v19)
+	hash, _ = manager.FindImage("kurma.io/api", "2.0.0")
+	tt.TestEqual(t, hash, "")
+
+	// the newest of several images with the same version is chosen
+	newer := createGCImage(t, manager, "kurma.io/api", "1.9.0", 0)
+	hash, _ = manager.FindImage("kurma.io/api", "1.9.0")
+	tt.TestEqual(t, hash, newer)
+
+	// an image labeled latest is preferred over the highest version
+	latest := createGCImage(t, manager, "kurma.io/api", "latest", 5)
+	hash, _ = manager.FindImage("kurma.io/api", "")
+	tt.TestEqual(t, hash, latest)
+}
+
+func TestTagImage(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createGCManager(t, &GCPolicy{})
+	v1 := createGCImage(t, manager, "kurma.io/api", "1.0.0", 2)
+	v2 := createGCImage(t, manager, "kurma.io/api", "2.0.0", 1)
+
+	// tags take precedence over version labels, including latest
+	tt.TestExpectSuccess(t, manager.TagImage(v1, "kurma.io/api", "stable"))
+	tt.TestExpectSuccess(t, manager.TagImage(v1, "kurma.io/api", "latest"))
+	hash, _ := manager.FindImage("kurma.io/api", "stable")
+	tt.TestEqual(t, hash, v1)
+	hash, _ = manager.FindImage("kurma.io/api", "")
+	tt.TestEqual(t, hash, v1)
+
+	// retagging moves the tag
+	tt.TestExpectSuccess(t, manager.TagImage(v2, "kurma.io/api", "stable"))
+	hash, _ = manager.FindImage("kurma.io/api", "stable")
+	tt.TestEqual(t, hash, v2)
+
+	tt.TestExpectError(t, manager.TagImage("sha512-missing", "kurma.io/api", "stable"))
+	tt.TestExpectError(t, manager.TagImage(v1, "Invalid Name", "stable"))
+	tt.TestExpectError(t, manager.TagImage(v1, "kurma.io/api", ""))
+
+	// tags are kept across a rescan and keep images from being pruned
+	tt.TestExpectSuccess(t, manager.Rescan())
+	tt.TestEqual(t, manager.ListTags(), map[string]string{
+		"kurma.io/api:stable": v2,
+		"kurma.io/api:latest": v1,
+	})
+	removed, err := manager.Prune(true)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(removed), 0)
+
+	tt.TestExpectSuccess(t, manager.UntagImage("kurma.io/api", "latest"))
+	tt.TestExpectError(t, manager.UntagImage("kurma.io/api", "latest"))
+	hash, _ = manager.FindImage("kurma.io/api", "")
+	tt.TestEqual(t, hash, v2)
+
+	// deleting an image removes its tags
+	tt.TestExpectSuccess(t, manager.DeleteImage(v2))
+	tt.TestEqual(t, manager.ListTags(), map[string]string{})
+	hash, _ = manager.FindImage("kurma.io/api", "stable")
+	tt.TestEqual(t, hash, "")
+}
@@ -0,0 +1,384 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apcera/kurma/pkg/backend"
+	"github.com/appc/spec/schema/types"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+)
+
+const (
+	// rootPrefixDirectory is the name of the directory the keys trusted for all
+	// images are stored in. Image names must start with an alphanumeric
+	// character, so it can't collide with a prefix.
+	rootPrefixDirectory = "_root"
+
+	// keyExtension is the extension of the armored key files.
+	keyExtension = ".asc"
+)
+
+// ErrNoTrustedKey is returned by CheckSignature when the image isn't signed by
+// any of the keys trusted for it.
+var ErrNoTrustedKey = errors.New("the image is not signed by a trusted key")
+
+// Config is the configuration of the keystore.
+type Config struct {
+	// Directory is where keys added at runtime are persisted.
+	Directory string `json:"directory,omitempty"`
+
+	// TrustedKeys are keys that are trusted through the configuration. They
+	// can't be removed at runtime.
+	TrustedKeys []*ConfigKey `json:"trustedKeys,omitempty"`
+}
+
+// ConfigKey is a key that is trusted through the configuration. Either the
+// path to an armored public key or the armored key itself must be given.
+type ConfigKey struct {
+	Prefix string `json:"prefix"`
+	Path   string `json:"path,omitempty"`
+	Key    string `json:"key,omitempty"`
+}
+
+// Key is the information about a trusted key.
+type Key struct {
+	// Prefix is the image name prefix the key is trusted for. An empty prefix
+	// means the key is trusted for all images.
+	Prefix string
+
+	// Fingerprint is the hex encoded fingerprint of the key's primary key.
+	Fingerprint string
+
+	// Identities are the names of the identities on the key.
+	Identities []string
+
+	// System is whether the key is trusted through the configuration, rather
+	// than having been added at runtime.
+	System bool
+}
+
+// trustedKey is a key along with the prefix it is trusted for.
+type trustedKey struct {
+	prefix string
+	entity *openpgp.Entity
+	system bool
+}
+
+// Keystore holds the OpenPGP keys which are trusted to sign images, each for
+// a prefix of image names.
+type Keystore struct {
+	directory string
+	system    []*trustedKey
+}
+
+// New creates a keystore from the configuration. The directory is created if
+// it doesn't exist.
+func New(config *Config) (*Keystore, error) {
+	ks := &Keystore{directory: config.Directory}
+	if ks.directory != "" {
+		if err := os.MkdirAll(ks.directory, os.FileMode(0755)); err != nil {
+			return nil, fmt.Errorf("failed to create keystore directory: %v", err)
+		}
+	}
+
+	for _, ck := range config.TrustedKeys {
+		prefix, err := normalizePrefix(ck.Prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		entities, err := ck.read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted key for prefix %q: %v", ck.Prefix, err)
+		}
+		for _, entity := range entities {
+			ks.system = append(ks.system, &trustedKey{prefix: prefix, entity: entity, system: true})
+		}
+	}
+	return ks, nil
+}
+
+// read parses the armored key from the configuration.
+func (ck *ConfigKey) read() (openpgp.EntityList, error) {
+	if ck.Path == "" {
+		return openpgp.ReadArmoredKeyRing(strings.NewReader(ck.Key))
+	}
+	f, err := os.Open(ck.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return openpgp.ReadArmoredKeyRing(f)
+}
+
+// AddKey adds the keys in the armored key ring as trusted for the prefix. An
+// empty prefix trusts the keys for all images. It returns the keys that were
+// added.
+func (ks *Keystore) AddKey(prefix string, r io.Reader) ([]*Key, error) {
+	if ks.directory == "" {
+		return nil, fmt.Errorf("no keystore directory is configured")
+	}
+	prefix, err := normalizePrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %v", err)
+	}
+
+	dir := filepath.Join(ks.directory, prefixDirectory(prefix))
+	if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %v", err)
+	}
+
+	keys := make([]*Key, 0, len(entities))
+	for _, entity := range entities {
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := entity.Serialize(w); err != nil {
+			return nil, fmt.Errorf("failed to serialize key: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		tk := &trustedKey{prefix: prefix, entity: entity}
+		key := tk.key()
+		path := filepath.Join(dir, key.Fingerprint+keyExtension)
+		if err := ioutil.WriteFile(path, buf.Bytes(), os.FileMode(0644)); err != nil {
+			return nil, fmt.Errorf("failed to write key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// RemoveKey removes the key with the fingerprint from every prefix it was
+// added to. Keys trusted through the configuration can't be removed.
+func (ks *Keystore) RemoveKey(fingerprint string) error {
+	fingerprint = strings.ToUpper(fingerprint)
+	for _, tk := range ks.system {
+		if tk.fingerprint() == fingerprint {
+			return fmt.Errorf("key %s is trusted through the configuration and can't be removed", fingerprint)
+		}
+	}
+
+	keys, err := ks.loadKeys()
+	if err != nil {
+		return err
+	}
+	removed := false
+	for _, tk := range keys {
+		if tk.fingerprint() != fingerprint {
+			continue
+		}
+		path := filepath.Join(ks.directory, prefixDirectory(tk.prefix), fingerprint+keyExtension)
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove key: %v", err)
+		}
+		removed = true
+	}
+	if !removed {
+		return fmt.Errorf("key %s is not trusted", fingerprint)
+	}
+	return nil
+}
+
+// Keys returns all of the trusted keys.
+func (ks *Keystore) Keys() ([]*Key, error) {
+	trusted, err := ks.trustedKeys()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*Key, 0, len(trusted))
+	for _, tk := range trusted {
+		keys = append(keys, tk.key())
+	}
+	return keys, nil
+}
+
+// CheckSignature verifies the armored detached signature of the image using
+// the keys trusted for the image name. If the name isn't known yet, all of the
+// trusted keys are used and CheckTrusted must be used once it is. It returns
+// the fingerprint of the key that signed the image.
+func (ks *Keystore) CheckSignature(name string, image, signature io.Reader) (string, error) {
+	trusted, err := ks.trustedKeys()
+	if err != nil {
+		return "", err
+	}
+
+	var keyring openpgp.EntityList
+	for _, tk := range trusted {
+		if name == "" || prefixMatches(tk.prefix, name) {
+			keyring = append(keyring, tk.entity)
+		}
+	}
+	if len(keyring) == 0 {
+		return "", ErrNoTrustedKey
+	}
+
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, image, signature)
+	if err == pgperrors.ErrUnknownIssuer {
+		return "", ErrNoTrustedKey
+	} else if err != nil {
+		return "", fmt.Errorf("failed to verify image signature: %v", err)
+	}
+	return fingerprint(signer), nil
+}
+
+// CheckTrusted returns an error unless the image name is signed by the key
+// with the fingerprint and the key is trusted for the name.
+func (ks *Keystore) CheckTrusted(name, fingerprint string) error {
+	if fingerprint == "" {
+		return fmt.Errorf("image %q is not signed", name)
+	}
+
+	trusted, err := ks.trustedKeys()
+	if err != nil {
+		return err
+	}
+	for _, tk := range trusted {
+		if tk.fingerprint() == fingerprint && prefixMatches(tk.prefix, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %q is signed by key %s, which is not trusted for it", name, fingerprint)
+}
+
+// CheckImage returns an error unless the image within the image manager was
+// signed by a key trusted for its name. It is safe to call on a nil keystore,
+// in which case signatures aren't required.
+func (ks *Keystore) CheckImage(hash string, imageManager backend.ImageManager) error {
+	if ks == nil {
+		return nil
+	}
+	manifest := imageManager.GetImage(hash)
+	if manifest == nil {
+		return fmt.Errorf("image %s not found", hash)
+	}
+	return ks.CheckTrusted(manifest.Name.String(), imageManager.ImageSigner(hash))
+}
+
+// trustedKeys returns the keys from the configuration along with those that
+// have been added at runtime.
+func (ks *Keystore) trustedKeys() ([]*trustedKey, error) {
+	keys, err := ks.loadKeys()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]*trustedKey(nil), ks.system...), keys...), nil
+}
+
+// loadKeys reads the keys that have been added at runtime from the keystore
+// directory.
+func (ks *Keystore) loadKeys() ([]*trustedKey, error) {
+	if ks.directory == "" {
+		return nil, nil
+	}
+
+	dirs, err := ioutil.ReadDir(ks.directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore directory: %v", err)
+	}
+
+	var keys []*trustedKey
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		prefix := ""
+		if dir.Name() != rootPrefixDirectory {
+			if prefix, err = url.QueryUnescape(dir.Name()); err != nil {
+				continue
+			}
+		}
+
+		files, err := filepath.Glob(filepath.Join(ks.directory, dir.Name(), "*"+keyExtension))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open key: %v", err)
+			}
+			entities, err := openpgp.ReadArmoredKeyRing(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read key %s: %v", file, err)
+			}
+			for _, entity := range entities {
+				keys = append(keys, &trustedKey{prefix: prefix, entity: entity})
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (tk *trustedKey) fingerprint() string {
+	return fingerprint(tk.entity)
+}
+
+func (tk *trustedKey) key() *Key {
+	key := &Key{
+		Prefix:      tk.prefix,
+		Fingerprint: tk.fingerprint(),
+		System:      tk.system,
+	}
+	for name := range tk.entity.Identities {
+		key.Identities = append(key.Identities, name)
+	}
+	sort.Strings(key.Identities)
+	return key
+}
+
+func fingerprint(entity *openpgp.Entity) string {
+	return fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+}
+
+// normalizePrefix validates the prefix and removes any trailing slash.
+func normalizePrefix(prefix string) (string, error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return "", nil
+	}
+	if _, err := types.NewACIdentifier(prefix); err != nil {
+		return "", fmt.Errorf("invalid prefix %q: %v", prefix, err)
+	}
+	return prefix, nil
+}
+
+// prefixDirectory returns the name of the directory the keys for the prefix
+// are stored in.
+func prefixDirectory(prefix string) string {
+	if prefix == "" {
+		return rootPrefixDirectory
+	}
+	return url.QueryEscape(prefix)
+}
+
+// prefixMatches returns whether the image name falls under the prefix. The
+// prefix must match whole path segments of the name.
+func prefixMatches(prefix, name string) bool {
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package keystore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// newKey generates a key and returns it along with its armored public key.
+func newKey(t *testing.T, name string) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	tt.TestExpectSuccess(t, err)
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	tt.TestExpectSuccess(t, err)
+	tt.TestExpectSuccess(t, entity.Serialize(w))
+	tt.TestExpectSuccess(t, w.Close())
+	return entity, buf.String()
+}
+
+func sign(t *testing.T, entity *openpgp.Entity, data string) string {
+	var buf bytes.Buffer
+	tt.TestExpectSuccess(t, openpgp.ArmoredDetachSign(&buf, entity, strings.NewReader(data), nil))
+	return buf.String()
+}
+
+func TestKeystore(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	system, systemArmored := newKey(t, "system")
+	entity, armored := newKey(t, "example")
+
+	ks, err := New(&Config{
+		Directory:   tt.TempDir(t),
+		TrustedKeys: []*ConfigKey{{Prefix: "", Key: systemArmored}},
+	})
+	tt.TestExpectSuccess(t, err)
+
+	keys, err := ks.AddKey("example.com/apps/", strings.NewReader(armored))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(keys), 1)
+	tt.TestEqual(t, keys[0].Prefix, "example.com/apps")
+	tt.TestEqual(t, keys[0].Identities, []string{"example <example@example.com>"})
+
+	keys, err = ks.Keys()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(keys), 2)
+	tt.TestEqual(t, keys[0].System, true)
+	fingerprint := keys[1].Fingerprint
+
+	// The signature is verified and the signer returned.
+	signer, err := ks.CheckSignature("example.com/apps/web", strings.NewReader("image"), strings.NewReader(sign(t, entity, "image")))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, signer, fingerprint)
+
+	// Tampered images fail.
+	_, err = ks.CheckSignature("example.com/apps/web", strings.NewReader("tampered"), strings.NewReader(sign(t, entity, "image")))
+	tt.TestExpectError(t, err)
+
+	// The key is only trusted for its prefix.
+	_, err = ks.CheckSignature("example.com/other", strings.NewReader("image"), strings.NewReader(sign(t, entity, "image")))
+	tt.TestEqual(t, err, ErrNoTrustedKey)
+	tt.TestExpectSuccess(t, ks.CheckTrusted("example.com/apps/web", fingerprint))
+	tt.TestExpectError(t, ks.CheckTrusted("example.com/appsweb", fingerprint))
+	tt.TestExpectError(t, ks.CheckTrusted("example.com/apps/web", ""))
+
+	// The key from the configuration is trusted for everything.
+	signer, err = ks.CheckSignature("example.com/other", strings.NewReader("image"), strings.NewReader(sign(t, system, "image")))
+	tt.TestExpectSuccess(t, err)
+	tt.TestExpectSuccess(t, ks.CheckTrusted("example.com/other", signer))
+
+	// Keys from the configuration can't be removed, but others can.
+	tt.TestExpectError(t, ks.RemoveKey(signer))
+	tt.TestExpectSuccess(t, ks.RemoveKey(strings.ToLower(fingerprint)))
+	tt.TestExpectError(t, ks.CheckTrusted("example.com/apps/web", fingerprint))
+	tt.TestExpectError(t, ks.RemoveKey(fingerprint))
+
+	// Invalid prefixes are rejected.
+	_, err = ks.AddKey("Not A Prefix", strings.NewReader(armored))
+	tt.TestExpectError(t, err)
+}
@@ -0,0 +1,211 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package logfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// TimestampFormat is the format used for the timestamp that prefixes each
+	// line written through a timestamp writer.
+	TimestampFormat = time.RFC3339Nano
+
+	// followInterval is how often a followed file is checked for new content
+	// once the end of it has been reached.
+	followInterval = 250 * time.Millisecond
+)
+
+// Options is used to control which portion of a log file is returned by Copy.
+type Options struct {
+	// Tail is the number of lines from the end of the file to return. Zero
+	// returns the entire file.
+	Tail int
+
+	// Since excludes any lines with a timestamp older than the specified time.
+	// It only applies to timestamped log files.
+	Since time.Time
+
+	// Timestamped specifies whether the lines in the file are prefixed with a
+	// timestamp written by a timestamp writer.
+	Timestamped bool
+
+	// Timestamps specifies whether the timestamp prefixes should be kept in the
+	// output. When false, they're stripped from each line.
+	Timestamps bool
+
+	// Follow specifies whether to continue to stream new content once the end of
+	// the file has been reached.
+	Follow bool
+
+	// Stop is used to end following the file. Once closed, any remaining content
+	// is written out and Copy returns. A nil channel follows indefinitely.
+	Stop <-chan struct{}
+}
+
+// timestampWriter prefixes each line written to it with the current time.
+type timestampWriter struct {
+	w           io.Writer
+	mutex       sync.Mutex
+	atLineStart bool
+}
+
+// NewTimestampWriter returns an io.Writer which will prefix each line written
+// to the underlying writer with the time it was written. It is safe for use
+// from multiple goroutines, so that both stdout and stderr of a process can
+// share it.
+func NewTimestampWriter(w io.Writer) io.Writer {
+	return &timestampWriter{w: w, atLineStart: true}
+}
+
+func (tw *timestampWriter) Write(p []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+
+	var buf bytes.Buffer
+	prefix := time.Now().UTC().Format(TimestampFormat) + " "
+	for _, b := range p {
+		if tw.atLineStart {
+			buf.WriteString(prefix)
+			tw.atLineStart = false
+		}
+		buf.WriteByte(b)
+		if b == '\n' {
+			tw.atLineStart = true
+		}
+	}
+
+	if _, err := tw.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Copy writes the content of the specified log file to the writer based on the
+// provided options.
+func Copy(w io.Writer, filename string, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	// Read through the existing content, retaining only the tail if one was
+	// requested.
+	var lines [][]byte
+	var partial []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			partial = line
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read log file: %v", err)
+		}
+		if !opts.include(line) {
+			continue
+		}
+		lines = append(lines, line)
+		if opts.Tail > 0 && len(lines) > opts.Tail {
+			lines = lines[1:]
+		}
+	}
+	for _, line := range lines {
+		if _, err := w.Write(opts.format(line)); err != nil {
+			return err
+		}
+	}
+
+	if !opts.Follow {
+		if len(partial) > 0 && opts.include(partial) {
+			_, err := w.Write(opts.format(partial))
+			return err
+		}
+		return nil
+	}
+
+	// Continue to check for new content until told to stop. A final pass is made
+	// after stopping to ensure any remaining content is flushed.
+	stopped := false
+	for {
+		line, err := r.ReadBytes('\n')
+		partial = append(partial, line...)
+		if err == nil {
+			if opts.include(partial) {
+				if _, err := w.Write(opts.format(partial)); err != nil {
+					return err
+				}
+			}
+			partial = nil
+			continue
+		} else if err != io.EOF {
+			return fmt.Errorf("failed to read log file: %v", err)
+		}
+
+		if stopped {
+			if len(partial) > 0 && opts.include(partial) {
+				_, err := w.Write(opts.format(partial))
+				return err
+			}
+			return nil
+		}
+
+		select {
+		case <-opts.Stop:
+			stopped = true
+		case <-time.After(followInterval):
+		}
+	}
+}
+
+// include returns whether the specified line should be included based on the
+// since time.
+func (opts *Options) include(line []byte) bool {
+	if !opts.Timestamped || opts.Since.IsZero() {
+		return true
+	}
+	ts, _, ok := splitTimestamp(line)
+	if !ok {
+		return true
+	}
+	return !ts.Before(opts.Since)
+}
+
+// format returns the line as it should be written out, stripping the timestamp
+// if it isn't desired.
+func (opts *Options) format(line []byte) []byte {
+	if !opts.Timestamped || opts.Timestamps {
+		return line
+	}
+	_, rest, ok := splitTimestamp(line)
+	if !ok {
+		return line
+	}
+	return rest
+}
+
+// splitTimestamp parses the timestamp prefix off of a line, returning the time,
+// the remainder of the line, and whether it could be successfully parsed.
+func splitTimestamp(line []byte) (time.Time, []byte, bool) {
+	i := bytes.IndexByte(line, ' ')
+	if i < 0 {
+		return time.Time{}, nil, false
+	}
+	ts, err := time.Parse(TimestampFormat, string(line[:i]))
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	return ts, line[i+1:], true
+}
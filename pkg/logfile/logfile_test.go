@@ -0,0 +1,100 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package logfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestTimestampWriter(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	var buf bytes.Buffer
+	w := NewTimestampWriter(&buf)
+
+	_, err := w.Write([]byte("one\ntw"))
+	tt.TestExpectSuccess(t, err)
+	_, err = w.Write([]byte("o\nthree\n"))
+	tt.TestExpectSuccess(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	tt.TestEqual(t, len(lines), 3)
+	for i, expected := range []string{"one", "two", "three"} {
+		_, rest, ok := splitTimestamp([]byte(lines[i]))
+		tt.TestEqual(t, ok, true, "line should have a timestamp: ", lines[i])
+		tt.TestEqual(t, string(rest), expected)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	now := time.Now().UTC()
+	content := strings.Join([]string{
+		now.Add(-3*time.Minute).Format(TimestampFormat) + " first",
+		now.Add(-2*time.Minute).Format(TimestampFormat) + " second",
+		now.Add(-1*time.Minute).Format(TimestampFormat) + " third",
+		"",
+	}, "\n")
+
+	filename := filepath.Join(tt.TempDir(t), "app")
+	tt.TestExpectSuccess(t, ioutil.WriteFile(filename, []byte(content), os.FileMode(0644)))
+
+	var buf bytes.Buffer
+	tt.TestExpectSuccess(t, Copy(&buf, filename, &Options{Timestamped: true}))
+	tt.TestEqual(t, buf.String(), "first\nsecond\nthird\n")
+
+	buf.Reset()
+	tt.TestExpectSuccess(t, Copy(&buf, filename, &Options{Timestamped: true, Tail: 2}))
+	tt.TestEqual(t, buf.String(), "second\nthird\n")
+
+	buf.Reset()
+	tt.TestExpectSuccess(t, Copy(&buf, filename, &Options{Timestamped: true, Since: now.Add(-90 * time.Second)}))
+	tt.TestEqual(t, buf.String(), "third\n")
+
+	buf.Reset()
+	tt.TestExpectSuccess(t, Copy(&buf, filename, &Options{Timestamped: true, Timestamps: true, Tail: 1}))
+	tt.TestEqual(t, buf.String(), now.Add(-1*time.Minute).Format(TimestampFormat)+" third\n")
+}
+
+func TestCopyFollow(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	filename := filepath.Join(tt.TempDir(t), "app")
+	f, err := os.Create(filename)
+	tt.TestExpectSuccess(t, err)
+	defer f.Close()
+	_, err = f.WriteString("first\n")
+	tt.TestExpectSuccess(t, err)
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	var buf bytes.Buffer
+	go func() {
+		done <- Copy(&buf, filename, &Options{Follow: true, Stop: stop})
+	}()
+
+	time.Sleep(followInterval)
+	_, err = f.WriteString("second\nthird")
+	tt.TestExpectSuccess(t, err)
+	close(stop)
+
+	select {
+	case err := <-done:
+		tt.TestExpectSuccess(t, err)
+	case <-time.After(5 * time.Second):
+		tt.Fatalf(t, "timed out waiting for follow to stop")
+	}
+	tt.TestEqual(t, buf.String(), "first\nsecond\nthird")
+}
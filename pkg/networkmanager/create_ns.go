@@ -4,10 +4,14 @@ package networkmanager
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"runtime"
 	"syscall"
+
+	"github.com/apcera/util/proc"
+	"github.com/containernetworking/cni/pkg/ns"
 )
 
 func init() {
@@ -81,3 +85,30 @@ func deleteNetworkNamespace(bindpath string) error {
 	}
 	return nil
 }
+
+// isMountPoint returns whether the specified path is currently a mount point.
+func isMountPoint(path string) (bool, error) {
+	mounted := false
+	err := proc.ParseSimpleProcFile(
+		proc.MountProcFile,
+		nil,
+		func(line int, index int, elem string) error {
+			if index == 1 && elem == path {
+				mounted = true
+			}
+			return nil
+		})
+	return mounted, err
+}
+
+// interfaceExists returns whether the named interface exists within the network
+// namespace bind mounted at the specified path.
+func interfaceExists(bindpath, name string) (bool, error) {
+	exists := false
+	err := ns.WithNetNSPath(bindpath, func(ns.NetNS) error {
+		_, err := net.InterfaceByName(name)
+		exists = err == nil
+		return nil
+	})
+	return exists, err
+}
@@ -44,6 +44,13 @@ func TestCreateNetworkNamespace(t *testing.T) {
 	tt.TestEqual(t, len(nsnics), 1)
 	tt.TestEqual(t, nsnics[0].Name, "lo")
 
+	exists, err := interfaceExists(dest, "lo")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, exists, true)
+	exists, err = interfaceExists(dest, "eth0")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, exists, false)
+
 	tt.TestExpectSuccess(t, deleteNetworkNamespace(dest))
 
 	_, err = os.Stat(dest)
@@ -3,6 +3,7 @@
 package networkmanager
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,6 +25,7 @@ const (
 	callSetup = "/opt/network/setup"
 	callAdd   = "/opt/network/add"
 	callDel   = "/opt/network/del"
+	callCheck = "/opt/network/check"
 )
 
 var (
@@ -57,10 +59,157 @@ func (d *networkDriver) generateArgs(targetPod backend.Pod) []string {
 	return []string{netNsPath, uuid, iface}
 }
 
-// call handles calling into a network plugin with the specific command and
-// arguments. It will process any success/response message and return once done
-// or timed out.
-func (d *networkDriver) call(exec string, args []string, val interface{}) error {
+// plugins returns the configuration of each plugin in the network's chain, or
+// the network's entire configuration if it isn't chained.
+func (d *networkDriver) plugins() []json.RawMessage {
+	if len(d.config.Plugins) > 0 {
+		return d.config.Plugins
+	}
+	return []json.RawMessage{d.config.RawConfig}
+}
+
+// pluginCapabilities returns the capabilities set in a plugin's configuration.
+func pluginCapabilities(plugin json.RawMessage) map[string]bool {
+	var conf struct {
+		Capabilities map[string]bool `json:"capabilities"`
+	}
+	json.Unmarshal(plugin, &conf)
+	return conf.Capabilities
+}
+
+// supportsPortMappings returns whether any of the driver's plugins handle
+// forwarding host ports to the pods it provisions.
+func (d *networkDriver) supportsPortMappings() bool {
+	for _, plugin := range d.plugins() {
+		if pluginCapabilities(plugin)["portMappings"] {
+			return true
+		}
+	}
+	return false
+}
+
+// pluginConfig creates the configuration that is passed to one of the
+// driver's plugins for the pod. Plugins within a chain are given the network's
+// name and CNI version. The result of the previous plugin, or the network's
+// result on del and check, is passed as the "prevResult" as with CNI. If the
+// plugin supports port mappings and the network forwards any, they are added
+// to the "runtimeConfig" of the configuration.
+func (d *networkDriver) pluginConfig(plugin json.RawMessage, mappings []*ntypes.PortMapping, prevResult *ntypes.IPResult) ([]byte, error) {
+	chained := len(d.config.Plugins) > 0
+	if !pluginCapabilities(plugin)["portMappings"] {
+		mappings = nil
+	}
+	if !chained && prevResult == nil && len(mappings) == 0 {
+		return plugin, nil
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(plugin, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the network configuration: %v", err)
+	}
+	if chained {
+		config["name"] = d.config.Name
+		if d.config.CNIVersion != "" {
+			config["cniVersion"] = d.config.CNIVersion
+		}
+	}
+	if prevResult != nil {
+		config["prevResult"] = prevResult
+	}
+	if len(mappings) > 0 {
+		config["runtimeConfig"] = map[string]interface{}{
+			"portMappings": mappings,
+		}
+	}
+	return json.Marshal(config)
+}
+
+// setup is called once the networking pod has started, so the plugin can
+// prepare the host before any pods are provisioned. It is passed the network's
+// entire configuration.
+func (d *networkDriver) setup() error {
+	return d.call(callSetup, nil, d.config.RawConfig, nil)
+}
+
+// add provisions the network on the pod by calling each plugin in the chain in
+// order, passing each the result of the one before it. Plugins which don't
+// return a result pass along the previous one. The result of the last plugin
+// is returned. The port mappings are the host ports the network is to forward
+// to the pod.
+func (d *networkDriver) add(targetPod backend.Pod, mappings []*ntypes.PortMapping) (*ntypes.IPResult, error) {
+	args := d.generateArgs(targetPod)
+	var result *ntypes.IPResult
+	for _, plugin := range d.plugins() {
+		config, err := d.pluginConfig(plugin, mappings, result)
+		if err != nil {
+			return nil, err
+		}
+		var next *ntypes.IPResult
+		if err := d.call(callAdd, args, config, &next); err != nil {
+			return nil, err
+		}
+		if next != nil {
+			result = next
+		}
+	}
+	if result == nil {
+		result = &ntypes.IPResult{}
+	}
+	return result, nil
+}
+
+// del deprovisions the network from the pod by calling each plugin in the
+// chain in reverse order, passing each the network's result. Every plugin is
+// called even if an earlier one fails, and the first error is returned. The
+// port mappings are the host ports the network forwarded to the pod.
+func (d *networkDriver) del(targetPod backend.Pod, mappings []*ntypes.PortMapping, result *ntypes.IPResult) error {
+	args := d.generateArgs(targetPod)
+	plugins := d.plugins()
+	var firstErr error
+	for i := len(plugins) - 1; i >= 0; i-- {
+		config, err := d.pluginConfig(plugins[i], mappings, result)
+		if err == nil {
+			err = d.call(callDel, args, config, nil)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// check verifies the network is still configured on the pod as it was
+// provisioned by calling each plugin in the chain in order, passing each the
+// network's result. If a plugin reports that it can't check the network, the
+// reason it was skipped is returned.
+func (d *networkDriver) check(targetPod backend.Pod, result *ntypes.IPResult) (string, error) {
+	args := d.generateArgs(targetPod)
+	for _, plugin := range d.plugins() {
+		config, err := d.pluginConfig(plugin, result.PortMappings, result)
+		if err != nil {
+			return "", err
+		}
+		var resp *checkResponse
+		if err := d.call(callCheck, args, config, &resp); err != nil {
+			return "", err
+		}
+		if resp != nil && resp.Skipped != "" {
+			return resp.Skipped, nil
+		}
+	}
+	return "", nil
+}
+
+// checkResponse is the optional response from the check executable, used to
+// report that the network couldn't be checked.
+type checkResponse struct {
+	Skipped string `json:"skipped"`
+}
+
+// call handles calling into a network plugin with the specific command,
+// arguments, and configuration. It will process any success/response message
+// and return once done or timed out.
+func (d *networkDriver) call(exec string, args []string, config []byte, val interface{}) error {
 	app := &schema.RunApp{
 		User:  "0",
 		Group: "0",
@@ -75,26 +224,41 @@ func (d *networkDriver) call(exec string, args []string, val interface{}) error
 	if err != nil {
 		return err
 	}
+	stderrr, stderrw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
 
 	// queue the writing of the config
-	var outBytes []byte
+	var outBytes, errBytes []byte
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 
-	process, err := d.manager.networkPod.Enter(d.config.Name, app, stdinr, stdoutw, stdoutw, func() {
+	process, err := d.manager.networkPod.Enter(d.config.Name, app, stdinr, stdoutw, stderrw, func() {
 		// mark done... note, this is ran in a separate goroutine
 		defer wg.Done()
 
 		// close our side of the pipes
 		stdinr.Close()
 		stdoutw.Close()
+		stderrw.Close()
+		defer stdoutr.Close()
+		defer stderrr.Close()
+
+		// read stderr alongside stdout so the plugin can't block on either
+		errch := make(chan []byte)
+		go func() {
+			b, _ := ioutil.ReadAll(stderrr)
+			errch <- b
+		}()
 
 		// writie the json configuration
-		stdinw.Write(d.config.RawConfig)
+		stdinw.Write(config)
 		stdinw.Close()
 
 		// read the response
 		outBytes, _ = ioutil.ReadAll(stdoutr)
+		errBytes = <-errch
 	})
 	if err != nil {
 		return err
@@ -138,15 +302,30 @@ func (d *networkDriver) call(exec string, args []string, val interface{}) error
 		return werr
 	}
 
-	// if exit code wasn't 0, return stderr value as the error
+	// if exit code wasn't 0, return the output as the error
 	if exitCode == 0 {
-		if val != nil {
-			if err := json.Unmarshal(outBytes, &val); err != nil {
+		if val != nil && len(bytes.TrimSpace(outBytes)) > 0 {
+			if err := json.Unmarshal(outBytes, val); err != nil {
 				return fmt.Errorf("failed to unmarshal response: %v -- %s", err, string(outBytes))
 			}
 		}
 	} else {
-		return fmt.Errorf("exited %d: %s", exitCode, string(outBytes))
+		return fmt.Errorf("exited %d: %s", exitCode, callOutput(outBytes, errBytes))
 	}
 	return nil
 }
+
+// callOutput combines the output a plugin wrote to stdout and stderr for use
+// in an error. CNI plugins report errors on stdout, while any other failures
+// are usually written to stderr.
+func callOutput(stdout, stderr []byte) string {
+	stdout, stderr = bytes.TrimSpace(stdout), bytes.TrimSpace(stderr)
+	switch {
+	case len(stdout) == 0:
+		return string(stderr)
+	case len(stderr) == 0:
+		return string(stdout)
+	default:
+		return fmt.Sprintf("%s -- stderr: %s", stdout, stderr)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package networkmanager
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/apcera/kurma/pkg/backend"
+
+	ntypes "github.com/apcera/kurma/pkg/networkmanager/types"
+	tt "github.com/apcera/util/testtool"
+)
+
+// testPod is a pod with only the information the drivers use.
+type testPod struct {
+	backend.Pod
+	uuid         string
+	portMappings []*ntypes.PortMapping
+}
+
+func (p *testPod) UUID() string                        { return p.uuid }
+func (p *testPod) PortMappings() []*ntypes.PortMapping { return p.portMappings }
+
+func TestPluginConfig(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	mappings := []*ntypes.PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}
+	decode := func(b []byte) map[string]interface{} {
+		var config map[string]interface{}
+		tt.TestExpectSuccess(t, json.Unmarshal(b, &config))
+		return config
+	}
+
+	// a single plugin is passed the configuration as is
+	var single *ntypes.NetConf
+	tt.TestExpectSuccess(t, json.Unmarshal([]byte(`{"name":"bridge","type":"bridge"}`), &single))
+	d := &networkDriver{config: single}
+	tt.TestEqual(t, len(d.plugins()), 1)
+	tt.TestEqual(t, d.supportsPortMappings(), false)
+	b, err := d.pluginConfig(d.plugins()[0], mappings, nil)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(b), `{"name":"bridge","type":"bridge"}`)
+
+	// chained plugins get the network's name and version, the previous result,
+	// and the port mappings if they support them
+	var chained *ntypes.NetConf
+	tt.TestExpectSuccess(t, json.Unmarshal([]byte(`{
+		"name": "mynet",
+		"cniVersion": "0.3.1",
+		"plugins": [
+			{"type": "bridge"},
+			{"type": "portmap", "capabilities": {"portMappings": true}}
+		]
+	}`), &chained))
+	d = &networkDriver{config: chained}
+	tt.TestEqual(t, len(d.plugins()), 2)
+	tt.TestEqual(t, d.supportsPortMappings(), true)
+
+	b, err = d.pluginConfig(d.plugins()[0], mappings, nil)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, decode(b), map[string]interface{}{
+		"name":       "mynet",
+		"cniVersion": "0.3.1",
+		"type":       "bridge",
+	})
+
+	prevResult := &ntypes.IPResult{CNIVersion: "0.3.1", Interfaces: []*ntypes.Interface{{Name: "eth0"}}}
+	b, err = d.pluginConfig(d.plugins()[1], mappings, prevResult)
+	tt.TestExpectSuccess(t, err)
+	config := decode(b)
+	tt.TestEqual(t, config["type"], "portmap")
+	tt.TestEqual(t, config["prevResult"].(map[string]interface{})["interfaces"], []interface{}{
+		map[string]interface{}{"name": "eth0"},
+	})
+	tt.TestEqual(t, config["runtimeConfig"], map[string]interface{}{
+		"portMappings": []interface{}{
+			map[string]interface{}{"hostPort": 8080.0, "containerPort": 80.0, "protocol": "tcp"},
+		},
+	})
+}
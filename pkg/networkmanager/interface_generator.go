@@ -28,6 +28,9 @@ func (d *networkDriver) generateInterfaceName(pod backend.Pod, interfaces []*typ
 	}
 
 	cint := buffer.String()
+	if cint == "" {
+		return "", nil
+	}
 
 	for _, res := range interfaces {
 		if res.ContainerInterface == cint {
@@ -4,9 +4,9 @@ package networkmanager
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -22,6 +22,14 @@ import (
 const (
 	netNsVolumeName    = "kurma-network-ns"
 	netNsContainerPath = "/var/lib/kurma/netns"
+	netNsDirectoryName = "kurma-netns"
+	networkPodName     = "kurma-networking"
+
+	// The plugins keep their state, such as the addresses allocated by the IPAM
+	// plugins, under /var/lib/cni. It is kept on a pod volume so that it outlives
+	// the networking pod when it is relaunched or the daemon restarts.
+	netStateVolumeName    = "kurma-network-state"
+	netStateContainerPath = "/var/lib/cni"
 )
 
 // Manager handles the management of the pods running and available on the
@@ -37,23 +45,35 @@ type Manager struct {
 	driversMutex   sync.RWMutex
 	defaultDrivers []string
 
+	// networks is the set of plugin drivers the networking pod was launched
+	// with, in the order they were configured.
+	networks []*backend.NetworkDriver
+
 	podManager backend.PodManager
 }
 
 // New will create and return a new Manager for managing network plugins.
 func New(podManager backend.PodManager) (backend.NetworkManager, error) {
-	netnsPath, err := ioutil.TempDir(os.TempDir(), "kurma-netns")
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate network namespace temp path: %v", err)
+	// The network namespace path is consistent across restarts so that the
+	// namespaces of pods restored after a restart can still be deprovisioned.
+	netnsPath := filepath.Join(os.TempDir(), netNsDirectoryName)
+	if err := os.MkdirAll(netnsPath, os.FileMode(0770)); err != nil {
+		return nil, fmt.Errorf("failed to create network namespace temp path: %v", err)
 	}
 	if err := os.Chmod(netnsPath, os.FileMode(0770)); err != nil {
 		return nil, fmt.Errorf("failed to chmod network namespace temp path: %v", err)
 	}
-	if err := syscall.Mount(netnsPath, netnsPath, "", syscall.MS_BIND, ""); err != nil {
-		return nil, fmt.Errorf("failed to bind mount network namespace temp path: %v", err)
+	mounted, err := isMountPoint(netnsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check network namespace temp path: %v", err)
 	}
-	if err := syscall.Mount("none", netnsPath, "", syscall.MS_SHARED, ""); err != nil {
-		return nil, fmt.Errorf("failed to make network namespace temp path shared: %v", err)
+	if !mounted {
+		if err := syscall.Mount(netnsPath, netnsPath, "", syscall.MS_BIND, ""); err != nil {
+			return nil, fmt.Errorf("failed to bind mount network namespace temp path: %v", err)
+		}
+		if err := syscall.Mount("none", netnsPath, "", syscall.MS_SHARED, ""); err != nil {
+			return nil, fmt.Errorf("failed to make network namespace temp path shared: %v", err)
+		}
 	}
 
 	m := &Manager{
@@ -78,14 +98,51 @@ func (m *Manager) Setup(drivers []*backend.NetworkDriver) error {
 		return nil
 	}
 
+	m.driversMutex.Lock()
+	defer m.driversMutex.Unlock()
+	return m.launch(drivers)
+}
+
+// launch starts the networking pod with the provided set of plugin drivers and
+// gives each plugin the chance to prepare the host. Interfaces provisioned by
+// networks which were already configured are carried over to the new drivers.
+// The driversMutex must be held by the caller.
+func (m *Manager) launch(networks []*backend.NetworkDriver) error {
+	drivers := make(map[string]*networkDriver, len(networks))
+	defaultDrivers := make([]string, 0)
+	for _, driver := range networks {
+		d := &networkDriver{
+			manager:       m,
+			config:        driver.Configuration,
+			podInterfaces: make(map[string]string),
+		}
+		if existing, exists := m.drivers[driver.Configuration.Name]; exists {
+			existing.podInterfacesMutex.RLock()
+			for uuid, iface := range existing.podInterfaces {
+				d.podInterfaces[uuid] = iface
+			}
+			existing.podInterfacesMutex.RUnlock()
+		}
+		if driver.Configuration.Default {
+			defaultDrivers = append(defaultDrivers, driver.Configuration.Name)
+		}
+		drivers[driver.Configuration.Name] = d
+	}
+	m.drivers = drivers
+	m.defaultDrivers = defaultDrivers
+	m.networks = networks
+	if len(networks) == 0 {
+		return nil
+	}
+
 	networkPodManifest, podOptions, err := m.defaultNetworkPod()
 	if err != nil {
 		return fmt.Errorf("failed to generate the default PodManifest: %v", err)
 	}
 
 	// Populate the pod with the apps
-	networkPodManifest.Apps = make([]schema.RuntimeApp, len(drivers))
-	for i, driver := range drivers {
+	networkPodManifest.Apps = make([]schema.RuntimeApp, len(networks))
+	for i, driver := range networks {
 		networkPodManifest.Apps[i].Name = atypes.ACName(driver.Configuration.Name)
 		networkPodManifest.Apps[i].Image = driver.Image
 		networkPodManifest.Apps[i].Mounts = []schema.Mount{
@@ -93,6 +150,10 @@ func (m *Manager) Setup(drivers []*backend.NetworkDriver) error {
 				Volume: atypes.ACName(netNsVolumeName),
 				Path:   netNsContainerPath,
 			},
+			schema.Mount{
+				Volume: atypes.ACName(netStateVolumeName),
+				Path:   netStateContainerPath,
+			},
 		}
 
 		// generate the configuration for the main input
@@ -105,41 +166,161 @@ func (m *Manager) Setup(drivers []*backend.NetworkDriver) error {
 			w.Close()
 		}(stdinw, driver.Configuration.RawConfig)
 		podOptions.ContainerIO[driver.Configuration.Name] = &backend.IOs{Stdin: stdinr}
-
-		// generate the local object
-		d := &networkDriver{
-			manager:       m,
-			config:        driver.Configuration,
-			podInterfaces: make(map[string]string),
-		}
-		m.driversMutex.Lock()
-		if driver.Configuration.Default {
-			m.defaultDrivers = append(m.defaultDrivers, driver.Configuration.Name)
-		}
-		m.drivers[driver.Configuration.Name] = d
-		m.driversMutex.Unlock()
 	}
 
 	// launch it
-	networkPod, err := m.podManager.Create("kurma-networking", networkPodManifest, podOptions)
+	networkPod, err := m.podManager.Create(networkPodName, networkPodManifest, podOptions)
 	if err != nil {
 		return fmt.Errorf("failed to launch network pod: %v", err)
 	}
-	if err := networkPod.WaitForState(time.Minute, backend.RUNNING, backend.STOPPED, backend.ERRORED); err != nil {
+	if err := networkPod.WaitForState(time.Minute, backend.RUNNING, backend.STOPPED, backend.ERRORED, backend.EXITED); err != nil {
+		networkPod.Stop()
 		return fmt.Errorf("failed to wait for network pod to start: %v", err)
 	}
 	if state := networkPod.State(); state != backend.RUNNING {
+		networkPod.Stop()
 		return fmt.Errorf("network pod failed to be running, is in the %v state", state)
 	}
 	m.networkPod = networkPod
 	m.log.Tracef("Network pod provisioned and running: %s", m.networkPod.UUID())
 
+	// give each plugin the chance to prepare the host
+	for _, driver := range m.drivers {
+		if err := driver.setup(); err != nil {
+			m.logDriverError(driver, "Setup", err)
+		}
+	}
+
 	return nil
 }
 
+// relaunch replaces the networking pod with one running the provided set of
+// plugin drivers. The network namespaces of pods are kept on the host, so pods
+// keep their networking while the plugins are restarted, and the plugin state is
+// kept on a volume shared with the new networking pod. If the new networking
+// pod fails to start, the previous set of drivers is launched again. The
+// driversMutex must be held by the caller.
+func (m *Manager) relaunch(networks []*backend.NetworkDriver) error {
+	previous := m.networks
+	if m.networkPod != nil {
+		if err := m.networkPod.Stop(); err != nil {
+			return fmt.Errorf("failed to stop the network pod: %v", err)
+		}
+		m.networkPod = nil
+	}
+
+	err := m.launch(networks)
+	if err == nil {
+		return nil
+	}
+	if rerr := m.launch(previous); rerr != nil {
+		m.log.Errorf("Failed to relaunch the network pod with the previous networks: %v", rerr)
+	}
+	return err
+}
+
+// Networks returns each of the configured networks along with the interfaces
+// they have provisioned on pods.
+func (m *Manager) Networks() []*backend.Network {
+	m.driversMutex.RLock()
+	defer m.driversMutex.RUnlock()
+	m.restoreAllInterfaces()
+
+	networks := make([]*backend.Network, 0, len(m.networks))
+	for _, network := range m.networks {
+		driver := m.drivers[network.Configuration.Name]
+		driver.podInterfacesMutex.RLock()
+		podInterfaces := make(map[string]string, len(driver.podInterfaces))
+		for uuid, iface := range driver.podInterfaces {
+			podInterfaces[uuid] = iface
+		}
+		driver.podInterfacesMutex.RUnlock()
+
+		networks = append(networks, &backend.Network{
+			Driver:        network,
+			PodInterfaces: podInterfaces,
+		})
+	}
+	return networks
+}
+
+// AddNetwork configures a new network, relaunching the networking pod to
+// include its plugin.
+func (m *Manager) AddNetwork(driver *backend.NetworkDriver) error {
+	if driver.Configuration == nil || driver.Configuration.Name == "" {
+		return fmt.Errorf("the network must have a name")
+	}
+
+	m.driversMutex.Lock()
+	defer m.driversMutex.Unlock()
+
+	if _, exists := m.drivers[driver.Configuration.Name]; exists {
+		return fmt.Errorf("network %q already exists", driver.Configuration.Name)
+	}
+	networks := make([]*backend.NetworkDriver, 0, len(m.networks)+1)
+	networks = append(networks, m.networks...)
+	networks = append(networks, driver)
+	return m.relaunch(networks)
+}
+
+// UpdateNetwork replaces the configuration of an existing network, relaunching
+// the networking pod to apply it. Pods the network has already been
+// provisioned on keep their networking, and are deprovisioned using the new
+// configuration.
+func (m *Manager) UpdateNetwork(driver *backend.NetworkDriver) error {
+	if driver.Configuration == nil || driver.Configuration.Name == "" {
+		return fmt.Errorf("the network must have a name")
+	}
+
+	m.driversMutex.Lock()
+	defer m.driversMutex.Unlock()
+
+	if _, exists := m.drivers[driver.Configuration.Name]; !exists {
+		return fmt.Errorf("network %q does not exist", driver.Configuration.Name)
+	}
+	networks := make([]*backend.NetworkDriver, 0, len(m.networks))
+	for _, network := range m.networks {
+		if network.Configuration.Name == driver.Configuration.Name {
+			network = driver
+		}
+		networks = append(networks, network)
+	}
+	return m.relaunch(networks)
+}
+
+// RemoveNetwork removes a network, relaunching the networking pod without its
+// plugin. A network can't be removed while it is still provisioned on any pods.
+func (m *Manager) RemoveNetwork(name string) error {
+	m.driversMutex.Lock()
+	defer m.driversMutex.Unlock()
+
+	driver, exists := m.drivers[name]
+	if !exists {
+		return fmt.Errorf("network %q does not exist", name)
+	}
+	m.restoreAllInterfaces()
+	driver.podInterfacesMutex.RLock()
+	provisioned := len(driver.podInterfaces)
+	driver.podInterfacesMutex.RUnlock()
+	if provisioned > 0 {
+		return fmt.Errorf("network %q is still provisioned on %d pods", name, provisioned)
+	}
+
+	networks := make([]*backend.NetworkDriver, 0, len(m.networks))
+	for _, network := range m.networks {
+		if network.Configuration.Name != name {
+			networks = append(networks, network)
+		}
+	}
+	return m.relaunch(networks)
+}
+
 // Provision handles setting up the networking for a new pod. It is
 // responsible for instrumenting the necessary network plugins for the
-// pod.
+// pod, including passing along any host ports to forward to the pod. If any
+// network which isn't optional fails to be provisioned, the networks already
+// provisioned are removed along with the pod's network namespace, and the
+// error is returned.
 func (m *Manager) Provision(pod backend.Pod, networks []string) (string, []*types.IPResult, error) {
 	m.driversMutex.RLock()
 	defer m.driversMutex.RUnlock()
@@ -158,60 +339,158 @@ func (m *Manager) Provision(pod backend.Pod, networks []string) (string, []*type
 		networks = m.defaultDrivers
 	}
 
+	results, err := m.provisionNetworks(pod, networks)
+	if err != nil {
+		if err := deleteNetworkNamespace(netNsPath); err != nil {
+			m.log.Warnf("Failed to cleanup network namespace for %q: %v", pod.UUID(), err)
+		}
+		return "", nil, err
+	}
+	return netNsPath, results, nil
+}
+
+// provisionNetworks adds each of the networks to the pod. Networks marked as
+// optional are skipped if they fail. If any other network fails, the networks
+// which were already added are deleted and the error is returned. The
+// driversMutex must be held by the caller.
+func (m *Manager) provisionNetworks(pod backend.Pod, networks []string) ([]*types.IPResult, error) {
+	// Host ports can only be forwarded by plugins which support port mappings,
+	// so ensure at least one of the pod's networks will handle them.
+	if len(pod.PortMappings()) > 0 {
+		supported := false
+		for _, network := range networks {
+			if driver, exists := m.drivers[network]; exists && driver.supportsPortMappings() {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return nil, fmt.Errorf("none of the pod's networks support mapping host ports")
+		}
+	}
+
 	results := make([]*types.IPResult, 0)
 
 	for _, network := range networks {
 		driver, exists := m.drivers[network]
 		if !exists {
-			return "", nil, fmt.Errorf("network %q does not exist", network)
+			m.rollback(pod, results)
+			return nil, fmt.Errorf("network %q does not exist", network)
 		}
 
-		iface, err := driver.generateInterfaceName(pod, results)
+		result, err := m.provision(driver, pod, results)
 		if err != nil {
-			m.log.Warnf("Failed to generate interface name: %v", err)
-			continue
+			if driver.config.Optional {
+				m.log.Warnf("Skipping optional network %q on %q: %v", network, pod.UUID(), err)
+				continue
+			}
+			m.rollback(pod, results)
+			return nil, fmt.Errorf("failed to provision network %q: %v", network, err)
+		}
+		m.log.Tracef("Provisioned networking. driver: %q, container: %q", result.Name, result.ContainerInterface)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// provision adds a single network to the pod. If the plugins fail to add it,
+// they are called to delete it so nothing is left half configured. The pod's
+// host ports are forwarded by the first network that supports port mappings,
+// so they are only passed to this network if none of the pod's existing
+// networks forward them. The driversMutex must be held by the caller.
+func (m *Manager) provision(driver *networkDriver, pod backend.Pod, results []*types.IPResult) (*types.IPResult, error) {
+	iface, err := driver.generateInterfaceName(pod, results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate interface name: %v", err)
+	}
+	driver.podInterfacesMutex.Lock()
+	driver.podInterfaces[pod.UUID()] = iface
+	driver.podInterfacesMutex.Unlock()
+
+	var mappings []*types.PortMapping
+	if driver.supportsPortMappings() && !forwardsPorts(results) {
+		mappings = pod.PortMappings()
+	}
+
+	result, err := driver.add(pod, mappings)
+	if err != nil {
+		if err := driver.del(pod, mappings, nil); err != nil {
+			m.logDriverError(driver, "Teardown", err)
 		}
 		driver.podInterfacesMutex.Lock()
-		driver.podInterfaces[pod.UUID()] = iface
+		delete(driver.podInterfaces, pod.UUID())
 		driver.podInterfacesMutex.Unlock()
+		return nil, err
+	}
 
-		var result *types.IPResult
-		if err := m.processDriver(driver, pod, callAdd, &result); err != nil {
-			if err == callTimeout {
-				m.log.Warnf("Provision call on %q timed out", driver.config.Name)
-			} else {
-				m.log.Error(err.Error())
-			}
-			continue
+	result.Name = driver.config.Name
+	result.ContainerInterface = iface
+	result.PortMappings = mappings
+	return result, nil
+}
+
+// forwardsPorts returns whether any of the networks forward host ports to the
+// pod.
+func forwardsPorts(results []*types.IPResult) bool {
+	for _, result := range results {
+		if len(result.PortMappings) > 0 {
+			return true
 		}
+	}
+	return false
+}
 
-		result.Name = driver.config.Name
-		result.ContainerInterface = iface
-		m.log.Tracef("Provisioned networking. driver: %q, container: %q", result.Name, result.ContainerInterface)
-		results = append(results, result)
+// rollback deletes the networks which were provisioned on a pod, in the
+// reverse order they were added, when provisioning the pod fails. The
+// driversMutex must be held by the caller.
+func (m *Manager) rollback(pod backend.Pod, results []*types.IPResult) {
+	for i := len(results) - 1; i >= 0; i-- {
+		driver := m.drivers[results[i].Name]
+		if err := driver.del(pod, results[i].PortMappings, results[i]); err != nil {
+			m.logDriverError(driver, "Teardown", err)
+		}
+		driver.podInterfacesMutex.Lock()
+		delete(driver.podInterfaces, pod.UUID())
+		driver.podInterfacesMutex.Unlock()
 	}
+}
 
-	return netNsPath, results, nil
+// HasNetwork returns whether a network with the specified name has been
+// configured.
+func (m *Manager) HasNetwork(name string) bool {
+	m.driversMutex.RLock()
+	defer m.driversMutex.RUnlock()
+	_, exists := m.drivers[name]
+	return exists
 }
 
 // Deprovision is called when a pod is shutting down to handle any
-// deallocation or cleanup processes that are necessary.
+// deallocation or cleanup processes that are necessary. The port mappings a
+// network forwarded are passed to its plugins again so they can be removed.
 func (m *Manager) Deprovision(pod backend.Pod) error {
 	m.driversMutex.RLock()
 	defer m.driversMutex.RUnlock()
 
 	if m.networkPod != nil {
+		m.restoreInterfaces(pod)
+		results := pod.Networks()
+
 		for _, driver := range m.drivers {
-			if _, exists := driver.podInterfaces[pod.UUID()]; !exists {
+			driver.podInterfacesMutex.RLock()
+			_, exists := driver.podInterfaces[pod.UUID()]
+			driver.podInterfacesMutex.RUnlock()
+			if !exists {
 				continue
 			}
 
-			if err := m.processDriver(driver, pod, callDel, nil); err != nil {
-				if err == callTimeout {
-					m.log.Warnf("Teardown call on %q timed out", driver.config.Name)
-				} else {
-					m.log.Error(err.Error())
-				}
+			result := findResult(results, driver.config.Name)
+			var mappings []*types.PortMapping
+			if result != nil {
+				mappings = result.PortMappings
+			}
+			if err := driver.del(pod, mappings, result); err != nil {
+				m.logDriverError(driver, "Teardown", err)
 			}
 			driver.podInterfacesMutex.Lock()
 			delete(driver.podInterfaces, pod.UUID())
@@ -227,14 +506,154 @@ func (m *Manager) Deprovision(pod backend.Pod) error {
 	return nil
 }
 
-// processDriver handles calling into a individual network plugin to
-// provision/deprovision networking.
-func (m *Manager) processDriver(driver *networkDriver, pod backend.Pod, callCmd string, result interface{}) error {
-	if err := driver.call(callCmd, driver.generateArgs(pod), result); err != nil {
+// Attach provisions an additional network on a running pod, within the network
+// namespace it was provisioned with. If the plugins fail to add the network,
+// they are called to delete it so nothing is left half configured. The
+// network's result is returned so it can be added to the pod's networks.
+func (m *Manager) Attach(pod backend.Pod, network string) (*types.IPResult, error) {
+	m.driversMutex.RLock()
+	defer m.driversMutex.RUnlock()
+
+	if m.networkPod == nil {
+		return nil, fmt.Errorf("networking is not available")
+	}
+	driver, exists := m.drivers[network]
+	if !exists {
+		return nil, fmt.Errorf("network %q does not exist", network)
+	}
+	results := pod.Networks()
+	if findResult(results, network) != nil {
+		return nil, fmt.Errorf("the pod is already attached to network %q", network)
+	}
+
+	result, err := m.provision(driver, pod, results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach network %q: %v", network, err)
+	}
+	m.log.Tracef("Attached networking. driver: %q, container: %q", result.Name, result.ContainerInterface)
+	return result, nil
+}
+
+// Detach deprovisions one of the networks from a running pod. If the plugins
+// fail to delete the network, it remains attached so that it is deprovisioned
+// again with the rest of the pod's networks.
+func (m *Manager) Detach(pod backend.Pod, network string) error {
+	m.driversMutex.RLock()
+	defer m.driversMutex.RUnlock()
+
+	if m.networkPod == nil {
+		return fmt.Errorf("networking is not available")
+	}
+	result := findResult(pod.Networks(), network)
+	if result == nil {
+		return fmt.Errorf("the pod is not attached to network %q", network)
+	}
+	driver, exists := m.drivers[network]
+	if !exists {
+		return fmt.Errorf("network %q does not exist", network)
+	}
+	m.restoreInterfaces(pod)
+
+	if err := driver.del(pod, result.PortMappings, result); err != nil {
+		return fmt.Errorf("failed to detach network %q: %v", network, err)
+	}
+	driver.podInterfacesMutex.Lock()
+	delete(driver.podInterfaces, pod.UUID())
+	driver.podInterfacesMutex.Unlock()
+	m.log.Tracef("Detached networking. driver: %q, container: %q", result.Name, result.ContainerInterface)
+	return nil
+}
+
+// Check verifies that each of the networks provisioned on a pod are still
+// configured as they were provisioned. It ensures each network's interface
+// still exists within the pod, and then has the network's plugins check it. An
+// error is returned describing any networks that failed the check, while
+// networks whose plugins can't check them are logged as skipped.
+func (m *Manager) Check(pod backend.Pod) error {
+	m.driversMutex.RLock()
+	defer m.driversMutex.RUnlock()
+
+	if m.networkPod == nil {
+		return nil
+	}
+	m.restoreInterfaces(pod)
+
+	var failures []string
+	for _, result := range pod.Networks() {
+		driver, exists := m.drivers[result.Name]
+		if !exists {
+			failures = append(failures, fmt.Sprintf("network %q is no longer configured", result.Name))
+			continue
+		}
+		if result.ContainerInterface != "" {
+			exists, err := interfaceExists(filepath.Join(m.netNsPath, pod.UUID()), result.ContainerInterface)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("network %q could not be checked: %v", result.Name, err))
+				continue
+			} else if !exists {
+				failures = append(failures, fmt.Sprintf("network %q interface %q no longer exists", result.Name, result.ContainerInterface))
+				continue
+			}
+		}
+		skipped, err := driver.check(pod, result)
 		if err == callTimeout {
-			return err
+			failures = append(failures, fmt.Sprintf("network %q check timed out", result.Name))
+		} else if err != nil {
+			failures = append(failures, fmt.Sprintf("network %q failed its check: %v", result.Name, err))
+		} else if skipped != "" {
+			m.log.Warnf("Network %q was not checked on pod %s: %s", result.Name, pod.UUID(), skipped)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// restoreInterfaces records the interfaces of a pod from its network results
+// on each driver that doesn't know of them. Pods restored after a restart were
+// provisioned by a previous instance, so their interfaces are only known from
+// the results. The driversMutex must be held by the caller.
+func (m *Manager) restoreInterfaces(pod backend.Pod) {
+	for _, result := range pod.Networks() {
+		driver, exists := m.drivers[result.Name]
+		if !exists || result.ContainerInterface == "" {
+			continue
+		}
+		driver.podInterfacesMutex.Lock()
+		if _, exists := driver.podInterfaces[pod.UUID()]; !exists {
+			driver.podInterfaces[pod.UUID()] = result.ContainerInterface
+		}
+		driver.podInterfacesMutex.Unlock()
+	}
+}
+
+// restoreAllInterfaces records the interfaces of every pod from their network
+// results, so that pods restored after a restart are included in the
+// interfaces each driver has provisioned. The driversMutex must be held by the
+// caller.
+func (m *Manager) restoreAllInterfaces() {
+	for _, pod := range m.podManager.Pods() {
+		m.restoreInterfaces(pod)
+	}
+}
+
+// findResult returns the result for the named network, or nil if it isn't in
+// the results.
+func findResult(results []*types.IPResult, name string) *types.IPResult {
+	for _, result := range results {
+		if result.Name == name {
+			return result
 		}
-		return fmt.Errorf("Failed to provision network with %q: %v", driver.config.Name, err)
 	}
 	return nil
 }
+
+// logDriverError logs the failure of a call to a network plugin.
+func (m *Manager) logDriverError(driver *networkDriver, call string, err error) {
+	if err == callTimeout {
+		m.log.Warnf("%s call on %q timed out", call, driver.config.Name)
+	} else {
+		m.log.Errorf("%s call on %q failed: %v", call, driver.config.Name, err)
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package networkmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/logray"
+	"github.com/appc/spec/schema"
+
+	ntypes "github.com/apcera/kurma/pkg/networkmanager/types"
+	kschema "github.com/apcera/kurma/schema"
+	tt "github.com/apcera/util/testtool"
+)
+
+// testPodManager launches networking pods which don't run anything, recording
+// the apps and manifest each was launched with.
+type testPodManager struct {
+	backend.PodManager
+	launched  [][]string
+	manifests []*schema.PodManifest
+	fail      bool
+	pods      []backend.Pod
+}
+
+func (pm *testPodManager) Create(name string, manifest *schema.PodManifest, options *backend.PodOptions) (backend.Pod, error) {
+	apps := make([]string, 0, len(manifest.Apps))
+	for _, app := range manifest.Apps {
+		apps = append(apps, app.Name.String())
+	}
+	pm.launched = append(pm.launched, apps)
+	pm.manifests = append(pm.manifests, manifest)
+	if pm.fail {
+		return nil, fmt.Errorf("failed to launch")
+	}
+	return &testNetworkPod{testPod: testPod{uuid: fmt.Sprintf("network-%d", len(pm.launched))}}, nil
+}
+
+func (pm *testPodManager) Pods() []backend.Pod { return pm.pods }
+
+// testNetworkPod is a running networking pod. Plugins are called by running
+// the shell script for the app and executable, if there is one.
+type testNetworkPod struct {
+	testPod
+	stopped bool
+	scripts map[string]string
+	calls   []string
+}
+
+func (p *testNetworkPod) State() backend.PodState { return backend.RUNNING }
+func (p *testNetworkPod) Stop() error             { p.stopped = true; return nil }
+func (p *testNetworkPod) WaitForState(timeout time.Duration, states ...backend.PodState) error {
+	return nil
+}
+func (p *testNetworkPod) Enter(appName string, app *kschema.RunApp, stdin io.Reader, stdout, stderr io.Writer, postStart func()) (*os.Process, error) {
+	call := fmt.Sprintf("%s %s", appName, filepath.Base(app.Exec[0]))
+	script, exists := p.scripts[call]
+	if !exists {
+		return nil, fmt.Errorf("plugins can't be called")
+	}
+	p.calls = append(p.calls, call)
+
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go postStart()
+	return cmd.Process, nil
+}
+
+// testRestoredPod is a pod provisioned before a restart, whose interfaces are
+// only known from its network results.
+type testRestoredPod struct {
+	testPod
+	networks []*ntypes.IPResult
+}
+
+func (p *testRestoredPod) Networks() []*ntypes.IPResult { return p.networks }
+
+func testDriver(t *testing.T, config string) *backend.NetworkDriver {
+	var conf *ntypes.NetConf
+	tt.TestExpectSuccess(t, json.Unmarshal([]byte(config), &conf))
+	return &backend.NetworkDriver{Configuration: conf}
+}
+
+// testPluginState checks that the networking pod keeps the plugin state on a
+// pod volume mounted into each of its apps.
+func testPluginState(t *testing.T, manifest *schema.PodManifest) {
+	tt.TestEqual(t, len(manifest.Volumes), 1)
+	tt.TestEqual(t, manifest.Volumes[0].Name.String(), netStateVolumeName)
+	for _, app := range manifest.Apps {
+		var mounted bool
+		for _, mount := range app.Mounts {
+			if mount.Volume.String() == netStateVolumeName {
+				tt.TestEqual(t, mount.Path, netStateContainerPath)
+				mounted = true
+			}
+		}
+		tt.TestEqual(t, mounted, true)
+	}
+}
+
+func TestNetworkPodKeepsPluginState(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	podManager := &testPodManager{}
+	m := &Manager{
+		log:            logray.New(),
+		drivers:        make(map[string]*networkDriver),
+		defaultDrivers: make([]string, 0),
+		podManager:     podManager,
+	}
+
+	tt.TestExpectSuccess(t, m.Setup([]*backend.NetworkDriver{
+		testDriver(t, `{"name":"bridge","default":true}`),
+		testDriver(t, `{"name":"overlay"}`),
+	}))
+	tt.TestEqual(t, len(podManager.manifests), 1)
+	testPluginState(t, podManager.manifests[0])
+
+	// the volume must be valid to be written to the stager's manifest
+	_, err := json.Marshal(podManager.manifests[0].Volumes[0])
+	tt.TestExpectSuccess(t, err)
+}
+
+func TestManagerChangeNetworks(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	podManager := &testPodManager{}
+	m := &Manager{
+		log:            logray.New(),
+		drivers:        make(map[string]*networkDriver),
+		defaultDrivers: make([]string, 0),
+		podManager:     podManager,
+	}
+
+	tt.TestExpectSuccess(t, m.Setup([]*backend.NetworkDriver{
+		testDriver(t, `{"name":"bridge","default":true}`),
+	}))
+	firstPod := m.networkPod.(*testNetworkPod)
+
+	// adding a network relaunches the networking pod with its plugin
+	tt.TestExpectSuccess(t, m.AddNetwork(testDriver(t, `{"name":"overlay"}`)))
+	tt.TestEqual(t, firstPod.stopped, true)
+	tt.TestEqual(t, podManager.launched, [][]string{{"bridge"}, {"bridge", "overlay"}})
+	testPluginState(t, podManager.manifests[1])
+	tt.TestEqual(t, m.HasNetwork("overlay"), true)
+	tt.TestEqual(t, m.defaultDrivers, []string{"bridge"})
+	tt.TestExpectError(t, m.AddNetwork(testDriver(t, `{"name":"overlay"}`)))
+	tt.TestExpectError(t, m.AddNetwork(testDriver(t, `{}`)))
+
+	// provisioned interfaces are kept when a network is reconfigured
+	m.drivers["overlay"].podInterfaces["pod-1"] = "veth1"
+	tt.TestExpectSuccess(t, m.UpdateNetwork(testDriver(t, `{"name":"overlay","default":true}`)))
+	tt.TestEqual(t, m.defaultDrivers, []string{"bridge", "overlay"})
+	tt.TestExpectError(t, m.UpdateNetwork(testDriver(t, `{"name":"missing"}`)))
+
+	networks := m.Networks()
+	tt.TestEqual(t, len(networks), 2)
+	tt.TestEqual(t, networks[0].Driver.Configuration.Name, "bridge")
+	tt.TestEqual(t, networks[0].PodInterfaces, map[string]string{})
+	tt.TestEqual(t, networks[1].Driver.Configuration.Name, "overlay")
+	tt.TestEqual(t, networks[1].PodInterfaces, map[string]string{"pod-1": "veth1"})
+
+	// networks still provisioned on pods, including those only known from a
+	// restored pod's results, can't be removed
+	tt.TestExpectError(t, m.RemoveNetwork("overlay"))
+	podManager.pods = []backend.Pod{&testRestoredPod{
+		testPod:  testPod{uuid: "pod-2"},
+		networks: []*ntypes.IPResult{{Name: "bridge", ContainerInterface: "veth0"}},
+	}}
+	tt.TestExpectError(t, m.RemoveNetwork("bridge"))
+	tt.TestExpectError(t, m.RemoveNetwork("missing"))
+
+	podManager.pods = nil
+	delete(m.drivers["bridge"].podInterfaces, "pod-2")
+	delete(m.drivers["overlay"].podInterfaces, "pod-1")
+	tt.TestExpectSuccess(t, m.RemoveNetwork("bridge"))
+	tt.TestEqual(t, m.HasNetwork("bridge"), false)
+	tt.TestEqual(t, m.defaultDrivers, []string{"overlay"})
+
+	// if the new networking pod fails to launch, the previous networks are
+	// launched again
+	podManager.fail = true
+	tt.TestExpectError(t, m.AddNetwork(testDriver(t, `{"name":"bridge"}`)))
+	tt.TestEqual(t, m.HasNetwork("bridge"), false)
+	tt.TestEqual(t, m.HasNetwork("overlay"), true)
+	tt.TestEqual(t, podManager.launched[len(podManager.launched)-1], []string{"overlay"})
+
+	// every relaunched networking pod picks up the same plugin state
+	for _, manifest := range podManager.manifests {
+		testPluginState(t, manifest)
+	}
+}
+
+func TestProvisionRollsBack(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	networkPod := &testNetworkPod{
+		testPod: testPod{uuid: "network"},
+		scripts: map[string]string{
+			"bridge add":  `cat >/dev/null; echo '{"ip4":{"ip":"10.0.0.2/24"}}'`,
+			"bridge del":  `cat >/dev/null`,
+			"overlay add": `cat >/dev/null; echo "no addresses left" >&2; exit 1`,
+			"overlay del": `cat >/dev/null`,
+		},
+	}
+	m := &Manager{
+		log:        logray.New(),
+		networkPod: networkPod,
+		drivers: map[string]*networkDriver{
+			"bridge":  {config: testDriver(t, `{"name":"bridge","containerInterface":"eth0"}`).Configuration},
+			"overlay": {config: testDriver(t, `{"name":"overlay","containerInterface":"eth1"}`).Configuration},
+		},
+	}
+	for _, driver := range m.drivers {
+		driver.manager = m
+		driver.podInterfaces = make(map[string]string)
+	}
+	pod := &testPod{uuid: "146d7cef-fbf6-41da-a2f8-eba218597f9c"}
+
+	// when a network fails, the networks already added are deleted and the
+	// plugin's stderr is included in the error
+	_, err := m.provisionNetworks(pod, []string{"bridge", "overlay"})
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, strings.Contains(err.Error(), `failed to provision network "overlay"`), true)
+	tt.TestEqual(t, strings.Contains(err.Error(), "no addresses left"), true)
+	tt.TestEqual(t, networkPod.calls, []string{"bridge add", "overlay add", "overlay del", "bridge del"})
+	tt.TestEqual(t, m.drivers["bridge"].podInterfaces, map[string]string{})
+	tt.TestEqual(t, m.drivers["overlay"].podInterfaces, map[string]string{})
+
+	// optional networks which fail are skipped
+	networkPod.calls = nil
+	m.drivers["overlay"].config.Optional = true
+	results, err := m.provisionNetworks(pod, []string{"bridge", "overlay"})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(results), 1)
+	tt.TestEqual(t, results[0].Name, "bridge")
+	tt.TestEqual(t, results[0].ContainerInterface, "eth0")
+	tt.TestEqual(t, networkPod.calls, []string{"bridge add", "overlay add", "overlay del"})
+	tt.TestEqual(t, m.drivers["bridge"].podInterfaces, map[string]string{pod.uuid: "eth0"})
+
+	// networks which don't exist fail the pod
+	_, err = m.provisionNetworks(pod, []string{"missing"})
+	tt.TestExpectError(t, err)
+}
+
+func TestCheckSkippedNetworks(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	networkPod := &testNetworkPod{
+		testPod: testPod{uuid: "network"},
+		scripts: map[string]string{
+			"bridge check":  `cat >/dev/null; echo '{"skipped":"CNI version 0.3.1 does not support CHECK"}'`,
+			"overlay check": `cat >/dev/null`,
+		},
+	}
+	m := &Manager{
+		log:        logray.New(),
+		networkPod: networkPod,
+		drivers: map[string]*networkDriver{
+			"bridge":  {config: testDriver(t, `{"name":"bridge"}`).Configuration},
+			"overlay": {config: testDriver(t, `{"name":"overlay"}`).Configuration},
+		},
+	}
+	for _, driver := range m.drivers {
+		driver.manager = m
+		driver.podInterfaces = make(map[string]string)
+	}
+	pod := &testRestoredPod{
+		testPod:  testPod{uuid: "146d7cef-fbf6-41da-a2f8-eba218597f9c"},
+		networks: []*ntypes.IPResult{{Name: "bridge"}, {Name: "overlay"}},
+	}
+
+	// a plugin which can't check the network reports the reason it was skipped
+	skipped, err := m.drivers["bridge"].check(pod, pod.networks[0])
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, skipped, "CNI version 0.3.1 does not support CHECK")
+	skipped, err = m.drivers["overlay"].check(pod, pod.networks[1])
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, skipped, "")
+
+	// skipped networks aren't failures, while failed checks are
+	tt.TestExpectSuccess(t, m.Check(pod))
+	networkPod.scripts["overlay check"] = `cat >/dev/null; echo "interface is down" >&2; exit 1`
+	err = m.Check(pod)
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, strings.Contains(err.Error(), `network "overlay" failed its check`), true)
+	tt.TestEqual(t, strings.Contains(err.Error(), "bridge"), false)
+}
+
+func TestPortMappingsForwardedOnce(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	// each plugin records the configuration it was passed
+	dir := tt.TempDir(t)
+	networkPod := &testNetworkPod{testPod: testPod{uuid: "network"}, scripts: make(map[string]string)}
+	m := &Manager{
+		log:        logray.New(),
+		networkPod: networkPod,
+		drivers:    make(map[string]*networkDriver),
+	}
+	for _, name := range []string{"bridge", "public", "backend"} {
+		config := fmt.Sprintf(`{"name":%q,"type":"portmap","capabilities":{"portMappings":true}}`, name)
+		m.drivers[name] = &networkDriver{
+			config:        testDriver(t, config).Configuration,
+			manager:       m,
+			podInterfaces: make(map[string]string),
+		}
+		for _, call := range []string{"add", "del"} {
+			networkPod.scripts[name+" "+call] = fmt.Sprintf(`cat > %s`, filepath.Join(dir, name+"-"+call))
+		}
+	}
+	mappings := []*ntypes.PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}
+	pod := &testRestoredPod{testPod: testPod{uuid: "146d7cef-fbf6-41da-a2f8-eba218597f9c", portMappings: mappings}}
+	forwarded := func(name, call string) bool {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name+"-"+call))
+		tt.TestExpectSuccess(t, err)
+		return strings.Contains(string(b), "runtimeConfig")
+	}
+
+	// only the first of the pod's networks which supports port mappings
+	// forwards the pod's ports
+	results, err := m.provisionNetworks(pod, []string{"bridge", "public"})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, forwarded("bridge", "add"), true)
+	tt.TestEqual(t, forwarded("public", "add"), false)
+	tt.TestEqual(t, results[0].PortMappings, mappings)
+	tt.TestEqual(t, len(results[1].PortMappings), 0)
+
+	// networks attached later don't forward them again
+	pod.networks = results
+	result, err := m.Attach(pod, "backend")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, forwarded("backend", "add"), false)
+	tt.TestEqual(t, len(result.PortMappings), 0)
+
+	// and they're only removed by the network that forwarded them
+	pod.networks = append(pod.networks, result)
+	tt.TestExpectSuccess(t, m.Deprovision(pod))
+	tt.TestEqual(t, forwarded("bridge", "del"), true)
+	tt.TestEqual(t, forwarded("public", "del"), false)
+	tt.TestEqual(t, forwarded("backend", "del"), false)
+}
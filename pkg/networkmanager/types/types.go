@@ -17,17 +17,24 @@ package types
 
 import (
 	"encoding/json"
+	"net"
 
 	"github.com/containernetworking/cni/pkg/types"
 )
 
-// NetConf describes a network.
+// NetConf describes a network. A network is either configured with a single
+// plugin, in which case the entire configuration is passed to it, or with a
+// chain of plugins under "plugins" which are each called in order.
 type NetConf struct {
-	Name               string          `json:"name,omitempty"`
-	ACI                string          `json:"aci,omitempty"`
-	Default            bool            `json:"default,omitempty"`
-	ContainerInterface string          `json:"containerInterface,omitempty"`
-	RawConfig          json.RawMessage `json:"-"`
+	Name               string            `json:"name,omitempty"`
+	ACI                string            `json:"aci,omitempty"`
+	Default            bool              `json:"default,omitempty"`
+	Optional           bool              `json:"optional,omitempty"`
+	ContainerInterface string            `json:"containerInterface,omitempty"`
+	Capabilities       map[string]bool   `json:"capabilities,omitempty"`
+	CNIVersion         string            `json:"cniVersion,omitempty"`
+	Plugins            []json.RawMessage `json:"plugins,omitempty"`
+	RawConfig          json.RawMessage   `json:"-"`
 }
 
 func (n *NetConf) UnmarshalJSON(data []byte) error {
@@ -39,7 +46,11 @@ func (n *NetConf) UnmarshalJSON(data []byte) error {
 	n.Name = nc.Name
 	n.ACI = nc.ACI
 	n.Default = nc.Default
+	n.Optional = nc.Optional
 	n.ContainerInterface = nc.ContainerInterface
+	n.Capabilities = nc.Capabilities
+	n.CNIVersion = nc.CNIVersion
+	n.Plugins = nc.Plugins
 	n.RawConfig = json.RawMessage(data)
 	return nil
 }
@@ -47,16 +58,74 @@ func (n *NetConf) UnmarshalJSON(data []byte) error {
 // netConf is internal and used to process the part of the configuration we
 // need, while preserving the plugin configuration in NetConf itself.
 type netConf struct {
-	Name               string `json:"name,omitempty"`
-	ACI                string `json:"aci,omitempty"`
-	Default            bool   `json:"default,omitempty"`
-	ContainerInterface string `json:"containerInterface,omitempty"`
+	Name               string            `json:"name,omitempty"`
+	ACI                string            `json:"aci,omitempty"`
+	Default            bool              `json:"default,omitempty"`
+	Optional           bool              `json:"optional,omitempty"`
+	ContainerInterface string            `json:"containerInterface,omitempty"`
+	Capabilities       map[string]bool   `json:"capabilities,omitempty"`
+	CNIVersion         string            `json:"cniVersion,omitempty"`
+	Plugins            []json.RawMessage `json:"plugins,omitempty"`
 }
 
+// IPResult is the result of provisioning a network on a pod. Plugins may
+// return the single IPv4 and IPv6 address of a CNI 0.2 result, or the
+// interfaces, addresses, and routes of a CNI 0.3 result.
 type IPResult struct {
 	Name               string          `json:"name"`
 	ContainerInterface string          `json:"containerInterface"`
+	CNIVersion         string          `json:"cniVersion,omitempty"`
+	Interfaces         []*Interface    `json:"interfaces,omitempty"`
+	IPs                []*IPAddress    `json:"ips,omitempty"`
+	Routes             []types.Route   `json:"routes,omitempty"`
 	IP4                *types.IPConfig `json:"ip4,omitempty"`
 	IP6                *types.IPConfig `json:"ip6,omitempty"`
 	DNS                *types.DNS      `json:"dns,omitempty"`
+
+	// PortMappings are the host ports the network forwards to the pod. Only
+	// one of a pod's networks forwards its ports.
+	PortMappings []*PortMapping `json:"portMappings,omitempty"`
+}
+
+// Interface is an interface created by a plugin, either on the host or within
+// the pod's network namespace.
+type Interface struct {
+	Name    string `json:"name"`
+	Mac     string `json:"mac,omitempty"`
+	Sandbox string `json:"sandbox,omitempty"`
+}
+
+// IPAddress is an address assigned by a plugin. Interface is the index of the
+// interface within the result's Interfaces the address is on.
+type IPAddress struct {
+	Version   string      `json:"version"`
+	Interface *int        `json:"interface,omitempty"`
+	Address   types.IPNet `json:"address"`
+	Gateway   net.IP      `json:"gateway,omitempty"`
+}
+
+// PortMapping forwards a port on the host to a port within a pod. Mappings are
+// passed to plugins which have the "portMappings" capability within the
+// "runtimeConfig" of their configuration, following the CNI conventions.
+type PortMapping struct {
+	HostPort      int    `json:"hostPort"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+	HostIP        string `json:"hostIP,omitempty"`
+}
+
+// Conflicts returns whether both mappings forward the same host port. Mappings
+// without a host IP forward the port on every address on the host.
+func (p *PortMapping) Conflicts(other *PortMapping) bool {
+	if p.HostPort != other.HostPort || p.Protocol != other.Protocol {
+		return false
+	}
+	if p.HostIP == "" || other.HostIP == "" {
+		return true
+	}
+	ip, otherIP := net.ParseIP(p.HostIP), net.ParseIP(other.HostIP)
+	if ip == nil || otherIP == nil {
+		return p.HostIP == other.HostIP
+	}
+	return ip.IsUnspecified() || otherIP.IsUnspecified() || ip.Equal(otherIP)
 }
@@ -23,6 +23,19 @@ func (m *Manager) defaultNetworkPod() (*schema.PodManifest, *backend.PodOptions,
 	}
 	pod.Isolators = []atypes.Isolator{*i}
 
+	// Pod volumes are kept in the pod manager's volume directory, so the plugin
+	// state is shared by every networking pod that is launched.
+	mode, uid, gid := "0755", 0, 0
+	pod.Volumes = []atypes.Volume{
+		atypes.Volume{
+			Name: atypes.ACName(netStateVolumeName),
+			Kind: "empty",
+			Mode: &mode,
+			UID:  &uid,
+			GID:  &gid,
+		},
+	}
+
 	options := &backend.PodOptions{
 		RawVolumes: []atypes.Volume{
 			atypes.Volume{
@@ -31,12 +44,14 @@ func (m *Manager) defaultNetworkPod() (*schema.PodManifest, *backend.PodOptions,
 				Source: m.netNsPath,
 			},
 		},
+		// The mount is recursive so the namespaces of pods which are still
+		// running from before a restart are visible to the network plugins.
 		StagerMounts: []*configs.Mount{
 			&configs.Mount{
 				Source:      m.netNsPath,
 				Destination: filepath.Join("/volumes", netNsVolumeName),
 				Device:      "bind",
-				Flags:       syscall.MS_BIND,
+				Flags:       syscall.MS_BIND | syscall.MS_REC,
 			},
 		},
 		ContainerIO: make(map[string]*backend.IOs),
@@ -0,0 +1,119 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package ociimage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+// imageConfig is the subset of the OCI and Docker image configuration that is
+// carried over to the ACI.
+type imageConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Config       struct {
+		User         string              `json:"User"`
+		ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+		Env          []string            `json:"Env"`
+		Entrypoint   []string            `json:"Entrypoint"`
+		Cmd          []string            `json:"Cmd"`
+		WorkingDir   string              `json:"WorkingDir"`
+	} `json:"config"`
+}
+
+// manifest returns the manifest of the top level image, which carries the
+// image's name and app.
+func (img *image) manifest() (*schema.ImageManifest, error) {
+	manifest := schema.BlankImageManifest()
+	name, err := types.NewACIdentifier(img.name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image name %q: %v", img.name, err)
+	}
+	manifest.Name = *name
+	manifest.Labels = types.Labels{{Name: "version", Value: img.version}}
+	if img.config == nil {
+		return manifest, nil
+	}
+
+	if img.config.OS != "" {
+		manifest.Labels = append(manifest.Labels, types.Label{Name: "os", Value: img.config.OS})
+	}
+	if img.config.Architecture != "" {
+		manifest.Labels = append(manifest.Labels, types.Label{Name: "arch", Value: img.config.Architecture})
+	}
+
+	app, err := img.config.app()
+	if err != nil {
+		return nil, err
+	}
+	manifest.App = app
+	return manifest, nil
+}
+
+// app converts the image's configuration to an ACI app. It returns nil if the
+// image doesn't specify anything to run.
+func (c *imageConfig) app() (*types.App, error) {
+	exec := append(append([]string(nil), c.Config.Entrypoint...), c.Config.Cmd...)
+	if len(exec) == 0 {
+		return nil, nil
+	}
+
+	app := &types.App{
+		Exec:             exec,
+		User:             "0",
+		Group:            "0",
+		WorkingDirectory: c.Config.WorkingDir,
+	}
+	if c.Config.User != "" {
+		parts := strings.SplitN(c.Config.User, ":", 2)
+		app.User = parts[0]
+		if len(parts) == 2 {
+			app.Group = parts[1]
+		}
+	}
+
+	for _, env := range c.Config.Env {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) == 2 {
+			app.Environment.Set(parts[0], parts[1])
+		}
+	}
+
+	ports := make([]string, 0, len(c.Config.ExposedPorts))
+	for port := range c.Config.ExposedPorts {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		p, err := convertPort(port)
+		if err != nil {
+			return nil, err
+		}
+		app.Ports = append(app.Ports, p)
+	}
+	return app, nil
+}
+
+// convertPort converts an exposed port, such as "80/tcp", to an ACI port.
+func convertPort(port string) (types.Port, error) {
+	parts := strings.SplitN(port, "/", 2)
+	protocol := "tcp"
+	if len(parts) == 2 {
+		protocol = parts[1]
+	}
+	n, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return types.Port{}, fmt.Errorf("invalid exposed port %q: %v", port, err)
+	}
+	name, err := types.SanitizeACName(fmt.Sprintf("%d-%s", n, protocol))
+	if err != nil {
+		return types.Port{}, fmt.Errorf("invalid exposed port %q: %v", port, err)
+	}
+	return types.Port{Name: types.ACName(name), Protocol: protocol, Port: uint(n), Count: 1}, nil
+}
@@ -0,0 +1,432 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package ociimage
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	// whiteoutPrefix marks a file within a layer which removes the file of the
+	// same name from the layers beneath it.
+	whiteoutPrefix = ".wh."
+
+	// opaqueWhiteout marks a directory within a layer which hides the contents
+	// of the directory from the layers beneath it.
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+// epoch is the modification time used for the entries written for each ACI.
+var epoch = time.Unix(0, 0)
+
+// fileTree is the set of paths that exist within the layers imported so far.
+type fileTree map[string]bool
+
+// children returns the paths directly within the directory, in order.
+func (t fileTree) children(dir string) []string {
+	var paths []string
+	for p := range t {
+		if path.Dir(p) == dir {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// remove removes the path and everything beneath it.
+func (t fileTree) remove(p string) {
+	delete(t, p)
+	for other := range t {
+		if strings.HasPrefix(other, p+"/") {
+			delete(t, other)
+		}
+	}
+}
+
+// Importer imports the layers of an image from the bottom up, with each layer
+// stored as an ACI depending on the layer beneath it. Layers are expected to
+// be tar files, optionally gzip compressed, using OCI whiteouts.
+type Importer struct {
+	create  ImageCreator
+	lower   fileTree
+	chainID string
+	deps    types.Dependencies
+}
+
+// NewImporter returns an Importer which adds images using create.
+func NewImporter(create ImageCreator) *Importer {
+	return &Importer{create: create, lower: make(fileTree)}
+}
+
+// AddLayer imports the layer on top of the layers imported so far. If digest
+// is set, such as "sha256:<hex>", the layer must match it.
+func (i *Importer) AddLayer(layer, digest string) error {
+	hash, manifest, chainID, err := importLayer(layer, digest, i.chainID, i.deps, i.lower, i.create)
+	if err != nil {
+		return err
+	}
+	imageID, err := types.NewHash(hash)
+	if err != nil {
+		return err
+	}
+	i.deps = types.Dependencies{{ImageName: manifest.Name, ImageID: imageID}}
+	i.chainID = chainID
+	return nil
+}
+
+// Finish adds the top level image with the manifest, which is made to depend
+// on the top layer, and returns its hash and manifest.
+func (i *Importer) Finish(manifest *schema.ImageManifest) (string, *schema.ImageManifest, error) {
+	manifest.Dependencies = i.deps
+	return createACI(i.create, manifest, func(*tar.Writer) error { return nil })
+}
+
+// layerContents describes the contents of a layer.
+type layerContents struct {
+	diffID    string
+	paths     map[string]bool
+	whiteouts []string
+	opaque    []string
+}
+
+// importLayer converts the layer to an ACI depending on the layer beneath it
+// and adds it using create. Whiteouts within the layer are written in the form
+// used by aufs, and opaque directories are converted to a whiteout for each of
+// the paths they hide, so the ACI can be used with either provisioner.
+// It returns the hash and manifest of the ACI along with the layer's chain ID,
+// and updates lower with the paths that exist once it is applied. The layer is
+// verified against the digest, if there is one, each time it is read.
+func importLayer(layer, digest, parentChainID string, deps types.Dependencies, lower fileTree, create ImageCreator) (string, *schema.ImageManifest, string, error) {
+	contents, err := scanLayer(layer, digest)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to read layer: %v", err)
+	}
+
+	// The chain ID identifies the layer along with everything beneath it, so
+	// layers are only shared when they're applied to the same layers.
+	chainID := contents.diffID
+	if parentChainID != "" {
+		chainID = fmt.Sprintf("%x", sha256.Sum256([]byte("sha256:"+parentChainID+" sha256:"+contents.diffID)))
+	}
+
+	manifest := schema.BlankImageManifest()
+	manifest.Name = types.ACIdentifier(layerNamePrefix + chainID)
+	manifest.Dependencies = deps
+
+	hash, manifest, err := createACI(create, manifest, func(tw *tar.Writer) error {
+		return writeLayer(tw, layer, digest, contents, lower)
+	})
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to import layer %s: %v", contents.diffID, err)
+	}
+
+	// record what exists once this layer is applied
+	for _, p := range contents.whiteouts {
+		lower.remove(p)
+	}
+	for _, dir := range contents.opaque {
+		for _, p := range lower.children(dir) {
+			lower.remove(p)
+		}
+	}
+	for p := range contents.paths {
+		lower[p] = true
+	}
+	return hash, manifest, chainID, nil
+}
+
+// scanLayer reads through the layer to find its diff ID, which is the digest
+// of its uncompressed contents, along with the paths and whiteouts within it.
+func scanLayer(layer, digest string) (*layerContents, error) {
+	r, err := openLayer(layer, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	tr := tar.NewReader(io.TeeReader(r, h))
+
+	contents := &layerContents{paths: make(map[string]bool)}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		name, ok := cleanName(header.Name)
+		if !ok {
+			continue
+		}
+		dir, base := path.Split(name)
+		dir = path.Clean(dir)
+		switch {
+		case base == opaqueWhiteout:
+			contents.opaque = append(contents.opaque, dir)
+		case strings.HasPrefix(base, whiteoutPrefix):
+			contents.whiteouts = append(contents.whiteouts, path.Join(dir, base[len(whiteoutPrefix):]))
+		default:
+			contents.paths[name] = true
+		}
+	}
+
+	// read any trailing padding so the digest covers the whole layer
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	if err := r.verify(); err != nil {
+		return nil, err
+	}
+	contents.diffID = fmt.Sprintf("%x", h.Sum(nil))
+	return contents, nil
+}
+
+// writeLayer writes the contents of the layer beneath the rootfs of an ACI,
+// converting its whiteouts. The ACI fails to be written if the layer doesn't
+// match its digest.
+func writeLayer(tw *tar.Writer, layer, digest string, contents *layerContents, lower fileTree) error {
+	r, err := openLayer(layer, digest)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return r.verify()
+		} else if err != nil {
+			return err
+		}
+		name, ok := cleanName(header.Name)
+		if !ok {
+			continue
+		}
+		dir, base := path.Split(name)
+		dir = path.Clean(dir)
+
+		switch {
+		case base == opaqueWhiteout:
+			// hide everything beneath that the layer doesn't replace
+			for _, p := range lower.children(dir) {
+				if contents.paths[p] {
+					continue
+				}
+				if err := writeWhiteout(tw, p, header.ModTime); err != nil {
+					return err
+				}
+			}
+
+		case strings.HasPrefix(base, whiteoutPrefix):
+			if err := writeWhiteout(tw, path.Join(dir, base[len(whiteoutPrefix):]), header.ModTime); err != nil {
+				return err
+			}
+
+		default:
+			header.Name = layerDirectoryPrefix + name
+			if header.Typeflag == tar.TypeDir {
+				header.Name += "/"
+			}
+			if header.Typeflag == tar.TypeLink {
+				linkname, ok := cleanName(header.Linkname)
+				if !ok {
+					return fmt.Errorf("invalid hard link %q", header.Linkname)
+				}
+				header.Linkname = layerDirectoryPrefix + linkname
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeWhiteout writes an aufs whiteout for the path, an empty file with the
+// whiteout prefix. The overlay provisioner converts these when mounting images.
+func writeWhiteout(tw *tar.Writer, p string, modTime time.Time) error {
+	dir, base := path.Split(p)
+	return tw.WriteHeader(&tar.Header{
+		Name:     layerDirectoryPrefix + dir + whiteoutPrefix + base,
+		Mode:     0644,
+		Typeflag: tar.TypeReg,
+		ModTime:  modTime,
+	})
+}
+
+// cleanName returns the path of the entry relative to the root of the layer.
+// It returns false for the root itself or paths that would be outside of it.
+func cleanName(name string) (string, bool) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	return name, name != ""
+}
+
+// layerReader reads the uncompressed contents of a layer, hashing the layer as
+// it was stored while it is read so that it can be verified against its
+// digest.
+type layerReader struct {
+	io.Reader
+	f      *os.File
+	raw    io.Reader
+	hash   hash.Hash
+	digest string
+}
+
+// openLayer opens the layer for reading. If digest is empty, the layer isn't
+// verified.
+func openLayer(layer, digest string) (*layerReader, error) {
+	f, err := os.Open(layer)
+	if err != nil {
+		return nil, err
+	}
+
+	lr := &layerReader{f: f, raw: f, digest: digest}
+	if digest != "" {
+		switch strings.SplitN(digest, ":", 2)[0] {
+		case "sha256":
+			lr.hash = sha256.New()
+		case "sha512":
+			lr.hash = sha512.New()
+		default:
+			f.Close()
+			return nil, fmt.Errorf("unsupported digest %q", digest)
+		}
+		lr.raw = io.TeeReader(f, lr.hash)
+	}
+
+	lr.Reader, err = decompress(lr.raw)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return lr, nil
+}
+
+// verify reads the remainder of the layer and returns an error if it doesn't
+// match its digest.
+func (lr *layerReader) verify() error {
+	if lr.hash == nil {
+		return nil
+	}
+	if _, err := io.Copy(ioutil.Discard, lr.raw); err != nil {
+		return err
+	}
+	algorithm := strings.SplitN(lr.digest, ":", 2)[0]
+	if actual := fmt.Sprintf("%s:%x", algorithm, lr.hash.Sum(nil)); actual != lr.digest {
+		return fmt.Errorf("layer digest %s does not match the expected digest %s", actual, lr.digest)
+	}
+	return nil
+}
+
+func (lr *layerReader) Close() error {
+	return lr.f.Close()
+}
+
+// decompress returns a reader for the uncompressed contents of the layer,
+// which may be gzip compressed.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// extractArchive extracts the OCI layout or Docker archive into the
+// directory. Only the directories, files, and symlinks within it are needed.
+func extractArchive(file, dest string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		name, ok := cleanName(header.Name)
+		if !ok {
+			continue
+		}
+		if err := checkParents(dest, name); err != nil {
+			return err
+		}
+		target := filepath.Join(dest, name)
+		if err := os.MkdirAll(filepath.Dir(target), os.FileMode(0755)); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(0755)); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0644))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// symlinks are resolved within the directory when they're read
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// checkParents returns an error if any of the directories the entry is within
+// is a symlink, which could otherwise be used to write outside of dest.
+func checkParents(dest, name string) error {
+	p := dest
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "." {
+			break
+		}
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%q is within a symlink", name)
+		}
+	}
+	return nil
+}
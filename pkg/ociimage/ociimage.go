@@ -0,0 +1,446 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+// Package ociimage imports OCI image layouts and archives created by "docker
+// save" as ACIs. Each layer is stored as its own ACI which depends on the
+// layer beneath it, so layers shared between images are only stored once and
+// are layered through the normal dependency resolution.
+package ociimage
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	ociLayoutFile      = "oci-layout"
+	ociIndexFile       = "index.json"
+	dockerManifestFile = "manifest.json"
+
+	// refNameAnnotation is the annotation on an OCI index entry which names
+	// the image.
+	refNameAnnotation = "org.opencontainers.image.ref.name"
+
+	ociIndexMediaType    = "application/vnd.oci.image.index.v1+json"
+	dockerListMediaType  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	defaultVersion       = "latest"
+	defaultImageName     = "oci-image"
+	layerNamePrefix      = "oci/layer/sha256-"
+	layerDirectoryPrefix = "rootfs/"
+)
+
+// ImageCreator adds an ACI to an image store and returns its hash and
+// manifest. The CreateImage function of an ImageManager satisfies it.
+type ImageCreator func(io.Reader) (string, *schema.ImageManifest, error)
+
+// image is an image read from an OCI layout or Docker archive.
+type image struct {
+	name    string
+	version string
+	config  *imageConfig
+	layers  []*layerFile
+}
+
+// layerFile is a layer of an image along with its digest, if it is known.
+type layerFile struct {
+	path   string
+	digest string
+}
+
+// descriptor references content within an OCI layout.
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+type ociIndex struct {
+	Manifests []descriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Config descriptor   `json:"config"`
+	Layers []descriptor `json:"layers"`
+}
+
+type dockerManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// IsImage returns whether the path is an OCI image layout or Docker archive,
+// either as a directory or as an uncompressed tar file. ACIs are not.
+func IsImage(file string) bool {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	if fi.IsDir() {
+		return fileExists(filepath.Join(file, ociLayoutFile)) || fileExists(filepath.Join(file, dockerManifestFile))
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			return false
+		}
+		name := path.Clean(header.Name)
+		switch {
+		case name == ociLayoutFile || name == dockerManifestFile:
+			return true
+		case name == "manifest" || name == "rootfs" || strings.HasPrefix(name, layerDirectoryPrefix):
+			return false
+		}
+	}
+}
+
+// Import adds the image within the OCI layout or Docker archive at the path
+// using create. If the path contains more than one image, ref selects the
+// image by its reference name or repository tag. It returns the hash and
+// manifest of the top level image, which depends on the image's layers.
+func Import(file, ref string, create ImageCreator) (string, *schema.ImageManifest, error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir := file
+	if !fi.IsDir() {
+		tmpdir, err := ioutil.TempDir(os.TempDir(), "ociimage")
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to create temp path to extract the image: %v", err)
+		}
+		defer os.RemoveAll(tmpdir)
+		if err := extractArchive(file, tmpdir); err != nil {
+			return "", nil, fmt.Errorf("failed to extract the image: %v", err)
+		}
+		dir = tmpdir
+	}
+
+	img, err := readImage(dir, ref, defaultName(file))
+	if err != nil {
+		return "", nil, err
+	}
+
+	importer := NewImporter(create)
+	for _, layer := range img.layers {
+		if err := importer.AddLayer(layer.path, layer.digest); err != nil {
+			return "", nil, err
+		}
+	}
+
+	manifest, err := img.manifest()
+	if err != nil {
+		return "", nil, err
+	}
+	return importer.Finish(manifest)
+}
+
+// readImage reads the image from the extracted OCI layout or Docker archive.
+func readImage(dir, ref, name string) (*image, error) {
+	if fileExists(filepath.Join(dir, ociIndexFile)) {
+		return readOCIImage(dir, ref, name)
+	}
+	if fileExists(filepath.Join(dir, dockerManifestFile)) {
+		return readDockerImage(dir, ref, name)
+	}
+	return nil, fmt.Errorf("no OCI index or Docker manifest was found")
+}
+
+// readOCIImage reads the image from an OCI layout. Entries within the index
+// which are themselves indexes are resolved to the image for the current
+// platform.
+func readOCIImage(dir, ref, name string) (*image, error) {
+	var index ociIndex
+	if err := readJSON(dir, ociIndexFile, &index); err != nil {
+		return nil, err
+	}
+
+	var candidates []descriptor
+	for _, desc := range index.Manifests {
+		if ref == "" || desc.Annotations[refNameAnnotation] == ref {
+			candidates = append(candidates, desc)
+		}
+	}
+	i, err := selectImage(len(candidates), ref)
+	if err != nil {
+		return nil, err
+	}
+	d := candidates[i]
+
+	img := &image{}
+	img.name, img.version = parseRef(d.Annotations[refNameAnnotation], name)
+
+	for d.MediaType == ociIndexMediaType || d.MediaType == dockerListMediaType {
+		var nested ociIndex
+		if err := readBlob(dir, d.Digest, &nested); err != nil {
+			return nil, err
+		}
+		found := false
+		for _, n := range nested.Manifests {
+			if n.Platform != nil && n.Platform.OS == runtime.GOOS && n.Platform.Architecture == runtime.GOARCH {
+				d, found = n, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no image was found for %s/%s", runtime.GOOS, runtime.GOARCH)
+		}
+	}
+
+	var manifest ociManifest
+	if err := readBlob(dir, d.Digest, &manifest); err != nil {
+		return nil, err
+	}
+	if err := readBlob(dir, manifest.Config.Digest, &img.config); err != nil {
+		return nil, err
+	}
+	for _, layer := range manifest.Layers {
+		p, err := blobPath(dir, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		img.layers = append(img.layers, &layerFile{path: p, digest: layer.Digest})
+	}
+	return img, nil
+}
+
+// readDockerImage reads the image from an archive created by "docker save".
+func readDockerImage(dir, ref, name string) (*image, error) {
+	var manifests []*dockerManifest
+	if err := readJSON(dir, dockerManifestFile, &manifests); err != nil {
+		return nil, err
+	}
+
+	var candidates []*dockerManifest
+	tags := make(map[*dockerManifest]string)
+	for _, m := range manifests {
+		if ref == "" {
+			candidates = append(candidates, m)
+			if len(m.RepoTags) > 0 {
+				tags[m] = m.RepoTags[0]
+			}
+			continue
+		}
+		for _, tag := range m.RepoTags {
+			if tag == ref {
+				candidates = append(candidates, m)
+				tags[m] = tag
+				break
+			}
+		}
+	}
+	i, err := selectImage(len(candidates), ref)
+	if err != nil {
+		return nil, err
+	}
+	m := candidates[i]
+
+	img := &image{}
+	img.name, img.version = parseRef(tags[m], name)
+	if err := readJSON(dir, m.Config, &img.config); err != nil {
+		return nil, err
+	}
+	for _, layer := range m.Layers {
+		p, err := resolvePath(dir, layer)
+		if err != nil {
+			return nil, err
+		}
+		img.layers = append(img.layers, &layerFile{path: p})
+	}
+	return img, nil
+}
+
+// selectImage returns the index of the image to use out of the candidates
+// which matched the ref.
+func selectImage(candidates int, ref string) (int, error) {
+	switch {
+	case candidates == 1:
+		return 0, nil
+	case candidates == 0 && ref != "":
+		return 0, fmt.Errorf("image %q was not found", ref)
+	case candidates == 0:
+		return 0, fmt.Errorf("no images were found")
+	default:
+		return 0, fmt.Errorf("%d images were found, one must be selected by name", candidates)
+	}
+}
+
+// parseRef returns the image name and version from a reference name, which is
+// either a full reference such as "example.com/app:1.0" or only a tag, in
+// which case the default name is used.
+func parseRef(ref, defaultName string) (string, string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if ref == "" {
+		return defaultName, defaultVersion
+	}
+	if !strings.ContainsAny(ref, "/:") {
+		return defaultName, ref
+	}
+
+	name, version := ref, defaultVersion
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		name, version = ref[:i], ref[i+1:]
+	}
+	if sanitized, err := types.SanitizeACIdentifier(name); err == nil {
+		name = sanitized
+	} else {
+		name = defaultName
+	}
+	return name, version
+}
+
+// defaultName returns the name to use for images that aren't named, based on
+// the file they're imported from.
+func defaultName(file string) string {
+	name := filepath.Base(filepath.Clean(file))
+	for _, ext := range []string{".tar", ".oci"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	if sanitized, err := types.SanitizeACIdentifier(name); err == nil {
+		return sanitized
+	}
+	return defaultImageName
+}
+
+// createACI writes an ACI with the manifest and the rootfs produced by the
+// function and adds it using create.
+func createACI(create ImageCreator, manifest *schema.ImageManifest, rootfs func(*tar.Writer) error) (string, *schema.ImageManifest, error) {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(writeACI(w, manifest, rootfs))
+	}()
+	// closing the reader stops the writer if the image is rejected early
+	defer r.Close()
+	return create(r)
+}
+
+// writeACI writes the ACI to the writer. The headers written for the manifest
+// and rootfs are fixed so that the same contents always produce the same ACI.
+func writeACI(w io.Writer, manifest *schema.ImageManifest, rootfs func(*tar.Writer) error) error {
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode image manifest: %v", err)
+	}
+
+	tw := tar.NewWriter(w)
+	header := &tar.Header{
+		Name:     "manifest",
+		Mode:     0644,
+		Size:     int64(len(b)),
+		ModTime:  epoch,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tw.Write(b); err != nil {
+		return err
+	}
+
+	header = &tar.Header{
+		Name:     layerDirectoryPrefix,
+		Mode:     0755,
+		ModTime:  epoch,
+		Typeflag: tar.TypeDir,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if err := rootfs(tw); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func readJSON(dir, name string, v interface{}) error {
+	p, err := resolvePath(dir, name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", name, err)
+	}
+	return nil
+}
+
+func readBlob(dir, digest string, v interface{}) error {
+	p, err := blobPath(dir, digest)
+	if err != nil {
+		return err
+	}
+	rel, _ := filepath.Rel(dir, p)
+	return readJSON(dir, rel, v)
+}
+
+// blobPath returns the path to the blob with the digest within an OCI layout.
+func blobPath(dir, digest string) (string, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || !isDigestPart(parts[0]) || !isDigestPart(parts[1]) {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return resolvePath(dir, filepath.Join("blobs", parts[0], parts[1]))
+}
+
+func isDigestPart(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvePath returns the path to the file within the directory, following
+// any symlinks. It is an error for the file to resolve outside the directory.
+func resolvePath(dir, name string) (string, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(p, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is outside of the image", name)
+	}
+	return p, nil
+}
+
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
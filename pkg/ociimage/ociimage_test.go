@@ -0,0 +1,224 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package ociimage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apcera/kurma/pkg/imagestore"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// layerTar returns a layer containing the files, where an empty value creates
+// a directory.
+func layerTar(t *testing.T, files ...string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		header := &tar.Header{Name: files[i], Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(files[i+1]))}
+		if files[i+1] == "" {
+			header.Mode = 0755
+			header.Typeflag = tar.TypeDir
+		}
+		tt.TestExpectSuccess(t, tw.WriteHeader(header))
+		_, err := tw.Write([]byte(files[i+1]))
+		tt.TestExpectSuccess(t, err)
+	}
+	tt.TestExpectSuccess(t, tw.Close())
+	return buf.Bytes()
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) {
+	tt.TestExpectSuccess(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.FileMode(0755)))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, name), data, os.FileMode(0644)))
+}
+
+func writeJSON(t *testing.T, dir, name string, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	tt.TestExpectSuccess(t, err)
+	writeFile(t, dir, name, b)
+	return b
+}
+
+// tarDirectory archives the directory into a file.
+func tarDirectory(t *testing.T, dir, file string) {
+	f, err := os.Create(file)
+	tt.TestExpectSuccess(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: rel, Mode: 0644, Size: int64(len(b)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		_, err = tw.Write(b)
+		return err
+	})
+	tt.TestExpectSuccess(t, err)
+	tt.TestExpectSuccess(t, tw.Close())
+}
+
+func createManager(t *testing.T) *imagestore.Manager {
+	manager, err := imagestore.New(&imagestore.Options{Directory: tt.TempDir(t)})
+	tt.TestExpectSuccess(t, err)
+	return manager.(*imagestore.Manager)
+}
+
+var testConfig = map[string]interface{}{
+	"architecture": "amd64",
+	"os":           "linux",
+	"config": map[string]interface{}{
+		"User":         "nobody:nogroup",
+		"Env":          []string{"PATH=/bin"},
+		"Entrypoint":   []string{"/bin/app"},
+		"Cmd":          []string{"--serve"},
+		"WorkingDir":   "/srv",
+		"ExposedPorts": map[string]interface{}{"8080/tcp": map[string]interface{}{}},
+	},
+}
+
+func TestImportDockerArchive(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	// two images sharing a base layer
+	dir := tt.TempDir(t)
+	writeFile(t, dir, "base/layer.tar", layerTar(t, "etc/", "", "etc/os-release", "base"))
+	writeFile(t, dir, "app1/layer.tar", layerTar(t, "bin/", "", "bin/app", "one"))
+	writeFile(t, dir, "app2/layer.tar", layerTar(t, "bin/", "", "bin/app", "two"))
+	writeJSON(t, dir, "config.json", testConfig)
+	writeJSON(t, dir, dockerManifestFile, []*dockerManifest{
+		{Config: "config.json", RepoTags: []string{"example.com/app:1"}, Layers: []string{"base/layer.tar", "app1/layer.tar"}},
+		{Config: "config.json", RepoTags: []string{"example.com/app:2"}, Layers: []string{"base/layer.tar", "app2/layer.tar"}},
+	})
+	archive := filepath.Join(tt.TempDir(t), "app.tar")
+	tarDirectory(t, dir, archive)
+	tt.TestEqual(t, IsImage(archive), true)
+
+	manager := createManager(t)
+
+	// the image must be selected when there is more than one
+	_, _, err := Import(archive, "", manager.CreateImage)
+	tt.TestExpectError(t, err)
+
+	hash1, manifest, err := Import(archive, "example.com/app:1", manager.CreateImage)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, manifest.Name.String(), "example.com/app")
+	version, _ := manifest.Labels.Get("version")
+	tt.TestEqual(t, version, "1")
+	tt.TestEqual(t, []string(manifest.App.Exec), []string{"/bin/app", "--serve"})
+	tt.TestEqual(t, manifest.App.User, "nobody")
+	tt.TestEqual(t, manifest.App.Group, "nogroup")
+	tt.TestEqual(t, manifest.App.WorkingDirectory, "/srv")
+	tt.TestEqual(t, len(manifest.App.Ports), 1)
+	tt.TestEqual(t, manifest.App.Ports[0].Port, uint(8080))
+
+	hash2, _, err := Import(archive, "example.com/app:2", manager.CreateImage)
+	tt.TestExpectSuccess(t, err)
+
+	// the base layer is only stored once
+	tt.TestEqual(t, len(manager.ListImages()), 5)
+
+	tree1, err := manager.ResolveTree(hash1)
+	tt.TestExpectSuccess(t, err)
+	tree2, err := manager.ResolveTree(hash2)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(tree1.Order), 3)
+	tt.TestEqual(t, tree1.Order[2], tree2.Order[2])
+	tt.TestNotEqual(t, tree1.Order[1], tree2.Order[1])
+
+	b, err := ioutil.ReadFile(filepath.Join(tree1.Paths[tree1.Order[1]], "bin", "app"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(b), "one")
+
+	// importing again produces the same images
+	again, _, err := Import(archive, "example.com/app:1", manager.CreateImage)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, again, hash1)
+	tt.TestEqual(t, len(manager.ListImages()), 5)
+}
+
+func TestImportOCILayout(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	dir := filepath.Join(tt.TempDir(t), "busybox")
+	blob := func(b []byte) map[string]interface{} {
+		digest := fmt.Sprintf("%x", sha256.Sum256(b))
+		writeFile(t, dir, filepath.Join("blobs", "sha256", digest), b)
+		return map[string]interface{}{"digest": "sha256:" + digest, "size": len(b)}
+	}
+	gzipped := func(b []byte) []byte {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write(b)
+		gw.Close()
+		return buf.Bytes()
+	}
+
+	// the second layer removes a file and hides the contents of a directory
+	base := blob(gzipped(layerTar(t, "etc/", "", "etc/passwd", "root", "var/", "", "var/cache/", "", "var/cache/old", "old")))
+	top := blob(gzipped(layerTar(t, "etc/", "", "etc/.wh.passwd", "", "var/cache/", "", "var/cache/.wh..wh..opq", "", "var/cache/new", "new")))
+	config := blob(writeJSON(t, tt.TempDir(t), "config.json", testConfig))
+	manifest := blob(writeJSON(t, tt.TempDir(t), "manifest.json", map[string]interface{}{
+		"schemaVersion": 2,
+		"config":        config,
+		"layers":        []interface{}{base, top},
+	}))
+	manifest["mediaType"] = "application/vnd.oci.image.manifest.v1+json"
+	manifest["annotations"] = map[string]string{refNameAnnotation: "1.24"}
+	writeJSON(t, dir, ociIndexFile, map[string]interface{}{"schemaVersion": 2, "manifests": []interface{}{manifest}})
+	writeFile(t, dir, ociLayoutFile, []byte(`{"imageLayoutVersion":"1.0.0"}`))
+	tt.TestEqual(t, IsImage(dir), true)
+
+	manager := createManager(t)
+	hash, m, err := Import(dir, "", manager.CreateImage)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, m.Name.String(), "busybox")
+	version, _ := m.Labels.Get("version")
+	tt.TestEqual(t, version, "1.24")
+
+	tree, err := manager.ResolveTree(hash)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(tree.Order), 3)
+
+	// the whiteouts are kept in the aufs form, and the opaque directory is
+	// converted to whiteouts of what it hides
+	rootfs := tree.Paths[tree.Order[1]]
+	for _, p := range []string{"etc/.wh.passwd", "var/cache/.wh.old"} {
+		fi, err := os.Lstat(filepath.Join(rootfs, p))
+		tt.TestExpectSuccess(t, err)
+		tt.TestEqual(t, fi.Mode().IsRegular(), true)
+		tt.TestEqual(t, fi.Size(), int64(0))
+	}
+	_, err = os.Lstat(filepath.Join(rootfs, "var/cache/.wh..wh..opq"))
+	tt.TestEqual(t, os.IsNotExist(err), true)
+	b, err := ioutil.ReadFile(filepath.Join(rootfs, "var/cache/new"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(b), "new")
+
+	// a layer which doesn't match its digest fails the import
+	digest := strings.TrimPrefix(top["digest"].(string), "sha256:")
+	writeFile(t, dir, filepath.Join("blobs", "sha256", digest), gzipped(layerTar(t, "etc/", "", "etc/shadow", "tampered")))
+	_, _, err = Import(dir, "", createManager(t).CreateImage)
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, strings.Contains(err.Error(), "does not match the expected digest sha256:"+digest), true)
+}
@@ -3,6 +3,7 @@
 package podmanager
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/opencontainers/runc/libcontainer"
@@ -23,13 +24,18 @@ func (mf *mockFactory) Create(id string, config *configs.Config) (libcontainer.C
 	mc := &mockContainer{
 		id:     id,
 		config: config,
+		status: libcontainer.Running,
 	}
 	mf.containers[id] = mc
 	return mc, nil
 }
 
 func (mf *mockFactory) Load(id string) (libcontainer.Container, error) {
-	return mf.containers[id], nil
+	mc, exists := mf.containers[id]
+	if !exists {
+		return nil, fmt.Errorf("container %q does not exist", id)
+	}
+	return mc, nil
 }
 
 func (mf *mockFactory) StartInitialization() error {
@@ -43,6 +49,7 @@ func (mf *mockFactory) Type() string {
 type mockContainer struct {
 	id     string
 	config *configs.Config
+	status libcontainer.Status
 }
 
 func (mc *mockContainer) ID() string {
@@ -50,7 +57,7 @@ func (mc *mockContainer) ID() string {
 }
 
 func (mc *mockContainer) Status() (libcontainer.Status, error) {
-	return libcontainer.Running, nil
+	return mc.status, nil
 }
 
 func (mc *mockContainer) State() (*libcontainer.State, error) {
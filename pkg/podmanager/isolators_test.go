@@ -169,3 +169,35 @@ func TestHostApiIsolator(t *testing.T) {
 	tt.TestEqual(t, pod.manifest.Pod.Apps[0].Mounts[0].Volume.String(), "example-kurma-socket")
 	tt.TestEqual(t, pod.manifest.Pod.Apps[0].Mounts[0].Path, "/var/lib/kurma")
 }
+
+func TestUserNamespaceIsolator(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	allocator, err := newIDAllocator(&IDRange{Start: 100000, Size: 2 * userNamespaceSize})
+	tt.TestExpectSuccess(t, err)
+	manager.userNamespaces = allocator
+	pod := createPod(t, manager)
+
+	// Request a user namespace without specifying mappings
+	pod.manifest = &backend.StagerManifest{
+		Pod: &schema.PodManifest{},
+	}
+	isolatorJson := `[{"name":%q,"value":{}}]`
+	err = json.Unmarshal([]byte(fmt.Sprintf(isolatorJson, kschema.LinuxUserNamespaceName)), &pod.manifest.Pod.Isolators)
+	tt.TestExpectSuccess(t, err)
+
+	// Run the setup function and ensure mappings were allocated
+	tt.TestExpectSuccess(t, pod.setupUserNamespaceIsolator())
+	uiso := getUserNamespaceIsolator(pod.manifest.Pod)
+	tt.TestNotEqual(t, uiso, nil)
+	tt.TestEqual(t, uiso.HasMappings(), true)
+	tt.TestEqual(t, uiso.UIDMappings, []kschema.IDMapping{{ContainerID: 0, HostID: 100000, Size: userNamespaceSize}})
+	tt.TestEqual(t, uiso.GIDMappings, uiso.UIDMappings)
+	tt.TestEqual(t, allocator.blocks[0], pod.uuid)
+
+	// Stopping the pod should release the range
+	tt.TestExpectSuccess(t, pod.stoppingUserNamespace())
+	tt.TestEqual(t, allocator.blocks[0], "")
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/apcera/kurma/pkg/apiclient"
 	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/keystore"
 	"github.com/apcera/logray"
 	"github.com/apcera/util/uuid"
 	"github.com/appc/spec/schema"
@@ -27,7 +28,10 @@ type Options struct {
 	LibcontainerDirectory string
 	VolumeDirectory       string
 	DefaultStagerHash     string
+	TrustedStagerHashes   []string
 	RequiredNamespaces    []string
+	UserNamespaceRange    *IDRange
+	Keystore              *keystore.Keystore
 	Log                   *logray.Logger
 	FactoryFunc           func(root string) (libcontainer.Factory, error)
 }
@@ -53,6 +57,10 @@ type Manager struct {
 	podNames map[string]string
 	podsLock sync.RWMutex
 
+	// userNamespaces allocates host ids to pods run within a user namespace. It
+	// is nil if no range has been configured.
+	userNamespaces *idAllocator
+
 	HostSocketFile string
 }
 
@@ -84,6 +92,13 @@ func NewManager(imageManager backend.ImageManager, networkManager backend.Networ
 		podNames:       make(map[string]string),
 	}
 
+	if opts.UserNamespaceRange != nil {
+		m.userNamespaces, err = newIDAllocator(opts.UserNamespaceRange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure user namespaces: %v", err)
+		}
+	}
+
 	return m, nil
 }
 
@@ -108,6 +123,7 @@ func (manager *Manager) validate(manifest *schema.PodManifest) error {
 	}
 
 	// Validate each application
+	apps := make([]*types.App, 0, len(manifest.Apps))
 	for _, runtimeApp := range manifest.Apps {
 		// Ensure we have the image already
 		imageManifest := manager.imageManager.GetImage(runtimeApp.Image.ID.String())
@@ -116,11 +132,41 @@ func (manager *Manager) validate(manifest *schema.PodManifest) error {
 		}
 
 		// See if the runtimeApp specifies an app, or the image manifest
-		if runtimeApp.App != nil || imageManifest.App != nil {
-			continue
+		app := runtimeApp.App
+		if app == nil {
+			app = imageManifest.App
+		}
+		if app == nil {
+			return fmt.Errorf("no App sets in the pod or image manifest for app %q", runtimeApp.Name)
+		}
+
+		// Ensure any capabilities referenced are valid
+		if err := validateCapabilitiesIsolators(app); err != nil {
+			return fmt.Errorf("invalid capabilities for app %q: %v", runtimeApp.Name, err)
 		}
+		apps = append(apps, app)
+	}
+
+	// If a user namespace is requested, ensure it can be provided
+	if err := manager.validateUserNamespace(manifest, apps); err != nil {
+		return err
+	}
 
-		return fmt.Errorf("no App sets in the pod or image manifest for app %q", runtimeApp.Name)
+	// Ensure the resources requested by the pod and its apps can be satisfied
+	if _, err := getResourceLimits(manifest, manager.imageManager.GetImage); err != nil {
+		return err
+	}
+
+	// If an exit policy references an app, ensure it is part of the pod
+	for _, iso := range manifest.Isolators {
+		if iso.Name != kschema.PodExitPolicyName {
+			continue
+		}
+		if piso, ok := iso.Value().(*kschema.PodExitPolicy); ok {
+			if piso.Policy == kschema.ExitPolicyApp && manifest.Apps.Get(piso.App) == nil {
+				return fmt.Errorf("the %s isolator references app %q which is not in the pod", kschema.PodExitPolicyName, piso.App)
+			}
+		}
 	}
 
 	// If the namespaces isolator is specified, validate a minimum set of namespaces
@@ -151,6 +197,74 @@ func (manager *Manager) validate(manifest *schema.PodManifest) error {
 	return nil
 }
 
+// validateUserNamespace ensures that a pod requesting a user namespace can be
+// run within one, and that any explicit mappings don't conflict with the range
+// allocated to pods by the host.
+func (manager *Manager) validateUserNamespace(manifest *schema.PodManifest, apps []*types.App) error {
+	uiso := getUserNamespaceIsolator(manifest)
+	if uiso == nil {
+		return nil
+	}
+
+	for _, iso := range manifest.Isolators {
+		if niso, ok := iso.Value().(*kschema.LinuxNamespaces); ok && niso.User() == kschema.LinuxNamespaceHost {
+			return fmt.Errorf("the %s isolator conflicts with the host user namespace", kschema.LinuxUserNamespaceName)
+		}
+	}
+	for _, app := range apps {
+		if isPrivilegedApp(app) {
+			return fmt.Errorf("privileged apps cannot be run within a user namespace")
+		}
+	}
+
+	if !uiso.HasMappings() {
+		if manager.userNamespaces == nil {
+			return fmt.Errorf("no user namespace range is configured to allocate from")
+		}
+		return nil
+	}
+	if manager.userNamespaces != nil &&
+		(manager.userNamespaces.overlaps(uiso.UIDMappings) || manager.userNamespaces.overlaps(uiso.GIDMappings)) {
+		return fmt.Errorf("the user namespace mappings overlap the range allocated by the host")
+	}
+	return nil
+}
+
+// validateOptions will ensure that the options provided for a pod can be
+// satisfied. The stager must be trusted and any requested networks must exist.
+func (manager *Manager) validateOptions(options *backend.PodOptions) error {
+	if !manager.isTrustedStager(options.StagerHash) {
+		return fmt.Errorf("the stager image %q is not trusted", options.StagerHash)
+	}
+	if manager.imageManager.GetImage(options.StagerHash) == nil {
+		return fmt.Errorf("the stager image %q was not found", options.StagerHash)
+	}
+
+	for _, network := range options.Networks {
+		if manager.networkManager == nil {
+			return fmt.Errorf("networking is not available to attach to network %q", network)
+		}
+		if !manager.networkManager.HasNetwork(network) {
+			return fmt.Errorf("network %q does not exist", network)
+		}
+	}
+	return nil
+}
+
+// isTrustedStager returns whether the specified image hash is allowed to be
+// used as a stager. The default stager is always trusted.
+func (manager *Manager) isTrustedStager(hash string) bool {
+	if hash == manager.Options.DefaultStagerHash {
+		return true
+	}
+	for _, h := range manager.Options.TrustedStagerHashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
 // Create begins launching a pod with the provided image manifest and
 // reader as the source of the ACI.
 func (manager *Manager) Create(name string, manifest *schema.PodManifest, options *backend.PodOptions) (backend.Pod, error) {
@@ -166,6 +280,14 @@ func (manager *Manager) Create(name string, manifest *schema.PodManifest, option
 	if options.StagerHash == "" {
 		options.StagerHash = manager.Options.DefaultStagerHash
 	}
+	if err := manager.validateOptions(options); err != nil {
+		return nil, err
+	}
+	mappings, err := manager.portMappings(manifest, options.PortMappings)
+	if err != nil {
+		return nil, err
+	}
+	options.PortMappings = mappings
 
 	// populate the pod
 	pod := &Pod{
@@ -191,12 +313,16 @@ func (manager *Manager) Create(name string, manifest *schema.PodManifest, option
 	// add it to the manager's map
 	manager.podsLock.Lock()
 
-	// Validate the name isn't taken right before we added. Want to ensure no
-	// races happen between checking and creating.
+	// Validate the name and host ports aren't taken right before we added. Want
+	// to ensure no races happen between checking and creating.
 	if _, exists := manager.podNames[pod.name]; exists {
 		manager.podsLock.Unlock()
 		return nil, fmt.Errorf("a pod with the name %q already exists", pod.name)
 	}
+	if err := manager.portConflict(options.PortMappings); err != nil {
+		manager.podsLock.Unlock()
+		return nil, err
+	}
 
 	manager.pods[pod.uuid] = pod
 	manager.podNames[pod.name] = pod.uuid
@@ -245,7 +371,9 @@ func (manager *Manager) remove(pod *Pod) {
 	manager.podsLock.Lock()
 	pod.mutex.Lock()
 	delete(manager.pods, pod.uuid)
-	delete(manager.podNames, pod.name)
+	if manager.podNames[pod.name] == pod.uuid {
+		delete(manager.podNames, pod.name)
+	}
 	pod.mutex.Unlock()
 	manager.podsLock.Unlock()
 }
@@ -3,7 +3,10 @@
 package podmanager
 
 import (
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/apcera/kurma/pkg/backend"
 	"github.com/apcera/kurma/pkg/backend/mocks"
@@ -21,28 +24,24 @@ func createManager(t *testing.T) *Manager {
 		FactoryFunc:      func(root string) (libcontainer.Factory, error) { return newMockFactory(), nil },
 	}
 
-	manager, err := NewManager(&mocks.ImageManager{}, nil, opts)
+	imageManager := &mocks.ImageManager{
+		AddReferenceFunc:     func(hash, owner string) {},
+		RemoveReferencesFunc: func(owner string) {},
+	}
+	manager, err := NewManager(imageManager, nil, opts)
 	tt.TestExpectSuccess(t, err)
 	return manager.(*Manager)
 }
 
-func TestNewManager(t *testing.T) {
-	tt.StartTest(t)
-	defer tt.FinishTest(t)
-
-	manager := createManager(t)
-	tt.TestNotEqual(t, manager, nil)
-}
-
-func TestCreatePod(t *testing.T) {
-	tt.StartTest(t)
-	defer tt.FinishTest(t)
-
-	manager := createManager(t)
-
+// createSampleManifest stubs the manager's image manager to return an image
+// with the provided isolators, and returns a pod manifest with a single app
+// named "sample" using it.
+func createSampleManifest(manager *Manager, isolators ...types.Isolator) *schema.PodManifest {
 	manager.imageManager.(*mocks.ImageManager).GetImageFunc = func(hash string) *schema.ImageManifest {
 		return &schema.ImageManifest{
-			App: &types.App{},
+			App: &types.App{
+				Isolators: types.Isolators(isolators),
+			},
 		}
 	}
 
@@ -55,10 +54,33 @@ func TestCreatePod(t *testing.T) {
 			},
 		},
 	}
+	return manifest
+}
 
+// replacePodStartup replaces the pod startup functions with the provided ones,
+// so pods can be created without launching a stager. It returns a function to
+// restore the original startup functions.
+func replacePodStartup(funcs ...func(*Pod) error) func() {
 	origPodStartup := podStartup
-	podStartup = nil
-	defer func() { podStartup = origPodStartup }()
+	podStartup = funcs
+	return func() { podStartup = origPodStartup }
+}
+
+func TestNewManager(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	tt.TestNotEqual(t, manager, nil)
+}
+
+func TestCreatePod(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	manifest := createSampleManifest(manager)
+	defer replacePodStartup()()
 
 	pod, err := manager.Create("example", manifest, nil)
 	tt.TestExpectSuccess(t, err)
@@ -79,31 +101,42 @@ func TestCreatePod(t *testing.T) {
 	tt.TestEqual(t, len(manager.Pods()), 0)
 }
 
-func TestCreatePodDuplicateName(t *testing.T) {
+func TestWaitReturnsWhenStartupFails(t *testing.T) {
 	tt.StartTest(t)
 	defer tt.FinishTest(t)
 
 	manager := createManager(t)
+	manifest := createSampleManifest(manager)
+	defer replacePodStartup(func(*Pod) error { return fmt.Errorf("stager failed") })()
 
-	manager.imageManager.(*mocks.ImageManager).GetImageFunc = func(hash string) *schema.ImageManifest {
-		return &schema.ImageManifest{
-			App: &types.App{},
-		}
+	pod, err := manager.Create("example", manifest, nil)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, pod.State(), backend.ERRORED)
+
+	done := make(chan struct{})
+	go func() {
+		pod.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after the pod failed to start")
 	}
+	tt.TestEqual(t, pod.StartupError().Error(), "stager failed")
 
-	manifest := schema.BlankPodManifest()
-	manifest.Apps = []schema.RuntimeApp{
-		schema.RuntimeApp{
-			Name: types.ACName("sample"),
-			Image: schema.RuntimeImage{
-				ID: *types.NewHashSHA512(nil),
-			},
-		},
-	}
+	// stopping the errored pod must not close the wait channel again
+	pod.Stop()
+	pod.Wait()
+}
 
-	origPodStartup := podStartup
-	podStartup = nil
-	defer func() { podStartup = origPodStartup }()
+func TestCreatePodDuplicateName(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	manifest := createSampleManifest(manager)
+	defer replacePodStartup()()
 
 	pod, err := manager.Create("example", manifest, nil)
 	tt.TestExpectSuccess(t, err)
@@ -122,3 +155,90 @@ func TestCreatePodDuplicateName(t *testing.T) {
 	pods = manager.Pods()
 	tt.TestEqual(t, len(pods), 1)
 }
+
+func TestCreatePodValidatesOptions(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	manager.Options.DefaultStagerHash = "sha512-default"
+	manager.Options.TrustedStagerHashes = []string{"sha512-trusted"}
+
+	manifest := createSampleManifest(manager)
+	defer replacePodStartup()()
+
+	// untrusted stager
+	_, err := manager.Create("example", manifest, &backend.PodOptions{StagerHash: "sha512-untrusted"})
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, err.Error(), `the stager image "sha512-untrusted" is not trusted`)
+
+	// networks without a network manager
+	_, err = manager.Create("example", manifest, &backend.PodOptions{Networks: []string{"bridge"}})
+	tt.TestExpectError(t, err)
+
+	// unknown network
+	manager.SetNetworkManager(&mocks.NetworkManager{
+		HasNetworkFunc:  func(name string) bool { return name == "bridge" },
+		DeprovisionFunc: func(pod backend.Pod) error { return nil },
+	})
+	_, err = manager.Create("example", manifest, &backend.PodOptions{Networks: []string{"bridge", "missing"}})
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, err.Error(), `network "missing" does not exist`)
+	tt.TestEqual(t, len(manager.Pods()), 0)
+
+	// trusted stager and existing network
+	pod, err := manager.Create("example", manifest, &backend.PodOptions{
+		StagerHash: "sha512-trusted",
+		Networks:   []string{"bridge"},
+	})
+	tt.TestExpectSuccess(t, err)
+	pod.Stop()
+}
+
+func TestCreatePodValidatesResources(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+
+	// request exceeds the limit
+	var memory types.Isolator
+	err := json.Unmarshal([]byte(`{"name":"resource/memory","value":{"request":"256Mi","limit":"128Mi"}}`), &memory)
+	tt.TestExpectSuccess(t, err)
+	manifest := createSampleManifest(manager, memory)
+	defer replacePodStartup()()
+
+	_, err = manager.Create("example", manifest, nil)
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, len(manager.Pods()), 0)
+}
+
+func TestCreatePodValidatesCapabilities(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+
+	var retain types.Isolator
+	err := json.Unmarshal([]byte(`{"name":"os/linux/capabilities-retain-set","value":{"set":["CAP_NOT_REAL"]}}`), &retain)
+	tt.TestExpectSuccess(t, err)
+	manifest := createSampleManifest(manager, retain)
+	defer replacePodStartup()()
+
+	_, err = manager.Create("example", manifest, nil)
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, err.Error(), `invalid capabilities for app "sample": unknown capability "CAP_NOT_REAL"`)
+
+	// an app can't both retain and remove capabilities
+	var remove types.Isolator
+	err = json.Unmarshal([]byte(`{"name":"os/linux/capabilities-remove-set","value":{"set":["CAP_NET_RAW"]}}`), &remove)
+	tt.TestExpectSuccess(t, err)
+	err = json.Unmarshal([]byte(`{"name":"os/linux/capabilities-retain-set","value":{"set":["CAP_CHOWN"]}}`), &retain)
+	tt.TestExpectSuccess(t, err)
+	manifest = createSampleManifest(manager, remove, retain)
+
+	_, err = manager.Create("example", manifest, nil)
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, err.Error(), `invalid capabilities for app "sample": cannot specify both a capabilities retain set and remove set`)
+	tt.TestEqual(t, len(manager.Pods()), 0)
+}
@@ -3,13 +3,18 @@
 package podmanager
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/backend/mocks"
 	"github.com/apcera/logray"
 	"github.com/apcera/util/uuid"
 	"github.com/appc/spec/schema"
@@ -184,3 +189,179 @@ func TestLaunchStager(t *testing.T) {
 	tt.TestExpectError(t, err)
 	tt.TestEqual(t, err.Error(), "failed to retrieve the pid of the stager process: invalid process")
 }
+
+func TestStoppingReadState(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	pod := createPod(t, manager)
+	tt.TestExpectSuccess(t, pod.startingBaseDirectories())
+
+	_, err := pod.ExitCode()
+	tt.TestExpectError(t, err)
+
+	state := `{"apps":{"migrate":{"exited":true,"exitCode":3}},"state":"exited","exitCode":3}`
+	err = ioutil.WriteFile(filepath.Join(pod.stagerRootPath(), "state.json"), []byte(state), os.FileMode(0600))
+	tt.TestExpectSuccess(t, err)
+
+	tt.TestExpectSuccess(t, pod.stoppingReadState())
+
+	exitCode, err := pod.ExitCode()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, exitCode, 3)
+
+	apps, err := pod.AppStatus()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(apps), 1)
+	tt.TestEqual(t, apps["migrate"].Exited, true)
+	tt.TestEqual(t, apps["migrate"].ExitCode, 3)
+}
+
+func TestAttachDetachNetwork(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	pod := createPod(t, manager)
+	tt.TestExpectSuccess(t, pod.startingBaseDirectories())
+	pod.networkResults = []*ntypes.IPResult{{Name: "default", ContainerInterface: "veth0"}}
+
+	// networking must be available and the pod must be running
+	tt.TestExpectError(t, pod.AttachNetwork("backend"))
+	manager.SetNetworkManager(&mocks.NetworkManager{
+		AttachFunc: func(p backend.Pod, network string) (*ntypes.IPResult, error) {
+			if network != "backend" {
+				return nil, fmt.Errorf("network %q not found", network)
+			}
+			return &ntypes.IPResult{Name: network, ContainerInterface: "veth1"}, nil
+		},
+		DetachFunc: func(p backend.Pod, network string) error { return nil },
+	})
+	tt.TestExpectError(t, pod.AttachNetwork("backend"))
+	pod.state = backend.RUNNING
+
+	tt.TestExpectError(t, pod.AttachNetwork("missing"))
+	tt.TestEqual(t, len(pod.Networks()), 1)
+
+	tt.TestExpectSuccess(t, pod.AttachNetwork("backend"))
+	tt.TestEqual(t, pod.Networks(), []*ntypes.IPResult{
+		{Name: "default", ContainerInterface: "veth0"},
+		{Name: "backend", ContainerInterface: "veth1"},
+	})
+
+	tt.TestExpectSuccess(t, pod.DetachNetwork("default"))
+	tt.TestEqual(t, pod.Networks(), []*ntypes.IPResult{
+		{Name: "backend", ContainerInterface: "veth1"},
+	})
+
+	// the record reflects the attached networks
+	b, err := ioutil.ReadFile(pod.podRecordPath())
+	tt.TestExpectSuccess(t, err)
+	var record podRecord
+	tt.TestExpectSuccess(t, json.Unmarshal(b, &record))
+	tt.TestEqual(t, record.NetworkResults, pod.Networks())
+
+	// pods in the host's network namespace can't change networks
+	pod.skipNetworking = true
+	tt.TestExpectError(t, pod.DetachNetwork("backend"))
+}
+
+func TestAppLogsExitedPod(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	pod := createPod(t, manager)
+	pod.shuttingDownCh = make(chan struct{})
+	pod.waitch = make(chan bool)
+	pod.manifest = &backend.StagerManifest{Name: pod.name, Pod: schema.BlankPodManifest()}
+	pod.manifest.Pod.Apps = schema.AppList{{Name: types.ACName("web")}}
+	tt.TestExpectSuccess(t, pod.startingBaseDirectories())
+	pod.state = backend.RUNNING
+	manager.pods[pod.uuid] = pod
+	manager.podNames[pod.name] = pod.uuid
+
+	logs := "2016-06-01T10:00:00Z starting\n2016-06-01T10:00:05Z exiting\n"
+	tt.TestExpectSuccess(t, os.MkdirAll(filepath.Join(pod.stagerRootPath(), "logs"), os.FileMode(0755)))
+	err := ioutil.WriteFile(filepath.Join(pod.stagerRootPath(), "logs", "web"), []byte(logs), os.FileMode(0644))
+	tt.TestExpectSuccess(t, err)
+
+	// the stager exiting on its own keeps the pod and its logs around
+	pod.exit()
+	pod.Wait()
+	tt.TestEqual(t, pod.State(), backend.EXITED)
+	tt.TestEqual(t, manager.Pod(pod.uuid), pod)
+
+	// the log is read from the stager's filesystem once the stager has exited,
+	// including when following it
+	var buf bytes.Buffer
+	tt.TestExpectSuccess(t, pod.Logs("web", &backend.LogOptions{Follow: true}, &buf, nil))
+	tt.TestEqual(t, buf.String(), "starting\nexiting\n")
+
+	buf.Reset()
+	since, _ := time.Parse(time.RFC3339, "2016-06-01T10:00:01Z")
+	tt.TestExpectSuccess(t, pod.Logs("web", &backend.LogOptions{Since: since, Timestamps: true}, &buf, nil))
+	tt.TestEqual(t, buf.String(), "2016-06-01T10:00:05Z exiting\n")
+
+	tt.TestExpectError(t, pod.Logs("missing", nil, &buf, nil))
+
+	// the stager's log isn't timestamped
+	tt.TestExpectError(t, pod.Logs("", &backend.LogOptions{Since: since}, &buf, nil))
+	tt.TestExpectError(t, pod.Logs("", &backend.LogOptions{Timestamps: true}, &buf, nil))
+
+	// stopping the pod removes it along with its logs
+	tt.TestExpectSuccess(t, pod.Stop())
+	tt.TestEqual(t, pod.State(), backend.STOPPED)
+	tt.TestEqual(t, manager.Pod(pod.uuid), nil)
+	_, err = os.Stat(pod.directory)
+	tt.TestEqual(t, os.IsNotExist(err), true)
+}
+
+func TestStartingDependencySetReferencesImages(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	pod := createPod(t, manager)
+	pod.layerPaths = make(map[string]string)
+	pod.manifest = &backend.StagerManifest{
+		Pod:           schema.BlankPodManifest(),
+		Images:        make(map[string]*schema.ImageManifest),
+		AppImageOrder: make(map[string][]string),
+	}
+	app := types.NewHashSHA512([]byte("app"))
+	base := types.NewHashSHA512([]byte("base")).String()
+	pod.manifest.Pod.Apps = schema.AppList{{Name: types.ACName("web"), Image: schema.RuntimeImage{ID: *app}}}
+
+	// record the calls made, with the base layer removed by garbage collection
+	// while the app is resolved
+	var calls []string
+	imageManager := manager.imageManager.(*mocks.ImageManager)
+	imageManager.AddReferenceFunc = func(hash, owner string) {
+		calls = append(calls, "reference "+hash)
+	}
+	imageManager.ResolveTreeFunc = func(hash string) (*backend.ResolutionTree, error) {
+		calls = append(calls, "resolve "+hash)
+		return &backend.ResolutionTree{
+			Order:     []string{hash, base},
+			Paths:     map[string]string{hash: "/images/app", base: "/images/base"},
+			Manifests: map[string]*schema.ImageManifest{hash: schema.BlankImageManifest(), base: schema.BlankImageManifest()},
+		}, nil
+	}
+	imageManager.GetImageFunc = func(hash string) *schema.ImageManifest {
+		if hash == base {
+			return nil
+		}
+		return schema.BlankImageManifest()
+	}
+
+	// the app's image is referenced before it is resolved, and a dependency
+	// removed before it was referenced fails the pod
+	err := pod.startingDependencySet()
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, err.Error(), fmt.Sprintf("image %s was removed while the pod was starting", base))
+	tt.TestEqual(t, calls[0], "reference "+app.String())
+	tt.TestEqual(t, calls[1], "resolve "+app.String())
+	tt.TestEqual(t, len(calls), 4)
+}
@@ -3,17 +3,22 @@
 package podmanager
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/logfile"
 	"github.com/apcera/logray"
 	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
 	"github.com/opencontainers/runc/libcontainer"
 
 	ntypes "github.com/apcera/kurma/pkg/networkmanager/types"
@@ -29,6 +34,10 @@ type Pod struct {
 	netNsPath      string
 	networkResults []*ntypes.IPResult
 
+	// networkMutex serializes changes to the networks the pod is attached to
+	// once it is running.
+	networkMutex sync.Mutex
+
 	stagerPath      string
 	stagerImage     *schema.ImageManifest
 	stagerContainer libcontainer.Container
@@ -48,13 +57,26 @@ type Pod struct {
 	// namespace of another container.
 	skipNetworking bool
 
+	// restored is set when the pod was restored from its record after the
+	// daemon restarted. Its stager is not a child process, so it is signaled
+	// through its container instead.
+	restored bool
+
 	directory string
 
 	shuttingDown   bool
 	shuttingDownCh chan struct{}
 	state          backend.PodState
+	startupError   error
 	mutex          sync.Mutex
 	waitch         chan bool
+	waitOnce       sync.Once
+	exitOnce       sync.Once
+
+	// finalStatus and exitCode are captured from the stager's state once it has
+	// exited, so they remain available after the pod is torn down.
+	finalStatus map[string]*backend.AppStatus
+	exitCode    *int
 }
 
 // PodManifest returns the current pod manifest for the App Pod
@@ -72,6 +94,88 @@ func (pod *Pod) Networks() []*ntypes.IPResult {
 	return pod.networkResults
 }
 
+// PortMappings returns the host ports forwarded to ports within the pod.
+func (pod *Pod) PortMappings() []*ntypes.PortMapping {
+	pod.mutex.Lock()
+	defer pod.mutex.Unlock()
+	return pod.options.PortMappings
+}
+
+// AttachNetwork provisions the named network on the running pod and adds it to
+// the pod's networks.
+func (pod *Pod) AttachNetwork(network string) error {
+	pod.networkMutex.Lock()
+	defer pod.networkMutex.Unlock()
+	if err := pod.canChangeNetworks(); err != nil {
+		return err
+	}
+
+	result, err := pod.manager.networkManager.Attach(pod, network)
+	if err != nil {
+		return err
+	}
+
+	pod.mutex.Lock()
+	pod.networkResults = append(pod.networkResults, result)
+	pod.mutex.Unlock()
+	if err := pod.writeRecord(); err != nil {
+		pod.log.Warnf("Failed to record the attached network: %v", err)
+	}
+	return nil
+}
+
+// DetachNetwork deprovisions the named network from the running pod and
+// removes it from the pod's networks.
+func (pod *Pod) DetachNetwork(network string) error {
+	pod.networkMutex.Lock()
+	defer pod.networkMutex.Unlock()
+	if err := pod.canChangeNetworks(); err != nil {
+		return err
+	}
+
+	if err := pod.manager.networkManager.Detach(pod, network); err != nil {
+		return err
+	}
+
+	pod.mutex.Lock()
+	results := make([]*ntypes.IPResult, 0, len(pod.networkResults))
+	for _, result := range pod.networkResults {
+		if result.Name != network {
+			results = append(results, result)
+		}
+	}
+	pod.networkResults = results
+	pod.mutex.Unlock()
+	if err := pod.writeRecord(); err != nil {
+		pod.log.Warnf("Failed to record the detached network: %v", err)
+	}
+	return nil
+}
+
+// canChangeNetworks returns an error if the networks the pod is attached to
+// can't be changed, such as when it isn't running or uses the host's network
+// namespace.
+func (pod *Pod) canChangeNetworks() error {
+	if pod.State() != backend.RUNNING {
+		return fmt.Errorf("pod must be in the running state to change its networks")
+	}
+	if pod.skipNetworking {
+		return fmt.Errorf("the pod's networks can't be changed as it doesn't have its own network namespace")
+	}
+	if pod.manager.networkManager == nil {
+		return fmt.Errorf("networking is not available")
+	}
+	return nil
+}
+
+// StartupError returns the error which caused the pod to fail to start, or nil
+// if it hasn't failed to start.
+func (pod *Pod) StartupError() error {
+	pod.mutex.Lock()
+	defer pod.mutex.Unlock()
+	return pod.startupError
+}
+
 // State returns the current operating state of the pod.
 func (pod *Pod) State() backend.PodState {
 	pod.mutex.Lock()
@@ -102,7 +206,9 @@ func (pod *Pod) start() {
 
 			pod.mutex.Lock()
 			pod.state = backend.ERRORED
+			pod.startupError = err
 			pod.mutex.Unlock()
+			pod.wake()
 			return
 		}
 	}
@@ -116,6 +222,7 @@ func (pod *Pod) start() {
 func (pod *Pod) Stop() error {
 	pod.mutex.Lock()
 	if pod.shuttingDown {
+		pod.mutex.Unlock()
 		return nil
 	}
 	pod.shuttingDown = true
@@ -123,6 +230,9 @@ func (pod *Pod) Stop() error {
 	pod.mutex.Unlock()
 	close(pod.shuttingDownCh)
 
+	// release the runtime resources, unless that was done when the stager exited
+	pod.exitOnce.Do(pod.release)
+
 	// loop over the pod stopping functions
 	for _, f := range podStopping {
 		if err := f(pod); err != nil {
@@ -134,9 +244,40 @@ func (pod *Pod) Stop() error {
 	pod.mutex.Lock()
 	pod.state = backend.STOPPED
 	pod.mutex.Unlock()
+	pod.wake()
 	return nil
 }
 
+// exit is called when the pod's stager exits on its own. It releases the pod's
+// runtime resources, but keeps the pod and its directory so that its status and
+// logs are available until it is stopped.
+func (pod *Pod) exit() {
+	pod.exitOnce.Do(pod.release)
+
+	pod.mutex.Lock()
+	if !pod.shuttingDown {
+		pod.state = backend.EXITED
+	}
+	pod.mutex.Unlock()
+	pod.wake()
+}
+
+// release runs the functions to release the pod's runtime resources.
+func (pod *Pod) release() {
+	for _, f := range podExiting {
+		if err := f(pod); err != nil {
+			// FIXME more error handling
+			pod.log.Errorf("Pod stopping error: %v", err)
+		}
+	}
+}
+
+// wake releases anything blocked in Wait. It is called when the pod fails to
+// start as well as when it is stopped, so it only closes the channel once.
+func (pod *Pod) wake() {
+	pod.waitOnce.Do(func() { close(pod.waitch) })
+}
+
 // UUID returns the UUID associated with the current Pod.
 func (pod *Pod) UUID() string {
 	if pod == nil {
@@ -234,6 +375,250 @@ func (pod *Pod) Enter(appName string, app *kschema.RunApp, stdin io.Reader, stdo
 	return os.FindProcess(pid)
 }
 
+// Attach is used to attach to the input and output of the primary process of
+// an application within the pod. It calls in to the stager's attach command.
+func (pod *Pod) Attach(appName string, stdin io.Reader, stdout io.Writer) (*os.Process, error) {
+	if pod.State() != backend.RUNNING {
+		return nil, fmt.Errorf("pod must be in the running state to attach to it")
+	}
+	if pod.PodManifest().Apps.Get(types.ACName(appName)) == nil {
+		return nil, fmt.Errorf("specified application %q was not found", appName)
+	}
+
+	process := &libcontainer.Process{
+		Cwd:    "/",
+		User:   "0",
+		Args:   []string{"/opt/stager/attach", appName},
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stdout,
+	}
+	if err := pod.stagerContainer.Start(process); err != nil {
+		return nil, fmt.Errorf("failed to start attach process in stager: %v", err)
+	}
+
+	pid, err := process.Pid()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get process pid")
+	}
+	return os.FindProcess(pid)
+}
+
+// AppStatus returns the status of each of the applications within the pod. It
+// calls in to the stager's status command to retrieve it. Once the pod has
+// stopped, the final status recorded by the stager is returned instead.
+func (pod *Pod) AppStatus() (map[string]*backend.AppStatus, error) {
+	pod.mutex.Lock()
+	finalStatus := pod.finalStatus
+	pod.mutex.Unlock()
+	if finalStatus != nil {
+		return finalStatus, nil
+	}
+
+	if pod.State() != backend.RUNNING {
+		return nil, fmt.Errorf("pod must be in the running state to retrieve its status")
+	}
+
+	var stdout, stderr bytes.Buffer
+	process := &libcontainer.Process{
+		Cwd:    "/",
+		User:   "0",
+		Args:   []string{"/opt/stager/status"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	if err := pod.stagerContainer.Start(process); err != nil {
+		return nil, fmt.Errorf("failed to start status process in stager: %v", err)
+	}
+
+	// Allow 10 seconds for the status command to complete.
+	type waitResult struct {
+		ps  *os.ProcessState
+		err error
+	}
+	ch := make(chan waitResult, 1)
+	go func() {
+		ps, err := process.Wait()
+		ch <- waitResult{ps, err}
+	}()
+
+	var result waitResult
+	select {
+	case result = <-ch:
+	case <-time.After(time.Second * 10):
+		pod.log.Error("Stager status failed to complete within 10 seconds, stopping status process")
+		process.Signal(syscall.SIGKILL)
+		return nil, fmt.Errorf("stager status process timed out")
+	}
+	if result.ps == nil || !result.ps.Success() {
+		return nil, fmt.Errorf("stager status process failed: %v: %s", result.err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var apps map[string]*backend.AppStatus
+	if err := json.Unmarshal(stdout.Bytes(), &apps); err != nil {
+		return nil, fmt.Errorf("failed to parse the stager status: %v", err)
+	}
+	return apps, nil
+}
+
+// ExitCode returns the exit code of the pod once it has stopped. It is
+// determined by the stager based on the pod's exit policy.
+func (pod *Pod) ExitCode() (int, error) {
+	pod.mutex.Lock()
+	defer pod.mutex.Unlock()
+	if pod.exitCode == nil {
+		return 0, fmt.Errorf("pod has not exited")
+	}
+	return *pod.exitCode, nil
+}
+
+// Logs writes the log output of the specified application to the provided
+// writer. If no application name is given, the stager's own log is written
+// instead.
+func (pod *Pod) Logs(appName string, options *backend.LogOptions, w io.Writer, cancel <-chan struct{}) error {
+	if options == nil {
+		options = &backend.LogOptions{}
+	}
+	if appName == "" {
+		return pod.stagerLogs(options, w, cancel)
+	}
+
+	if pod.PodManifest().Apps.Get(types.ACName(appName)) == nil {
+		return fmt.Errorf("specified application %q was not found", appName)
+	}
+
+	// The log is read directly from the stager's filesystem so that it is
+	// available once the app or the pod has exited. Only following the log of a
+	// running stager is handled within the stager.
+	if !options.Follow || !pod.stagerRunning() {
+		return pod.appLogs(appName, options, w)
+	}
+
+	args := []string{"/opt/stager/logs"}
+	if options.Follow {
+		args = append(args, "--follow")
+	}
+	if options.Tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(options.Tail))
+	}
+	if !options.Since.IsZero() {
+		args = append(args, "--since", options.Since.Format(time.RFC3339Nano))
+	}
+	if options.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	args = append(args, appName)
+
+	var stderr bytes.Buffer
+	process := &libcontainer.Process{
+		Cwd:    "/",
+		User:   "0",
+		Args:   args,
+		Stdout: w,
+		Stderr: &stderr,
+	}
+	if err := pod.stagerContainer.Start(process); err != nil {
+		return fmt.Errorf("failed to start logs process in stager: %v", err)
+	}
+
+	ch := make(chan *os.ProcessState, 1)
+	go func() {
+		ps, _ := process.Wait()
+		ch <- ps
+	}()
+
+	var ps *os.ProcessState
+	select {
+	case ps = <-ch:
+	case <-cancel:
+		process.Signal(syscall.SIGKILL)
+		<-ch
+		return nil
+	}
+	if ps == nil || !ps.Success() {
+		return fmt.Errorf("stager logs process failed: %s", bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// appLogs writes the log of the specified app to the provided writer, reading
+// it directly from the stager's filesystem.
+func (pod *Pod) appLogs(appName string, options *backend.LogOptions, w io.Writer) error {
+	pod.mutex.Lock()
+	directory := pod.directory
+	pod.mutex.Unlock()
+
+	if directory == "" {
+		return fmt.Errorf("pod has no application logs available")
+	}
+
+	opts := &logfile.Options{
+		Tail:        options.Tail,
+		Since:       options.Since,
+		Timestamped: true,
+		Timestamps:  options.Timestamps,
+	}
+	return logfile.Copy(w, filepath.Join(pod.stagerRootPath(), "logs", appName), opts)
+}
+
+// stagerRunning returns whether the pod's stager has been started and has not
+// yet exited.
+func (pod *Pod) stagerRunning() bool {
+	pod.mutex.Lock()
+	container := pod.stagerContainer
+	stagerWaitCh := pod.stagerWaitCh
+	pod.mutex.Unlock()
+
+	if container == nil || stagerWaitCh == nil {
+		return false
+	}
+	select {
+	case <-stagerWaitCh:
+		return false
+	default:
+		return true
+	}
+}
+
+// stagerLogs writes the stager's own log to the provided writer. It is read
+// directly from the pod's directory, so it is available even if the stager is
+// no longer running. The stager's log isn't timestamped, so it can't be
+// filtered by time or shown with timestamps.
+func (pod *Pod) stagerLogs(options *backend.LogOptions, w io.Writer, cancel <-chan struct{}) error {
+	if !options.Since.IsZero() || options.Timestamps {
+		return fmt.Errorf("the stager log does not have timestamps")
+	}
+
+	pod.mutex.Lock()
+	directory := pod.directory
+	stagerWaitCh := pod.stagerWaitCh
+	pod.mutex.Unlock()
+
+	if directory == "" {
+		return fmt.Errorf("pod has no stager log available")
+	}
+
+	opts := &logfile.Options{
+		Tail:   options.Tail,
+		Follow: options.Follow,
+	}
+
+	// Stop following once the caller cancels or the stager exits.
+	if options.Follow {
+		stop := make(chan struct{})
+		go func() {
+			defer close(stop)
+			select {
+			case <-cancel:
+			case <-stagerWaitCh:
+			}
+		}()
+		opts.Stop = stop
+	}
+
+	return logfile.Copy(w, pod.stagerLogPath(), opts)
+}
+
 // WaitForState is used to poll until the state of the pod reaches a desired
 // state.
 func (pod *Pod) WaitForState(timeout time.Duration, states ...backend.PodState) error {
@@ -252,8 +637,8 @@ func (pod *Pod) WaitForState(timeout time.Duration, states ...backend.PodState)
 }
 
 // Wait can be used to block until the processes within a container are finished
-// executed. It is primarily intended for an internal API to code against system
-// services.
+// executed, or until the pod has failed to start. It is primarily intended for
+// an internal API to code against system services.
 func (pod *Pod) Wait() {
 	<-pod.waitch
 }
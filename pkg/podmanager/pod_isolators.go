@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"syscall"
 
+	"github.com/apcera/kurma/pkg/capabilities"
+	"github.com/apcera/kurma/pkg/resources"
 	"github.com/appc/spec/schema"
 	"github.com/appc/spec/schema/types"
 	"github.com/opencontainers/runc/libcontainer/configs"
@@ -176,3 +178,119 @@ func (pod *Pod) setupHostApiAccessIsolator(runtimeApp *schema.RuntimeApp) error
 
 	return nil
 }
+
+// getResourceLimits returns the resource limits which should be applied to the
+// pod's cgroup, based on the resource isolators of the pod and its apps. The
+// getImage function is used to look up the image manifest for apps which don't
+// specify an App in the pod manifest.
+func getResourceLimits(manifest *schema.PodManifest, getImage func(string) *schema.ImageManifest) (*resources.Limits, error) {
+	appIsolators := make([]types.Isolators, 0, len(manifest.Apps))
+	for _, runtimeApp := range manifest.Apps {
+		app := runtimeApp.App
+		if app == nil {
+			if imageManifest := getImage(runtimeApp.Image.ID.String()); imageManifest != nil {
+				app = imageManifest.App
+			}
+		}
+		if app != nil {
+			appIsolators = append(appIsolators, app.Isolators)
+		}
+	}
+
+	limits, err := resources.PodLimits(manifest.Isolators, appIsolators)
+	if err != nil {
+		return nil, fmt.Errorf("the pod's resources can't be satisfied: %v", err)
+	}
+	return limits, nil
+}
+
+// validateCapabilitiesIsolators ensures the capabilities named in any of the
+// app's capabilities isolators are supported. An app may not have both a
+// retain set and a remove set, since the capabilities it would be run with
+// would depend on the order of its isolators.
+func validateCapabilitiesIsolators(app *types.App) error {
+	var retain, remove bool
+	for _, iso := range app.Isolators {
+		set, ok := iso.Value().(types.LinuxCapabilitiesSet)
+		if !ok {
+			continue
+		}
+		switch set.(type) {
+		case *types.LinuxCapabilitiesRetainSet:
+			retain = true
+		case *types.LinuxCapabilitiesRevokeSet:
+			remove = true
+		}
+		if retain && remove {
+			return fmt.Errorf("cannot specify both a capabilities retain set and remove set")
+		}
+		for _, c := range capabilities.Names(set) {
+			if _, err := capabilities.Normalize(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getUserNamespaceIsolator returns the pod's user namespace isolator, or nil if
+// the pod isn't to be run within a user namespace.
+func getUserNamespaceIsolator(manifest *schema.PodManifest) *kschema.LinuxUserNamespace {
+	for _, iso := range manifest.Isolators {
+		if iso.Name.String() != kschema.LinuxUserNamespaceName {
+			continue
+		}
+		if uiso, ok := iso.Value().(*kschema.LinuxUserNamespace); ok {
+			return uiso
+		}
+	}
+	return nil
+}
+
+// isPrivilegedApp returns whether the app requests either Linux or host
+// privilege.
+func isPrivilegedApp(app *types.App) bool {
+	if iso := app.Isolators.GetByName(kschema.LinuxPrivilegedName); iso != nil {
+		if piso, ok := iso.Value().(*kschema.LinuxPrivileged); ok && bool(*piso) {
+			return true
+		}
+	}
+	if iso := app.Isolators.GetByName(kschema.HostPrivilegedName); iso != nil {
+		if piso, ok := iso.Value().(*kschema.HostPrivileged); ok && bool(*piso) {
+			return true
+		}
+	}
+	return false
+}
+
+// setupUserNamespaceIsolator allocates the host ids for a pod which requests a
+// user namespace without specifying its own mappings. The isolator is updated
+// with the allocated mappings so the stager can apply them.
+func (pod *Pod) setupUserNamespaceIsolator() error {
+	for i, iso := range pod.manifest.Pod.Isolators {
+		if iso.Name.String() != kschema.LinuxUserNamespaceName {
+			continue
+		}
+		uiso, ok := iso.Value().(*kschema.LinuxUserNamespace)
+		if !ok || uiso.HasMappings() {
+			return nil
+		}
+		if pod.manager.userNamespaces == nil {
+			return fmt.Errorf("no user namespace range is configured to allocate from")
+		}
+
+		hostID, err := pod.manager.userNamespaces.allocate(pod.uuid)
+		if err != nil {
+			return err
+		}
+		mappings := []kschema.IDMapping{{ContainerID: 0, HostID: hostID, Size: userNamespaceSize}}
+		niso, err := kschema.GenerateUserNamespaceIsolator(mappings, mappings)
+		if err != nil {
+			pod.manager.userNamespaces.release(pod.uuid)
+			return fmt.Errorf("failed to generate the user namespace isolator: %v", err)
+		}
+		pod.manifest.Pod.Isolators[i] = *niso
+		return nil
+	}
+	return nil
+}
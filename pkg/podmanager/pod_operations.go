@@ -31,17 +31,28 @@ var (
 		(*Pod).startingInitializeContainer,
 		(*Pod).startingWriteManifest,
 		(*Pod).launchStager,
+		(*Pod).startingWriteRecord,
 		(*Pod).waitForReady,
 	}
 
-	// These are the functions that will be called in order to handle pod
-	// teardown.
-	podStopping = []func(*Pod) error{
+	// These are the functions that will be called in order to release the
+	// runtime resources of a pod, either when it is stopped or when its stager
+	// exits on its own.
+	podExiting = []func(*Pod) error{
 		(*Pod).stoppingReadyPipe,
 		(*Pod).stoppingSignal,
+		(*Pod).stoppingReadState,
 		(*Pod).stoppingNetwork,
 		(*Pod).stoppingStager,
+	}
+
+	// These are the functions that will be called in order to handle the rest
+	// of the pod teardown. They're only run when the pod is stopped, so the logs
+	// of an exited pod remain available until then.
+	podStopping = []func(*Pod) error{
 		(*Pod).stoppingDirectories,
+		(*Pod).stoppingUserNamespace,
+		(*Pod).stoppingImageReferences,
 		(*Pod).stoppingrRemoveFromParent,
 	}
 )
@@ -49,6 +60,10 @@ var (
 // startingGetStager locates the image manifest for the stager and validates
 // that it can be used.
 func (pod *Pod) startingGetStager() error {
+	// Reference the stager image before looking it up, so that it can't be
+	// garbage collected while the pod is starting.
+	pod.referenceImage(pod.options.StagerHash)
+
 	image := pod.manager.imageManager.GetImage(pod.options.StagerHash)
 	if image == nil {
 		return fmt.Errorf("failed to locate specified stager image")
@@ -56,6 +71,9 @@ func (pod *Pod) startingGetStager() error {
 	if image.App == nil {
 		return fmt.Errorf("the specified stager does not define an \"app\"")
 	}
+	if err := pod.manager.Options.Keystore.CheckImage(pod.options.StagerHash, pod.manager.imageManager); err != nil {
+		return fmt.Errorf("the specified stager is not trusted: %v", err)
+	}
 	pod.stagerImage = image
 
 	resolution, err := pod.manager.imageManager.ResolveTree(pod.options.StagerHash)
@@ -71,9 +89,13 @@ func (pod *Pod) startingGetStager() error {
 }
 
 // startingDependencySet resolves the dependencies for the pod's applications
-// and applies them to the StagerManifest.
+// and applies them to the StagerManifest. Each app's image is referenced before
+// it is resolved, so garbage collection keeps it and its dependencies, and the
+// dependencies are referenced as they're resolved.
 func (pod *Pod) startingDependencySet() error {
 	for _, app := range pod.manifest.Pod.Apps {
+		pod.referenceImage(app.Image.ID.String())
+
 		resolution, err := pod.manager.imageManager.ResolveTree(app.Image.ID.String())
 		if err != nil {
 			return fmt.Errorf("failed to resolve dependencies for app %q: %v", app.Name, err)
@@ -81,6 +103,7 @@ func (pod *Pod) startingDependencySet() error {
 
 		pod.manifest.AppImageOrder[string(app.Name)] = resolution.Order
 		for k, v := range resolution.Manifests {
+			pod.referenceImage(k)
 			pod.manifest.Images[k] = v
 		}
 		for layer, layerPath := range resolution.Paths {
@@ -88,6 +111,12 @@ func (pod *Pod) startingDependencySet() error {
 		}
 	}
 
+	// Ensure none of the dependencies were removed before they were referenced.
+	for hash := range pod.manifest.Images {
+		if pod.manager.imageManager.GetImage(hash) == nil {
+			return fmt.Errorf("image %s was removed while the pod was starting", hash)
+		}
+	}
 	return nil
 }
 
@@ -133,6 +162,9 @@ func (pod *Pod) startingApplyIsolators() error {
 	if err := pod.setupLinuxNamespaceIsolator(); err != nil {
 		return err
 	}
+	if err := pod.setupUserNamespaceIsolator(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -313,6 +345,12 @@ func (pod *Pod) launchStager() error {
 	return nil
 }
 
+// startingWriteRecord persists the pod's record so that it can be restored if
+// the daemon is restarted while the pod is running.
+func (pod *Pod) startingWriteRecord() error {
+	return pod.writeRecord()
+}
+
 // waitForReady is used to wait until the stager closes its end of the pipe to
 // signal the pod is ready.
 func (pod *Pod) waitForReady() error {
@@ -352,12 +390,30 @@ func (pod *Pod) stoppingReadyPipe() error {
 // shutdown the application's in the pod.
 func (pod *Pod) stoppingSignal() error {
 	pod.mutex.Lock()
-	process := pod.stagerProcess
+	var process interface {
+		Signal(os.Signal) error
+	}
+	if pod.stagerProcess != nil {
+		process = pod.stagerProcess
+	} else if pod.restored && pod.stagerContainer != nil {
+		process = pod.stagerContainer
+	}
 	pod.mutex.Unlock()
 	if process == nil {
 		return nil
 	}
 
+	// The stager may have already exited on its own, such as when the pod's
+	// exit policy has been met.
+	select {
+	case <-pod.stagerWaitCh:
+		pod.mutex.Lock()
+		pod.stagerProcess = nil
+		pod.mutex.Unlock()
+		return nil
+	default:
+	}
+
 	pod.log.Trace("Sending shutdown signal to the stager process")
 	if err := process.Signal(os.Signal(syscall.SIGTERM)); err != nil {
 		return fmt.Errorf("failed to send TERM signal to stager: %v", err)
@@ -380,12 +436,47 @@ func (pod *Pod) stoppingSignal() error {
 	return nil
 }
 
+// stoppingReadState reads the final state written by the stager so that the
+// exit status of the pod's apps is available after the pod is torn down.
+func (pod *Pod) stoppingReadState() error {
+	if pod.directory == "" {
+		return nil
+	}
+
+	f, err := os.Open(filepath.Join(pod.stagerRootPath(), "state.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open the stager state: %v", err)
+	}
+	defer f.Close()
+
+	var state *stagerState
+	if err := json.NewDecoder(f).Decode(&state); err != nil {
+		return fmt.Errorf("failed to parse the stager state: %v", err)
+	}
+
+	pod.mutex.Lock()
+	defer pod.mutex.Unlock()
+	pod.finalStatus = state.Apps
+	if state.State == stagerStateExited {
+		exitCode := state.ExitCode
+		pod.exitCode = &exitCode
+	}
+	return nil
+}
+
 // stoppingNetwork handles the teardown of the networks the container is
 // attached to.
 func (pod *Pod) stoppingNetwork() error {
 	if pod.skipNetworking || pod.manager.networkManager == nil {
 		return nil
 	}
+
+	// wait for any networks being attached or detached
+	pod.networkMutex.Lock()
+	defer pod.networkMutex.Unlock()
 	return pod.manager.networkManager.Deprovision(pod)
 }
 
@@ -438,6 +529,20 @@ func (pod *Pod) stoppingDirectories() error {
 	return nil
 }
 
+// stoppingUserNamespace releases any host ids allocated to the pod for its user
+// namespace.
+func (pod *Pod) stoppingUserNamespace() error {
+	pod.manager.userNamespaces.release(pod.uuid)
+	return nil
+}
+
+// stoppingImageReferences releases the pod's references on its images so they
+// can be removed once they are no longer needed.
+func (pod *Pod) stoppingImageReferences() error {
+	pod.manager.imageManager.RemoveReferences(pod.imageReferenceOwner())
+	return nil
+}
+
 // stoppingrRemoveFromParent removes the container object itself from the Pod
 // Manager.
 func (pod *Pod) stoppingrRemoveFromParent() error {
@@ -0,0 +1,244 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package podmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apcera/kurma/pkg/backend"
+	"github.com/opencontainers/runc/libcontainer"
+
+	ntypes "github.com/apcera/kurma/pkg/networkmanager/types"
+)
+
+const (
+	// podRecordFile is the name of the file within the pod's directory which the
+	// information needed to restore the pod is persisted to.
+	podRecordFile = "pod.json"
+
+	// restoredPollInterval is how often the stager of a restored pod is checked
+	// to see if it has exited. Restored stagers are not children of the current
+	// process, so they can't be waited on directly.
+	restoredPollInterval = time.Second
+)
+
+// podRecord is the information about a pod which is persisted so that the pod
+// can be restored if the daemon is restarted while it is running.
+type podRecord struct {
+	UUID           string                `json:"uuid"`
+	Name           string                `json:"name"`
+	ContainerID    string                `json:"containerId"`
+	StagerHash     string                `json:"stagerHash"`
+	Networks       []string              `json:"networks,omitempty"`
+	NetworkResults []*ntypes.IPResult    `json:"networkResults,omitempty"`
+	NetNsPath      string                `json:"netNsPath,omitempty"`
+	SkipNetworking bool                  `json:"skipNetworking,omitempty"`
+	PortMappings   []*ntypes.PortMapping `json:"portMappings,omitempty"`
+}
+
+func (pod *Pod) podRecordPath() string {
+	return filepath.Join(pod.directory, podRecordFile)
+}
+
+// writeRecord persists the pod's record to its directory.
+func (pod *Pod) writeRecord() error {
+	pod.mutex.Lock()
+	record := &podRecord{
+		UUID:           pod.uuid,
+		Name:           pod.name,
+		ContainerID:    pod.ShortName(),
+		StagerHash:     pod.options.StagerHash,
+		Networks:       pod.options.Networks,
+		NetworkResults: pod.networkResults,
+		NetNsPath:      pod.netNsPath,
+		SkipNetworking: pod.skipNetworking,
+		PortMappings:   pod.options.PortMappings,
+	}
+	pod.mutex.Unlock()
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode the pod record: %v", err)
+	}
+
+	tmpfile := pod.podRecordPath() + ".tmp"
+	if err := ioutil.WriteFile(tmpfile, b, os.FileMode(0600)); err != nil {
+		return fmt.Errorf("failed to write the pod record: %v", err)
+	}
+	if err := os.Rename(tmpfile, pod.podRecordPath()); err != nil {
+		return fmt.Errorf("failed to move the pod record into place: %v", err)
+	}
+	return nil
+}
+
+// Restore reloads any pods that were left running by a previous instance of
+// the pod manager, using the records persisted in the pod directory. Pods whose
+// stager has since exited, or whose name has already been taken, are torn down.
+// Any pod directories without a record are removed.
+func (manager *Manager) Restore() error {
+	fis, err := ioutil.ReadDir(manager.Options.PodDirectory)
+	if err != nil {
+		return fmt.Errorf("failed to read the pod directory: %v", err)
+	}
+
+	for _, fi := range fis {
+		directory := filepath.Join(manager.Options.PodDirectory, fi.Name())
+		if !fi.IsDir() || directory == manager.Options.LibcontainerDirectory {
+			continue
+		}
+
+		pod, err := manager.loadPod(directory)
+		if err != nil {
+			manager.log.Warnf("Removing stale pod directory %q: %v", fi.Name(), err)
+			manager.removeStalePod(directory, fi.Name())
+			continue
+		}
+
+		manager.podsLock.Lock()
+		_, exists := manager.podNames[pod.name]
+		manager.pods[pod.uuid] = pod
+		if !exists {
+			manager.podNames[pod.name] = pod.uuid
+		}
+		manager.podsLock.Unlock()
+
+		if exists {
+			pod.log.Warnf("A pod named %q has already been created, stopping the restored pod", pod.name)
+			go pod.Stop()
+			continue
+		}
+
+		if status, err := pod.stagerContainer.Status(); err != nil || status != libcontainer.Running {
+			pod.log.Infof("The pod's stager is no longer running, marking it as exited")
+			go pod.exit()
+			continue
+		}
+
+		pod.log.Infof("Restored pod %q", pod.name)
+		if !pod.skipNetworking && manager.networkManager != nil {
+			if err := manager.networkManager.Check(pod); err != nil {
+				pod.log.Warnf("The restored pod's networking is unhealthy: %v", err)
+			}
+		}
+		pod.restoredWaitRoutine()
+	}
+	return nil
+}
+
+// loadPod reads the record from the pod directory and returns a pod that has
+// been reattached to its stager container.
+func (manager *Manager) loadPod(directory string) (*Pod, error) {
+	b, err := ioutil.ReadFile(filepath.Join(directory, podRecordFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the pod record: %v", err)
+	}
+	var record *podRecord
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse the pod record: %v", err)
+	}
+
+	pod := &Pod{
+		manager:        manager,
+		log:            manager.log.Clone(),
+		uuid:           record.UUID,
+		name:           record.Name,
+		directory:      directory,
+		netNsPath:      record.NetNsPath,
+		networkResults: record.NetworkResults,
+		skipNetworking: record.SkipNetworking,
+		restored:       true,
+		state:          backend.RUNNING,
+		shuttingDownCh: make(chan struct{}),
+		waitch:         make(chan bool),
+		layerPaths:     make(map[string]string),
+		options: &backend.PodOptions{
+			StagerHash:   record.StagerHash,
+			Networks:     record.Networks,
+			PortMappings: record.PortMappings,
+		},
+	}
+	pod.log.SetField("pod", pod.uuid)
+
+	f, err := os.Open(filepath.Join(pod.stagerRootPath(), "manifest"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the stager manifest: %v", err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&pod.manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse the stager manifest: %v", err)
+	}
+
+	container, err := manager.factory.Load(record.ContainerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the stager container: %v", err)
+	}
+	pod.stagerContainer = container
+
+	// Keep the images the pod is using from being removed.
+	pod.referenceImages()
+
+	// Keep the pod's user namespace range from being allocated to another pod.
+	if uiso := getUserNamespaceIsolator(pod.manifest.Pod); uiso != nil && manager.userNamespaces != nil {
+		if uiso.HasMappings() {
+			manager.userNamespaces.reserve(pod.uuid, uiso.UIDMappings[0].HostID)
+		}
+	}
+
+	// Keep the wait channel closed until a wait routine is started, so stopping
+	// the pod doesn't block on a stager that is no longer running.
+	ch := make(chan struct{})
+	close(ch)
+	pod.stagerWaitCh = ch
+	return pod, nil
+}
+
+// removeStalePod cleans up a pod directory that couldn't be restored. Any
+// container remaining for it is destroyed, which kills its processes.
+func (manager *Manager) removeStalePod(directory, containerID string) {
+	if container, err := manager.factory.Load(containerID); err == nil {
+		if err := container.Destroy(); err != nil {
+			manager.log.Warnf("Failed to destroy stale container %q: %v", containerID, err)
+		}
+	}
+	if err := unmountDirectories(directory); err != nil {
+		manager.log.Warnf("Failed to unmount stale pod directory %q: %v", directory, err)
+		return
+	}
+	if err := os.RemoveAll(directory); err != nil {
+		manager.log.Warnf("Failed to remove stale pod directory %q: %v", directory, err)
+	}
+}
+
+// restoredWaitRoutine is used to track when the stager of a restored pod exits
+// and to respond by releasing the pod's runtime resources.
+func (pod *Pod) restoredWaitRoutine() {
+	ch := make(chan struct{})
+	pod.mutex.Lock()
+	pod.stagerWaitCh = ch
+	container := pod.stagerContainer
+	pod.mutex.Unlock()
+
+	go func() {
+		defer close(ch)
+
+		for {
+			time.Sleep(restoredPollInterval)
+			status, err := container.Status()
+			if err != nil || status != libcontainer.Running {
+				break
+			}
+		}
+		pod.log.Warn("Stager process has exited")
+
+		// If we're in the process of shutting down, just return
+		if pod.isShuttingDown() {
+			return
+		}
+		go pod.exit()
+	}()
+}
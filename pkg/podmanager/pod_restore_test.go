@@ -0,0 +1,93 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package podmanager
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apcera/kurma/pkg/backend"
+	"github.com/appc/spec/schema"
+	"github.com/opencontainers/runc/libcontainer"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// writeRestorablePod creates the directory, record, and container for a pod as
+// they would be left by a previous instance of the manager.
+func writeRestorablePod(t *testing.T, manager *Manager, status libcontainer.Status) *Pod {
+	pod := createPod(t, manager)
+	pod.manifest = &backend.StagerManifest{Name: pod.name, Pod: schema.BlankPodManifest()}
+	tt.TestExpectSuccess(t, pod.startingBaseDirectories())
+	tt.TestExpectSuccess(t, pod.writeRecord())
+
+	b, err := json.Marshal(pod.manifest)
+	tt.TestExpectSuccess(t, err)
+	err = ioutil.WriteFile(filepath.Join(pod.stagerRootPath(), "manifest"), b, os.FileMode(0644))
+	tt.TestExpectSuccess(t, err)
+
+	container, err := manager.factory.Create(pod.ShortName(), nil)
+	tt.TestExpectSuccess(t, err)
+	container.(*mockContainer).status = status
+	return pod
+}
+
+func TestRestoreRunningPod(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	orig := writeRestorablePod(t, manager, libcontainer.Running)
+
+	tt.TestExpectSuccess(t, manager.Restore())
+
+	pod := manager.Pod(orig.UUID())
+	tt.TestNotEqual(t, pod, nil)
+	tt.TestEqual(t, pod.Name(), orig.Name())
+	tt.TestEqual(t, pod.State(), backend.RUNNING)
+	tt.TestEqual(t, pod.PodManifest() != nil, true)
+	tt.TestEqual(t, pod.(*Pod).restored, true)
+}
+
+func TestRestoreExitedPod(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	orig := writeRestorablePod(t, manager, libcontainer.Destroyed)
+
+	tt.TestExpectSuccess(t, manager.Restore())
+
+	pod := manager.Pod(orig.UUID())
+	tt.TestNotEqual(t, pod, nil)
+	pod.Wait()
+
+	// the exited pod is kept until it is stopped
+	tt.TestEqual(t, pod.State(), backend.EXITED)
+	tt.TestEqual(t, manager.Pod(orig.UUID()), pod)
+	_, err := os.Stat(orig.directory)
+	tt.TestExpectSuccess(t, err)
+
+	tt.TestExpectSuccess(t, pod.Stop())
+	tt.TestEqual(t, manager.Pod(orig.UUID()), nil)
+	_, err = os.Stat(orig.directory)
+	tt.TestEqual(t, os.IsNotExist(err), true)
+}
+
+func TestRestoreRemovesStaleDirectories(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	stale := filepath.Join(manager.Options.PodDirectory, "stale")
+	tt.TestExpectSuccess(t, os.Mkdir(stale, os.FileMode(0755)))
+
+	tt.TestExpectSuccess(t, manager.Restore())
+
+	_, err := os.Stat(stale)
+	tt.TestEqual(t, os.IsNotExist(err), true)
+	tt.TestEqual(t, len(manager.Pods()), 0)
+}
@@ -0,0 +1,136 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package podmanager
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+
+	ntypes "github.com/apcera/kurma/pkg/networkmanager/types"
+	kschema "github.com/apcera/kurma/schema"
+)
+
+// portMappings returns the host port mappings for a pod, combining the
+// requested mappings with the ports exposed by the pod manifest. Exposed ports
+// are resolved to the port with the same name declared by one of the pod's
+// apps. An error is returned if any mapping is invalid or two of them forward
+// the same host port.
+func (manager *Manager) portMappings(manifest *schema.PodManifest, requested []*ntypes.PortMapping) ([]*ntypes.PortMapping, error) {
+	mappings := make([]*ntypes.PortMapping, 0, len(requested)+len(manifest.Ports))
+	for _, r := range requested {
+		mapping := *r
+		if mapping.Protocol == "" {
+			mapping.Protocol = "tcp"
+		}
+		mappings = append(mappings, &mapping)
+	}
+
+	for _, exposed := range manifest.Ports {
+		port := manager.appPort(manifest, exposed.Name)
+		if port == nil {
+			return nil, fmt.Errorf("the exposed port %q is not declared by any app in the pod", exposed.Name)
+		}
+		count := port.Count
+		if count == 0 {
+			count = 1
+		}
+		for i := uint(0); i < count; i++ {
+			mappings = append(mappings, &ntypes.PortMapping{
+				HostPort:      int(exposed.HostPort + i),
+				ContainerPort: int(port.Port + i),
+				Protocol:      port.Protocol,
+			})
+		}
+	}
+
+	if len(mappings) == 0 {
+		return nil, nil
+	}
+	if manager.networkManager == nil {
+		return nil, fmt.Errorf("networking is not available to map host ports")
+	}
+	if isHostNetworking(manifest) {
+		return nil, fmt.Errorf("host ports can't be mapped for pods using the host's network namespace")
+	}
+
+	for i, mapping := range mappings {
+		if err := validatePortMapping(mapping); err != nil {
+			return nil, err
+		}
+		for _, other := range mappings[:i] {
+			if mapping.Conflicts(other) {
+				return nil, fmt.Errorf("host port %d/%s is mapped more than once", mapping.HostPort, mapping.Protocol)
+			}
+		}
+	}
+	return mappings, nil
+}
+
+// appPort returns the port with the specified name declared by one of the
+// pod's apps, or nil if none of them declare it.
+func (manager *Manager) appPort(manifest *schema.PodManifest, name types.ACName) *types.Port {
+	for _, runtimeApp := range manifest.Apps {
+		app := runtimeApp.App
+		if app == nil {
+			if imageManifest := manager.imageManager.GetImage(runtimeApp.Image.ID.String()); imageManifest != nil {
+				app = imageManifest.App
+			}
+		}
+		if app == nil {
+			continue
+		}
+		for i := range app.Ports {
+			if app.Ports[i].Name == name {
+				return &app.Ports[i]
+			}
+		}
+	}
+	return nil
+}
+
+// validatePortMapping ensures the ports, protocol, and host IP of the mapping
+// are valid.
+func validatePortMapping(mapping *ntypes.PortMapping) error {
+	if mapping.HostPort < 1 || mapping.HostPort > 65535 {
+		return fmt.Errorf("invalid host port %d", mapping.HostPort)
+	}
+	if mapping.ContainerPort < 1 || mapping.ContainerPort > 65535 {
+		return fmt.Errorf("invalid container port %d", mapping.ContainerPort)
+	}
+	if mapping.Protocol != "tcp" && mapping.Protocol != "udp" {
+		return fmt.Errorf("invalid protocol %q for host port %d, must be tcp or udp", mapping.Protocol, mapping.HostPort)
+	}
+	if mapping.HostIP != "" && net.ParseIP(mapping.HostIP) == nil {
+		return fmt.Errorf("invalid host IP %q for host port %d", mapping.HostIP, mapping.HostPort)
+	}
+	return nil
+}
+
+// portConflict returns an error if any of the mappings forward a host port
+// already forwarded to another pod. The podsLock must be held by the caller.
+func (manager *Manager) portConflict(mappings []*ntypes.PortMapping) error {
+	for _, pod := range manager.pods {
+		for _, existing := range pod.PortMappings() {
+			for _, mapping := range mappings {
+				if mapping.Conflicts(existing) {
+					return fmt.Errorf("host port %d/%s is already mapped to pod %q", mapping.HostPort, mapping.Protocol, pod.Name())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isHostNetworking returns whether the pod manifest requests that the pod use
+// the host's network namespace.
+func isHostNetworking(manifest *schema.PodManifest) bool {
+	for _, iso := range manifest.Isolators {
+		if niso, ok := iso.Value().(*kschema.LinuxNamespaces); ok && niso.Net() == kschema.LinuxNamespaceHost {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package podmanager
+
+import (
+	"testing"
+
+	"github.com/apcera/kurma/pkg/backend"
+	"github.com/apcera/kurma/pkg/backend/mocks"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+
+	ntypes "github.com/apcera/kurma/pkg/networkmanager/types"
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestCreatePodPortMappings(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	manager := createManager(t)
+	manager.imageManager.(*mocks.ImageManager).GetImageFunc = func(hash string) *schema.ImageManifest {
+		return &schema.ImageManifest{
+			App: &types.App{
+				Ports: []types.Port{
+					{Name: types.ACName("http"), Protocol: "tcp", Port: 80, Count: 1},
+					{Name: types.ACName("dns"), Protocol: "udp", Port: 53, Count: 2},
+				},
+			},
+		}
+	}
+
+	manifest := schema.BlankPodManifest()
+	manifest.Apps = []schema.RuntimeApp{
+		schema.RuntimeApp{
+			Name: types.ACName("sample"),
+			Image: schema.RuntimeImage{
+				ID: *types.NewHashSHA512(nil),
+			},
+		},
+	}
+	manifest.Ports = []types.ExposedPort{{Name: types.ACName("dns"), HostPort: 5353}}
+
+	defer replacePodStartup()()
+
+	// mapping ports requires networking
+	_, err := manager.Create("example", manifest, nil)
+	tt.TestExpectError(t, err)
+
+	manager.SetNetworkManager(&mocks.NetworkManager{
+		HasNetworkFunc:  func(name string) bool { return true },
+		DeprovisionFunc: func(pod backend.Pod) error { return nil },
+	})
+
+	// requested mappings default to tcp, and exposed ports are resolved to the
+	// apps' ports
+	pod, err := manager.Create("example", manifest, &backend.PodOptions{
+		PortMappings: []*ntypes.PortMapping{{HostPort: 8080, ContainerPort: 80}},
+	})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, pod.PortMappings(), []*ntypes.PortMapping{
+		{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+		{HostPort: 5353, ContainerPort: 53, Protocol: "udp"},
+		{HostPort: 5354, ContainerPort: 54, Protocol: "udp"},
+	})
+
+	// host ports already mapped to another pod are rejected
+	other := schema.BlankPodManifest()
+	other.Apps = manifest.Apps
+	_, err = manager.Create("other", other, &backend.PodOptions{
+		PortMappings: []*ntypes.PortMapping{{HostPort: 8080, ContainerPort: 8080, HostIP: "127.0.0.1"}},
+	})
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, err.Error(), `host port 8080/tcp is already mapped to pod "example"`)
+
+	// the same port with another protocol doesn't conflict
+	otherPod, err := manager.Create("other", other, &backend.PodOptions{
+		PortMappings: []*ntypes.PortMapping{{HostPort: 8080, ContainerPort: 8080, Protocol: "udp"}},
+	})
+	tt.TestExpectSuccess(t, err)
+	otherPod.Stop()
+
+	// invalid and duplicate mappings
+	_, err = manager.Create("other", other, &backend.PodOptions{
+		PortMappings: []*ntypes.PortMapping{{HostPort: 8081, ContainerPort: 80, Protocol: "sctp"}},
+	})
+	tt.TestExpectError(t, err)
+	_, err = manager.Create("other", other, &backend.PodOptions{
+		PortMappings: []*ntypes.PortMapping{
+			{HostPort: 8081, ContainerPort: 80},
+			{HostPort: 8081, ContainerPort: 81, HostIP: "0.0.0.0"},
+		},
+	})
+	tt.TestExpectError(t, err)
+
+	// exposed ports must be declared by an app
+	other.Ports = []types.ExposedPort{{Name: types.ACName("missing"), HostPort: 9000}}
+	_, err = manager.Create("other", other, nil)
+	tt.TestExpectError(t, err)
+
+	// once the pod is removed, its ports can be mapped again
+	pod.Stop()
+	other.Ports = nil
+	otherPod, err = manager.Create("other", other, &backend.PodOptions{
+		PortMappings: []*ntypes.PortMapping{{HostPort: 8080, ContainerPort: 8080}},
+	})
+	tt.TestExpectSuccess(t, err)
+	otherPod.Stop()
+}
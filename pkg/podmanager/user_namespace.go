@@ -0,0 +1,105 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package podmanager
+
+import (
+	"fmt"
+	"sync"
+
+	kschema "github.com/apcera/kurma/schema"
+)
+
+const (
+	// userNamespaceSize is the number of uids and gids allocated to each pod
+	// from the host's range. It covers the full 16 bit range that most images
+	// expect to be available.
+	userNamespaceSize = 65536
+)
+
+// IDRange is a range of uids and gids on the host.
+type IDRange struct {
+	Start int `json:"start"`
+	Size  int `json:"size"`
+}
+
+// idAllocator hands out blocks of host ids from a range to pods that run within
+// a user namespace. The same block is used for both uids and gids.
+type idAllocator struct {
+	start  int
+	blocks []string
+	mutex  sync.Mutex
+}
+
+// newIDAllocator returns an allocator for the provided range. It returns an
+// error if the range can't hold a single pod's block or includes root.
+func newIDAllocator(r *IDRange) (*idAllocator, error) {
+	if r.Start <= 0 {
+		return nil, fmt.Errorf("the user namespace range cannot include root")
+	}
+	count := r.Size / userNamespaceSize
+	if count == 0 {
+		return nil, fmt.Errorf("the user namespace range must have room for at least %d ids", userNamespaceSize)
+	}
+	return &idAllocator{
+		start:  r.Start,
+		blocks: make([]string, count),
+	}, nil
+}
+
+// allocate reserves a free block for the pod and returns the first host id in
+// it.
+func (a *idAllocator) allocate(uuid string) (int, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i, owner := range a.blocks {
+		if owner == "" {
+			a.blocks[i] = uuid
+			return a.start + i*userNamespaceSize, nil
+		}
+	}
+	return 0, fmt.Errorf("no user namespace ranges are available")
+}
+
+// reserve marks the block starting at the host id as being used by the pod.
+// This is used when restoring pods. It returns false if the id isn't the start
+// of a block within the range.
+func (a *idAllocator) reserve(uuid string, hostID int) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	offset := hostID - a.start
+	if offset < 0 || offset%userNamespaceSize != 0 || offset/userNamespaceSize >= len(a.blocks) {
+		return false
+	}
+	a.blocks[offset/userNamespaceSize] = uuid
+	return true
+}
+
+// release frees any block held by the pod. It is safe to call on a nil
+// allocator.
+func (a *idAllocator) release(uuid string) {
+	if a == nil {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i, owner := range a.blocks {
+		if owner == uuid {
+			a.blocks[i] = ""
+		}
+	}
+}
+
+// overlaps returns whether any of the mappings fall within the allocator's
+// range.
+func (a *idAllocator) overlaps(mappings []kschema.IDMapping) bool {
+	end := a.start + len(a.blocks)*userNamespaceSize
+	for _, m := range mappings {
+		if m.HostID < end && a.start < m.HostID+m.Size {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package podmanager
+
+import (
+	"testing"
+
+	kschema "github.com/apcera/kurma/schema"
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestIDAllocator(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	_, err := newIDAllocator(&IDRange{Start: 0, Size: 2 * userNamespaceSize})
+	tt.TestExpectError(t, err)
+	_, err = newIDAllocator(&IDRange{Start: 100000, Size: 1000})
+	tt.TestExpectError(t, err)
+
+	a, err := newIDAllocator(&IDRange{Start: 100000, Size: 2 * userNamespaceSize})
+	tt.TestExpectSuccess(t, err)
+
+	id, err := a.allocate("pod1")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, id, 100000)
+
+	id, err = a.allocate("pod2")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, id, 100000+userNamespaceSize)
+
+	// the range is exhausted
+	_, err = a.allocate("pod3")
+	tt.TestExpectError(t, err)
+
+	// releasing allows it to be reused
+	a.release("pod1")
+	id, err = a.allocate("pod3")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, id, 100000)
+
+	// reserving a restored pod's block
+	a.release("pod2")
+	tt.TestEqual(t, a.reserve("pod4", 100000+userNamespaceSize), true)
+	tt.TestEqual(t, a.reserve("pod5", 100001), false)
+	tt.TestEqual(t, a.blocks[1], "pod4")
+
+	// checking for overlapping mappings
+	tt.TestEqual(t, a.overlaps([]kschema.IDMapping{{ContainerID: 0, HostID: 1000, Size: 1000}}), false)
+	tt.TestEqual(t, a.overlaps([]kschema.IDMapping{{ContainerID: 0, HostID: 99000, Size: 2000}}), true)
+}
@@ -11,9 +11,11 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/apcera/kurma/pkg/backend"
 	"github.com/apcera/kurma/pkg/capabilities"
 	"github.com/apcera/util/proc"
 	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema"
 	"github.com/appc/spec/schema/types"
 	"github.com/opencontainers/runc/libcontainer"
 	"github.com/opencontainers/runc/libcontainer/configs"
@@ -31,9 +33,38 @@ func (pod *Pod) stagerRootPath() string {
 	return filepath.Join(pod.directory, "stager")
 }
 
+// imageReferenceOwner returns the owner the pod's image references are
+// recorded under.
+func (pod *Pod) imageReferenceOwner() string {
+	return fmt.Sprintf("pod %s", pod.uuid)
+}
+
+// referenceImage marks the image as being in use by the pod, so it isn't
+// removed while the pod is running.
+func (pod *Pod) referenceImage(hash string) {
+	pod.manager.imageManager.AddReference(hash, pod.imageReferenceOwner())
+}
+
+// referenceImages marks the pod's stager image and all of the image layers
+// used by its apps as being in use, so they aren't removed while the pod is
+// running.
+func (pod *Pod) referenceImages() {
+	pod.referenceImage(pod.options.StagerHash)
+	for hash := range pod.manifest.Images {
+		pod.referenceImage(hash)
+	}
+}
+
 func (pod *Pod) generateContainerConfig() (*configs.Config, error) {
 	root := pod.stagerRootPath()
 
+	limits, err := getResourceLimits(pod.manifest.Pod, func(hash string) *schema.ImageManifest {
+		return pod.manifest.Images[hash]
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	config := &configs.Config{
 		// Settings that vary per container
 		Rootfs: root,
@@ -77,6 +108,11 @@ func (pod *Pod) generateContainerConfig() (*configs.Config, error) {
 		},
 	}
 
+	// Constrain the pod as a whole to the aggregate of its resource limits
+	if err := limits.Apply(config.Cgroups.Resources); err != nil {
+		return nil, fmt.Errorf("failed to apply the pod's resource limits: %v", err)
+	}
+
 	// Add the layer mounts
 	for layer, layerPath := range pod.layerPaths {
 		dst := filepath.Join("/layers", layer)
@@ -235,8 +271,20 @@ func convertACIdentifierToACName(name types.ACIdentifier) (*types.ACName, error)
 	return types.NewACName(n)
 }
 
-// waitRoutine is used to track when the stager exits and to respond by tearing
-// down the pod.
+// stagerStateExited is the state the stager records once it has finished
+// tearing down the pod.
+const stagerStateExited = "exited"
+
+// stagerState is the portion of the state persisted by the stager that is read
+// once it has exited.
+type stagerState struct {
+	Apps     map[string]*backend.AppStatus `json:"apps"`
+	State    string                        `json:"state"`
+	ExitCode int                           `json:"exitCode"`
+}
+
+// waitRoutine is used to track when the stager exits and to respond by
+// releasing the pod's runtime resources.
 func (pod *Pod) waitRoutine() {
 	proc := pod.stagerProcess
 	if proc == nil {
@@ -257,7 +305,7 @@ func (pod *Pod) waitRoutine() {
 		if pod.isShuttingDown() {
 			return
 		}
-		go pod.Stop()
+		go pod.exit()
 	}()
 }
 
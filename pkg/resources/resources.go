@@ -0,0 +1,340 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package resources
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/appc/spec/schema/types"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+const (
+	// MinimumMemory is the smallest memory limit that can be applied to a pod
+	// or app. Anything smaller is unlikely to be able to even exec.
+	MinimumMemory = 4 * 1024 * 1024
+
+	// StagerMemoryOverhead is added to the memory limit of a pod when it is
+	// calculated from the limits of its apps, to account for the stager and the
+	// init process which run in the pod's cgroup.
+	StagerMemoryOverhead = 32 * 1024 * 1024
+
+	// cpuPeriod is the CFS period, in microseconds, used when applying a CPU
+	// limit.
+	cpuPeriod = 100000
+
+	// minimumCPUShares is the smallest value the kernel accepts for cpu.shares.
+	minimumCPUShares = 2
+)
+
+var (
+	// The following are variables to allow them to be swapped out in tests.
+	blockDevicesPath = "/sys/block"
+	hostMemory       = totalMemory
+	hostCPUs         = runtime.NumCPU
+
+	memTotalPattern = regexp.MustCompile(`MemTotal:\s+([0-9]+)`)
+)
+
+// Limits is the set of resource constraints specified by the appc resource
+// isolators. Memory and block values are in bytes, CPU values are in
+// millicores. A value of zero means it is unset.
+type Limits struct {
+	MemoryLimit    int64
+	MemoryRequest  int64
+	CPULimit       int64
+	CPURequest     int64
+	BlockBandwidth int64
+	BlockIOPS      int64
+}
+
+// FromIsolators returns the limits specified by any resource isolators in the
+// provided list. Other isolators are ignored.
+func FromIsolators(isolators types.Isolators) *Limits {
+	l := &Limits{}
+	for _, iso := range isolators {
+		l.Set(iso.Value())
+	}
+	return l
+}
+
+// Set updates the limits from the provided isolator value. Values that aren't
+// resource isolators are ignored.
+func (l *Limits) Set(iso types.IsolatorValue) {
+	switch riso := iso.(type) {
+	case *types.ResourceMemory:
+		if q := riso.Limit(); q != nil {
+			l.MemoryLimit = q.Value()
+		}
+		if q := riso.Request(); q != nil {
+			l.MemoryRequest = q.Value()
+		}
+	case *types.ResourceCPU:
+		if q := riso.Limit(); q != nil {
+			l.CPULimit = q.MilliValue()
+		}
+		if q := riso.Request(); q != nil {
+			l.CPURequest = q.MilliValue()
+		}
+	case *types.ResourceBlockBandwidth:
+		if q := riso.Limit(); q != nil {
+			l.BlockBandwidth = q.Value()
+		}
+	case *types.ResourceBlockIOPS:
+		if q := riso.Limit(); q != nil {
+			l.BlockIOPS = q.Value()
+		}
+	}
+}
+
+// Validate ensures the limits are consistent with each other and can be
+// satisfied by the host.
+func (l *Limits) Validate() error {
+	if l.MemoryLimit < 0 || l.MemoryRequest < 0 || l.CPULimit < 0 || l.CPURequest < 0 ||
+		l.BlockBandwidth < 0 || l.BlockIOPS < 0 {
+		return fmt.Errorf("resource values must not be negative")
+	}
+
+	if l.MemoryLimit > 0 {
+		if l.MemoryLimit < MinimumMemory {
+			return fmt.Errorf("the memory limit of %d bytes is below the minimum of %d bytes", l.MemoryLimit, MinimumMemory)
+		}
+		if l.MemoryRequest > l.MemoryLimit {
+			return fmt.Errorf("the memory request of %d bytes exceeds the limit of %d bytes", l.MemoryRequest, l.MemoryLimit)
+		}
+	}
+	if l.CPULimit > 0 && l.CPURequest > l.CPULimit {
+		return fmt.Errorf("the cpu request of %dm exceeds the limit of %dm", l.CPURequest, l.CPULimit)
+	}
+
+	total, err := hostMemory()
+	if err != nil {
+		return fmt.Errorf("failed to determine the host's memory: %v", err)
+	}
+	if l.MemoryLimit > total || l.MemoryRequest > total {
+		return fmt.Errorf("the requested memory exceeds the host's memory of %d bytes", total)
+	}
+	cpus := int64(hostCPUs()) * 1000
+	if l.CPULimit > cpus || l.CPURequest > cpus {
+		return fmt.Errorf("the requested cpu exceeds the host's %dm", cpus)
+	}
+	return nil
+}
+
+// PodLimits returns the limits which should be applied to the cgroup of a pod
+// as a whole. If the pod manifest specifies a resource isolator, it is used,
+// otherwise the limit is the sum of the apps' limits if every app sets one.
+// Requests are always the sum of the apps' requests. An error is returned if
+// the limits of the pod or any app can't be satisfied.
+func PodLimits(podIsolators types.Isolators, appIsolators []types.Isolators) (*Limits, error) {
+	pod := FromIsolators(podIsolators)
+	if err := pod.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid pod resources: %v", err)
+	}
+
+	apps := make([]*Limits, len(appIsolators))
+	for i, isolators := range appIsolators {
+		apps[i] = FromIsolators(isolators)
+		if err := apps[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Limits can be overcommitted, so a sum of the apps' limits which exceeds
+	// what the host has is left unbounded rather than rejected.
+	sum := sumLimits(apps)
+	if sum.MemoryLimit > 0 {
+		sum.MemoryLimit += StagerMemoryOverhead
+		if total, err := hostMemory(); err == nil && sum.MemoryLimit > total {
+			sum.MemoryLimit = 0
+		}
+	}
+	if sum.CPULimit > int64(hostCPUs())*1000 {
+		sum.CPULimit = 0
+	}
+
+	// Ensure the apps fit within any limits set on the pod.
+	if pod.MemoryLimit > 0 {
+		for _, app := range apps {
+			if app.MemoryLimit > pod.MemoryLimit {
+				return nil, fmt.Errorf("an app's memory limit exceeds the pod's limit of %d bytes", pod.MemoryLimit)
+			}
+		}
+		if sum.MemoryRequest > pod.MemoryLimit {
+			return nil, fmt.Errorf("the apps' memory requests total %d bytes, exceeding the pod's limit of %d bytes", sum.MemoryRequest, pod.MemoryLimit)
+		}
+	}
+	if pod.CPULimit > 0 {
+		for _, app := range apps {
+			if app.CPULimit > pod.CPULimit {
+				return nil, fmt.Errorf("an app's cpu limit exceeds the pod's limit of %dm", pod.CPULimit)
+			}
+		}
+		if sum.CPURequest > pod.CPULimit {
+			return nil, fmt.Errorf("the apps' cpu requests total %dm, exceeding the pod's limit of %dm", sum.CPURequest, pod.CPULimit)
+		}
+	}
+
+	// Fill in anything not set on the pod from the apps.
+	if pod.MemoryLimit == 0 {
+		pod.MemoryLimit = sum.MemoryLimit
+	}
+	if pod.MemoryRequest == 0 {
+		pod.MemoryRequest = sum.MemoryRequest
+	}
+	if pod.CPULimit == 0 {
+		pod.CPULimit = sum.CPULimit
+	}
+	if pod.CPURequest == 0 {
+		pod.CPURequest = sum.CPURequest
+	}
+	if pod.BlockBandwidth == 0 {
+		pod.BlockBandwidth = sum.BlockBandwidth
+	}
+	if pod.BlockIOPS == 0 {
+		pod.BlockIOPS = sum.BlockIOPS
+	}
+
+	// Ensure the combined values still fit on the host.
+	if err := pod.Validate(); err != nil {
+		return nil, fmt.Errorf("the pod's combined resources can't be satisfied: %v", err)
+	}
+	return pod, nil
+}
+
+// sumLimits totals the provided limits. Requests are always summed, while a
+// limit is only summed if every entry has it set, since an entry without a
+// limit is unbounded.
+func sumLimits(limits []*Limits) *Limits {
+	sum := &Limits{}
+	if len(limits) == 0 {
+		return sum
+	}
+
+	limitAll := func(f func(*Limits) int64) int64 {
+		var total int64
+		for _, l := range limits {
+			if f(l) == 0 {
+				return 0
+			}
+			total += f(l)
+		}
+		return total
+	}
+
+	sum.MemoryLimit = limitAll(func(l *Limits) int64 { return l.MemoryLimit })
+	sum.CPULimit = limitAll(func(l *Limits) int64 { return l.CPULimit })
+	sum.BlockBandwidth = limitAll(func(l *Limits) int64 { return l.BlockBandwidth })
+	sum.BlockIOPS = limitAll(func(l *Limits) int64 { return l.BlockIOPS })
+	for _, l := range limits {
+		sum.MemoryRequest += l.MemoryRequest
+		sum.CPURequest += l.CPURequest
+	}
+	return sum
+}
+
+// Apply sets the limits on the provided cgroup resources. Only the values which
+// have been set are applied, so it can be called repeatedly to layer limits.
+func (l *Limits) Apply(r *configs.Resources) error {
+	if l.MemoryLimit > 0 {
+		r.Memory = l.MemoryLimit
+		// Prevent the limit from being sidestepped by swapping.
+		r.MemorySwap = l.MemoryLimit
+	}
+	if l.MemoryRequest > 0 {
+		r.MemoryReservation = l.MemoryRequest
+	}
+	if l.CPURequest > 0 {
+		shares := l.CPURequest * 1024 / 1000
+		if shares < minimumCPUShares {
+			shares = minimumCPUShares
+		}
+		r.CpuShares = shares
+	}
+	if l.CPULimit > 0 {
+		r.CpuPeriod = cpuPeriod
+		r.CpuQuota = l.CPULimit * cpuPeriod / 1000
+	}
+
+	if l.BlockBandwidth == 0 && l.BlockIOPS == 0 {
+		return nil
+	}
+
+	devices, err := blockDevices()
+	if err != nil {
+		return fmt.Errorf("failed to list block devices: %v", err)
+	}
+	for _, dev := range devices {
+		if l.BlockBandwidth > 0 {
+			rate := uint64(l.BlockBandwidth)
+			r.BlkioThrottleReadBpsDevice = append(r.BlkioThrottleReadBpsDevice, configs.NewThrottleDevice(dev[0], dev[1], rate))
+			r.BlkioThrottleWriteBpsDevice = append(r.BlkioThrottleWriteBpsDevice, configs.NewThrottleDevice(dev[0], dev[1], rate))
+		}
+		if l.BlockIOPS > 0 {
+			rate := uint64(l.BlockIOPS)
+			r.BlkioThrottleReadIOPSDevice = append(r.BlkioThrottleReadIOPSDevice, configs.NewThrottleDevice(dev[0], dev[1], rate))
+			r.BlkioThrottleWriteIOPSDevice = append(r.BlkioThrottleWriteIOPSDevice, configs.NewThrottleDevice(dev[0], dev[1], rate))
+		}
+	}
+	return nil
+}
+
+// blockDevices returns the major and minor numbers of the block devices on the
+// host. The appc block isolators apply to all devices.
+func blockDevices() ([][2]int64, error) {
+	fis, err := ioutil.ReadDir(blockDevicesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([][2]int64, 0, len(fis))
+	for _, fi := range fis {
+		b, err := ioutil.ReadFile(filepath.Join(blockDevicesPath, fi.Name(), "dev"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		parts := strings.SplitN(strings.TrimSpace(string(b)), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("unexpected device number %q for %s", string(b), fi.Name())
+		}
+		major, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse major number for %s: %v", fi.Name(), err)
+		}
+		minor, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse minor number for %s: %v", fi.Name(), err)
+		}
+		devices = append(devices, [2]int64{major, minor})
+	}
+	return devices, nil
+}
+
+// totalMemory returns the total memory on the host in bytes.
+func totalMemory() (int64, error) {
+	meminfo, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+
+	match := memTotalPattern.FindStringSubmatch(string(meminfo))
+	if match == nil {
+		return 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+	}
+	kb, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return kb * 1024, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appc/spec/schema/types"
+	"github.com/opencontainers/runc/libcontainer/configs"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+const gigabyte = 1024 * 1024 * 1024
+
+func mockHost(t *testing.T) {
+	origMemory, origCPUs := hostMemory, hostCPUs
+	hostMemory = func() (int64, error) { return 4 * gigabyte, nil }
+	hostCPUs = func() int { return 2 }
+	tt.AddTestFinalizer(func() {
+		hostMemory, hostCPUs = origMemory, origCPUs
+	})
+}
+
+func resourceIsolator(t *testing.T, name, request, limit string) types.Isolator {
+	var iso types.Isolator
+	b := fmt.Sprintf(`{"name":%q,"value":{"request":%q,"limit":%q}}`, name, request, limit)
+	tt.TestExpectSuccess(t, json.Unmarshal([]byte(b), &iso))
+	return iso
+}
+
+func memoryIsolator(t *testing.T, request, limit string) types.Isolator {
+	return resourceIsolator(t, types.ResourceMemoryName, request, limit)
+}
+
+func cpuIsolator(t *testing.T, request, limit string) types.Isolator {
+	return resourceIsolator(t, types.ResourceCPUName, request, limit)
+}
+
+func TestFromIsolators(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	limits := FromIsolators(types.Isolators{
+		memoryIsolator(t, "128Mi", "256Mi"),
+		cpuIsolator(t, "250m", "1"),
+	})
+	tt.TestEqual(t, limits.MemoryRequest, int64(128*1024*1024))
+	tt.TestEqual(t, limits.MemoryLimit, int64(256*1024*1024))
+	tt.TestEqual(t, limits.CPURequest, int64(250))
+	tt.TestEqual(t, limits.CPULimit, int64(1000))
+	tt.TestEqual(t, limits.BlockBandwidth, int64(0))
+}
+
+func TestValidate(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+	mockHost(t)
+
+	tt.TestExpectSuccess(t, (&Limits{MemoryLimit: gigabyte, CPULimit: 2000}).Validate())
+
+	// request above the limit
+	tt.TestExpectError(t, (&Limits{MemoryRequest: 2 * gigabyte, MemoryLimit: gigabyte}).Validate())
+	tt.TestExpectError(t, (&Limits{CPURequest: 1000, CPULimit: 500}).Validate())
+
+	// below the minimum
+	tt.TestExpectError(t, (&Limits{MemoryLimit: 1024}).Validate())
+
+	// more than the host has
+	tt.TestExpectError(t, (&Limits{MemoryLimit: 8 * gigabyte}).Validate())
+	tt.TestExpectError(t, (&Limits{CPURequest: 4000}).Validate())
+}
+
+func TestPodLimits(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+	mockHost(t)
+
+	apps := []types.Isolators{
+		types.Isolators{memoryIsolator(t, "128Mi", "256Mi"), cpuIsolator(t, "500m", "1")},
+		types.Isolators{memoryIsolator(t, "64Mi", "128Mi"), cpuIsolator(t, "250m", "500m")},
+	}
+
+	// With no pod level isolators, the pod gets the sum of the apps.
+	limits, err := PodLimits(nil, apps)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, limits.MemoryLimit, int64(384*1024*1024+StagerMemoryOverhead))
+	tt.TestEqual(t, limits.MemoryRequest, int64(192*1024*1024))
+	tt.TestEqual(t, limits.CPULimit, int64(1500))
+	tt.TestEqual(t, limits.CPURequest, int64(750))
+
+	// If an app doesn't have a limit, the pod shouldn't either.
+	limits, err = PodLimits(nil, append(apps, types.Isolators{}))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, limits.MemoryLimit, int64(0))
+	tt.TestEqual(t, limits.CPULimit, int64(0))
+
+	// A pod level isolator takes precedence.
+	limits, err = PodLimits(types.Isolators{memoryIsolator(t, "0", "512Mi")}, apps)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, limits.MemoryLimit, int64(512*1024*1024))
+	tt.TestEqual(t, limits.CPULimit, int64(1500))
+
+	// An app limit above the pod's limit can't be satisfied.
+	_, err = PodLimits(types.Isolators{memoryIsolator(t, "0", "200Mi")}, apps)
+	tt.TestExpectError(t, err)
+
+	// Nor can requests that add up to more than the pod's limit.
+	_, err = PodLimits(types.Isolators{cpuIsolator(t, "0", "1")}, []types.Isolators{
+		types.Isolators{cpuIsolator(t, "750m", "1")},
+		types.Isolators{cpuIsolator(t, "750m", "1")},
+	})
+	tt.TestExpectError(t, err)
+
+	// Nor can an app which is invalid on its own.
+	_, err = PodLimits(nil, []types.Isolators{types.Isolators{cpuIsolator(t, "3", "3")}})
+	tt.TestExpectError(t, err)
+}
+
+func TestApply(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	dir := tt.TempDir(t)
+	origPath := blockDevicesPath
+	blockDevicesPath = dir
+	defer func() { blockDevicesPath = origPath }()
+
+	tt.TestExpectSuccess(t, os.MkdirAll(filepath.Join(dir, "sda"), os.FileMode(0755)))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "sda", "dev"), []byte("8:0\n"), os.FileMode(0644)))
+
+	limits := &Limits{
+		MemoryLimit:    gigabyte,
+		MemoryRequest:  gigabyte / 2,
+		CPULimit:       1500,
+		CPURequest:     1,
+		BlockBandwidth: 1024 * 1024,
+	}
+	r := &configs.Resources{}
+	tt.TestExpectSuccess(t, limits.Apply(r))
+
+	tt.TestEqual(t, r.Memory, int64(gigabyte))
+	tt.TestEqual(t, r.MemorySwap, int64(gigabyte))
+	tt.TestEqual(t, r.MemoryReservation, int64(gigabyte/2))
+	tt.TestEqual(t, r.CpuShares, int64(minimumCPUShares))
+	tt.TestEqual(t, r.CpuPeriod, int64(100000))
+	tt.TestEqual(t, r.CpuQuota, int64(150000))
+	tt.TestEqual(t, len(r.BlkioThrottleReadBpsDevice), 1)
+	tt.TestEqual(t, r.BlkioThrottleReadBpsDevice[0].String(), "8:0 1048576")
+	tt.TestEqual(t, len(r.BlkioThrottleWriteBpsDevice), 1)
+	tt.TestEqual(t, len(r.BlkioThrottleReadIOPSDevice), 0)
+}
@@ -0,0 +1,37 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package schema
+
+import (
+	"encoding/json"
+
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	AppInteractiveName = "app/interactive"
+)
+
+func init() {
+	types.AddIsolatorValueConstructor(AppInteractiveName, newAppInteractive)
+}
+
+func newAppInteractive() types.IsolatorValue {
+	n := AppInteractive(false)
+	return &n
+}
+
+type AppInteractive bool
+
+func (n *AppInteractive) UnmarshalJSON(b []byte) error {
+	interactive := false
+	if err := json.Unmarshal(b, &interactive); err != nil {
+		return err
+	}
+	*n = AppInteractive(interactive)
+	return nil
+}
+
+func (n AppInteractive) AssertValid() error {
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	AppRestartPolicyName = "app/restart-policy"
+
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+func init() {
+	types.AddIsolatorValueConstructor(AppRestartPolicyName, newAppRestartPolicy)
+}
+
+func newAppRestartPolicy() types.IsolatorValue {
+	return &AppRestartPolicy{Policy: RestartNever}
+}
+
+// AppRestartPolicy specifies whether an application should be restarted when
+// it exits. It can be set on an app, or on the pod to apply to all apps that
+// don't specify their own.
+type AppRestartPolicy struct {
+	// Policy is one of "never", "on-failure", or "always".
+	Policy string `json:"policy"`
+
+	// MaxRetries is the maximum number of times the app will be restarted. Zero
+	// means there is no limit.
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+func (n *AppRestartPolicy) UnmarshalJSON(b []byte) error {
+	var policy struct {
+		Policy     string `json:"policy"`
+		MaxRetries int    `json:"maxRetries"`
+	}
+	if err := json.Unmarshal(b, &policy); err != nil {
+		return err
+	}
+	n.Policy = policy.Policy
+	n.MaxRetries = policy.MaxRetries
+	return nil
+}
+
+func (n *AppRestartPolicy) AssertValid() error {
+	switch n.Policy {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unrecognized restart policy %q", n.Policy)
+	}
+	if n.MaxRetries < 0 {
+		return fmt.Errorf("maxRetries must not be negative")
+	}
+	return nil
+}
+
+// ShouldRestart returns whether an app with the specified exit code should be
+// restarted, given the number of times it has already been restarted.
+func (n *AppRestartPolicy) ShouldRestart(exitCode, restartCount int) bool {
+	if n.MaxRetries > 0 && restartCount >= n.MaxRetries {
+		return false
+	}
+	switch n.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	LinuxUserNamespaceName = "os/linux/user-namespace"
+)
+
+func init() {
+	types.AddIsolatorValueConstructor(LinuxUserNamespaceName, newLinuxUserNamespace)
+}
+
+func newLinuxUserNamespace() types.IsolatorValue {
+	return &LinuxUserNamespace{}
+}
+
+// IDMapping maps a range of uids or gids within a user namespace to a range of
+// ids on the host.
+type IDMapping struct {
+	ContainerID int `json:"containerID"`
+	HostID      int `json:"hostID"`
+	Size        int `json:"size"`
+}
+
+// LinuxUserNamespace is a pod isolator which requests that the pod be run
+// within a user namespace. The mappings can be specified explicitly, or if they
+// are omitted, a range will be allocated to the pod by the host.
+type LinuxUserNamespace struct {
+	UIDMappings []IDMapping `json:"uidMappings,omitempty"`
+	GIDMappings []IDMapping `json:"gidMappings,omitempty"`
+}
+
+func (n *LinuxUserNamespace) UnmarshalJSON(b []byte) error {
+	type linuxUserNamespace LinuxUserNamespace
+	var v linuxUserNamespace
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*n = LinuxUserNamespace(v)
+	return nil
+}
+
+func (n LinuxUserNamespace) AssertValid() error {
+	if len(n.UIDMappings) == 0 && len(n.GIDMappings) == 0 {
+		return nil
+	}
+	if err := assertValidMappings(n.UIDMappings); err != nil {
+		return fmt.Errorf("invalid uidMappings: %v", err)
+	}
+	if err := assertValidMappings(n.GIDMappings); err != nil {
+		return fmt.Errorf("invalid gidMappings: %v", err)
+	}
+	return nil
+}
+
+// HasMappings returns whether the uid and gid mappings have been specified.
+func (n *LinuxUserNamespace) HasMappings() bool {
+	return len(n.UIDMappings) > 0 && len(n.GIDMappings) > 0
+}
+
+// assertValidMappings ensures that root within the namespace is mapped, that
+// root on the host is not, and that none of the ranges overlap.
+func assertValidMappings(mappings []IDMapping) error {
+	if len(mappings) == 0 {
+		return fmt.Errorf("at least one mapping must be specified")
+	}
+
+	rootMapped := false
+	for i, m := range mappings {
+		if m.ContainerID < 0 || m.HostID < 0 || m.Size <= 0 {
+			return fmt.Errorf("ids must not be negative and the size must be positive")
+		}
+		if m.HostID == 0 {
+			return fmt.Errorf("the host's root cannot be mapped")
+		}
+		if m.ContainerID == 0 {
+			rootMapped = true
+		}
+		for _, o := range mappings[:i] {
+			if m.ContainerID < o.ContainerID+o.Size && o.ContainerID < m.ContainerID+m.Size {
+				return fmt.Errorf("the container id ranges overlap")
+			}
+			if m.HostID < o.HostID+o.Size && o.HostID < m.HostID+m.Size {
+				return fmt.Errorf("the host id ranges overlap")
+			}
+		}
+	}
+	if !rootMapped {
+		return fmt.Errorf("root within the namespace must be mapped")
+	}
+	return nil
+}
+
+// GenerateUserNamespaceIsolator returns a user namespace isolator with the
+// provided mappings. Like with GenerateHostNamespaceIsolator, it is looped
+// through JSON so the isolator's value is populated.
+func GenerateUserNamespaceIsolator(uidMappings, gidMappings []IDMapping) (*types.Isolator, error) {
+	var interim struct {
+		Name  string              `json:"name"`
+		Value types.IsolatorValue `json:"value"`
+	}
+	interim.Name = LinuxUserNamespaceName
+	interim.Value = &LinuxUserNamespace{
+		UIDMappings: uidMappings,
+		GIDMappings: gidMappings,
+	}
+
+	b, err := json.Marshal(interim)
+	if err != nil {
+		return nil, err
+	}
+
+	var i types.Isolator
+	if err := i.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
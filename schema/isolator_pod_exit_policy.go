@@ -0,0 +1,69 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	PodExitPolicyName = "pod/exit-policy"
+
+	ExitPolicyNone = "none"
+	ExitPolicyAll  = "all"
+	ExitPolicyAny  = "any"
+	ExitPolicyApp  = "app"
+)
+
+func init() {
+	types.AddIsolatorValueConstructor(PodExitPolicyName, newPodExitPolicy)
+}
+
+func newPodExitPolicy() types.IsolatorValue {
+	return &PodExitPolicy{Policy: ExitPolicyNone}
+}
+
+// PodExitPolicy specifies when the pod should be stopped based on its apps
+// exiting. Apps which will be restarted based on their restart policy are not
+// considered to have exited.
+type PodExitPolicy struct {
+	// Policy is one of "none", "all", "any", or "app". With "none", the pod
+	// keeps running until it is explicitly stopped.
+	Policy string `json:"policy"`
+
+	// App is the name of the app which stops the pod when it exits. It is only
+	// used with the "app" policy.
+	App types.ACName `json:"app,omitempty"`
+}
+
+func (n *PodExitPolicy) UnmarshalJSON(b []byte) error {
+	var policy struct {
+		Policy string       `json:"policy"`
+		App    types.ACName `json:"app"`
+	}
+	if err := json.Unmarshal(b, &policy); err != nil {
+		return err
+	}
+	n.Policy = policy.Policy
+	n.App = policy.App
+	return nil
+}
+
+func (n *PodExitPolicy) AssertValid() error {
+	switch n.Policy {
+	case ExitPolicyNone, ExitPolicyAll, ExitPolicyAny:
+		if !n.App.Empty() {
+			return fmt.Errorf("an app can only be specified with the %q exit policy", ExitPolicyApp)
+		}
+	case ExitPolicyApp:
+		if n.App.Empty() {
+			return fmt.Errorf("the %q exit policy requires an app", ExitPolicyApp)
+		}
+	default:
+		return fmt.Errorf("unrecognized exit policy %q", n.Policy)
+	}
+	return nil
+}
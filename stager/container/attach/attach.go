@@ -0,0 +1,39 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package attach
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/apcera/kurma/stager/container/common"
+)
+
+// Run attaches to the input and output of the specified application over its
+// attach socket. It returns once stdin is closed, which is how the caller
+// detaches, or once the application exits.
+func Run() error {
+	if len(os.Args) != 2 {
+		return fmt.Errorf("the name of the application must be specified")
+	}
+
+	conn, err := net.Dial("unix", common.AttachSocketPath(os.Args[1]))
+	if err != nil {
+		return fmt.Errorf("failed to attach to application %q: %v", os.Args[1], err)
+	}
+	defer conn.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(conn, os.Stdin)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(os.Stdout, conn)
+		done <- struct{}{}
+	}()
+	<-done
+	return nil
+}
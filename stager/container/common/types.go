@@ -2,12 +2,31 @@
 
 package common
 
+import (
+	"path/filepath"
+)
+
+// StagerStateFile is the path within the stager's filesystem where the current
+// StagerState is persisted for the call in commands to read.
+const StagerStateFile = "/state.json"
+
+// AttachDirectory is the path within the stager's filesystem where the unix
+// sockets used to attach to an application's input and output are created.
+const AttachDirectory = "/attach"
+
+// AttachSocketPath returns the path of the attach socket for the specified
+// application.
+func AttachSocketPath(appName string) string {
+	return filepath.Join(AttachDirectory, appName+".sock")
+}
+
 type StagerRuntimeState string
 
 const (
 	StagerStateSetup    = StagerRuntimeState("setup")
 	StagerStateRunning  = StagerRuntimeState("running")
 	StagerStateTeardown = StagerRuntimeState("teardown")
+	StagerStateExited   = StagerRuntimeState("exited")
 )
 
 type StagerConfig struct {
@@ -19,6 +38,10 @@ type StagerConfig struct {
 type StagerState struct {
 	Apps  map[string]*StagerAppState `json:"apps"`
 	State StagerRuntimeState         `json:"state"`
+
+	// ExitCode is the exit code of the pod as a whole. It is set once the
+	// stager has finished tearing down the pod and the state is "exited".
+	ExitCode int `json:"exitCode,omitempty"`
 }
 
 type StagerAppState struct {
@@ -26,4 +49,8 @@ type StagerAppState struct {
 	Exited     bool   `json:"exited"`
 	ExitCode   int    `json:"exitCode,omitempty"`
 	ExitReason string `json:"exitReason,omitempty"`
+
+	// RestartCount is the number of times the app has been restarted based on
+	// its restart policy.
+	RestartCount int `json:"restartCount,omitempty"`
 }
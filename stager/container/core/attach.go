@@ -0,0 +1,219 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package core
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/pkg/logfile"
+	"github.com/apcera/kurma/stager/container/common"
+	"github.com/apcera/logray"
+	"github.com/opencontainers/runc/libcontainer"
+)
+
+// attachWriteTimeout is how long a write to an attached client may block
+// before the client is dropped. This ensures a slow client can't stall the
+// application's output.
+const attachWriteTimeout = 5 * time.Second
+
+// appOutput handles the output of an application. It writes the output to the
+// application's log file and fans it out to any clients that have attached to
+// the application over its attach socket. It is retained across restarts of
+// the application.
+type appOutput struct {
+	log *logray.Logger
+
+	applog    *os.File
+	logWriter io.Writer
+	listener  net.Listener
+
+	// console is the current process's console, which input from attached
+	// clients is written to. It is nil if the application was not launched
+	// interactively.
+	console      io.ReadWriteCloser
+	consoleMutex sync.Mutex
+
+	// copyDone is closed once all output from the current process's console has
+	// been copied. It is nil if no console was allocated.
+	copyDone chan struct{}
+
+	mutex   sync.Mutex
+	clients map[net.Conn]bool
+}
+
+// newAppOutput opens the log file for the application and creates its attach
+// socket. Clients aren't accepted until accept is called.
+func newAppOutput(log *logray.Logger, name string) (*appOutput, error) {
+	flags := os.O_WRONLY | os.O_APPEND | os.O_CREATE | os.O_EXCL | os.O_TRUNC
+	applog, err := os.OpenFile(filepath.Join("/logs", name), flags, os.FileMode(0666))
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", common.AttachSocketPath(name))
+	if err != nil {
+		applog.Close()
+		return nil, fmt.Errorf("failed to listen on attach socket: %v", err)
+	}
+
+	ao := &appOutput{
+		log:       log,
+		applog:    applog,
+		logWriter: logfile.NewTimestampWriter(applog),
+		listener:  listener,
+		clients:   make(map[net.Conn]bool),
+	}
+	return ao, nil
+}
+
+// writer returns the io.Writer the application's output should be written to.
+// Each line written to the log file is prefixed with a timestamp.
+func (ao *appOutput) writer() io.Writer {
+	return io.MultiWriter(ao.logWriter, ao)
+}
+
+// setupProcess configures the process's input and output. Interactive apps get
+// a console, which attached clients can write to, unless specific inputs or
+// outputs were passed in for the app.
+func (ao *appOutput) setupProcess(process *libcontainer.Process, interactive bool) error {
+	if interactive && process.Stdin == nil && process.Stdout == nil && process.Stderr == nil {
+		console, err := process.NewConsole(os.Getuid())
+		if err != nil {
+			return fmt.Errorf("failed to allocate console: %v", err)
+		}
+		ao.copyConsole(console)
+		return nil
+	}
+
+	appout := ao.writer()
+	if process.Stdout == nil {
+		process.Stdout = appout
+	}
+	if process.Stderr == nil {
+		process.Stderr = appout
+	}
+	return nil
+}
+
+// copyConsole copies the output of the process's console and sets it as the
+// destination for input from attached clients.
+func (ao *appOutput) copyConsole(console io.ReadWriteCloser) {
+	copyDone := make(chan struct{})
+	ao.consoleMutex.Lock()
+	ao.console = console
+	ao.copyDone = copyDone
+	ao.consoleMutex.Unlock()
+
+	go func() {
+		defer close(copyDone)
+		io.Copy(ao.writer(), console)
+	}()
+}
+
+// Write sends the output to all attached clients. It never returns an error so
+// that attached clients can't interfere with writing the log file.
+func (ao *appOutput) Write(p []byte) (int, error) {
+	ao.mutex.Lock()
+	defer ao.mutex.Unlock()
+
+	for conn := range ao.clients {
+		conn.SetWriteDeadline(time.Now().Add(attachWriteTimeout))
+		if _, err := conn.Write(p); err != nil {
+			ao.log.Debugf("Dropping attached client: %v", err)
+			conn.Close()
+			delete(ao.clients, conn)
+		}
+	}
+	return len(p), nil
+}
+
+// accept handles new clients attaching to the application. It returns once the
+// attach socket is closed.
+func (ao *appOutput) accept() {
+	for {
+		conn, err := ao.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		ao.mutex.Lock()
+		ao.clients[conn] = true
+		ao.mutex.Unlock()
+
+		go ao.handleInput(conn)
+	}
+}
+
+// handleInput copies any input from the attached client to the current
+// process's console, or discards it if the application isn't interactive. The
+// client is removed once it disconnects.
+func (ao *appOutput) handleInput(conn net.Conn) {
+	io.Copy(inputWriter{ao}, conn)
+
+	ao.mutex.Lock()
+	defer ao.mutex.Unlock()
+	if ao.clients[conn] {
+		conn.Close()
+		delete(ao.clients, conn)
+	}
+}
+
+// inputWriter writes input from attached clients to the current console.
+type inputWriter struct {
+	ao *appOutput
+}
+
+func (w inputWriter) Write(p []byte) (int, error) {
+	w.ao.consoleMutex.Lock()
+	console := w.ao.console
+	w.ao.consoleMutex.Unlock()
+
+	if console == nil {
+		return ioutil.Discard.Write(p)
+	}
+	if _, err := console.Write(p); err != nil {
+		// The process may have exited and is possibly being restarted, so the
+		// input is dropped rather than disconnecting the client.
+		w.ao.log.Debugf("Failed to write input to console: %v", err)
+	}
+	return len(p), nil
+}
+
+// processExited is called once the application's current process has exited.
+// It waits for any remaining console output to be written and releases the
+// console.
+func (ao *appOutput) processExited() {
+	ao.consoleMutex.Lock()
+	console := ao.console
+	copyDone := ao.copyDone
+	ao.console = nil
+	ao.copyDone = nil
+	ao.consoleMutex.Unlock()
+
+	if copyDone != nil {
+		<-copyDone
+		console.Close()
+	}
+}
+
+// close is called once the application has finally exited and won't be
+// restarted. It closes the log file and disconnects all attached clients.
+func (ao *appOutput) close() {
+	ao.processExited()
+	ao.listener.Close()
+	ao.applog.Close()
+
+	ao.mutex.Lock()
+	defer ao.mutex.Unlock()
+	for conn := range ao.clients {
+		conn.Close()
+		delete(ao.clients, conn)
+	}
+}
@@ -5,6 +5,7 @@ package core
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -18,7 +19,11 @@ import (
 	"github.com/apcera/kurma/pkg/graphstorage/overlay"
 	"github.com/apcera/kurma/stager/container/common"
 	"github.com/apcera/logray"
+	"github.com/appc/spec/schema/types"
 	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/configs"
+
+	kschema "github.com/apcera/kurma/schema"
 )
 
 type containerSetup struct {
@@ -32,6 +37,12 @@ type containerSetup struct {
 	state      *common.StagerState
 	stateMutex sync.Mutex
 	isStopping bool
+	stopMutex  sync.Mutex
+	shutdownCh chan struct{}
+
+	// exitCode is set once the pod's exit policy has been met, and is used as
+	// the exit code of the pod.
+	exitCode *int
 
 	// libcontainer related objects
 	factory       libcontainer.Factory
@@ -44,6 +55,8 @@ type containerSetup struct {
 	appContainers map[string]libcontainer.Container
 	appProcesses  map[string]*libcontainer.Process
 	appWaitch     map[string]chan struct{}
+	appBackoff    map[string]time.Duration
+	appFinished   map[string]bool
 }
 
 var (
@@ -72,6 +85,8 @@ var (
 		(*containerSetup).writeState,
 		(*containerSetup).stopProcesses,
 		(*containerSetup).stopContainers,
+		(*containerSetup).markExited,
+		(*containerSetup).writeState,
 	}
 )
 
@@ -98,6 +113,10 @@ func (cs *containerSetup) run() error {
 // state they're accessing, as the stager may be tearing down after only
 // partially setting up.
 func (cs *containerSetup) stop() {
+	// Teardown may be triggered concurrently by a signal, the init process
+	// exiting, or the pod's exit policy, so ensure it only happens once.
+	cs.stopMutex.Lock()
+	defer cs.stopMutex.Unlock()
 	if cs.isStopping {
 		return
 	}
@@ -115,18 +134,28 @@ func (cs *containerSetup) stop() {
 // file. This can be read by other processes calling in to the stager's exposed
 // command API to quickly access the pod state.
 func (cs *containerSetup) writeState() error {
-	f, err := os.OpenFile("state.json", os.O_WRONLY|os.O_CREATE, os.FileMode(0600))
+	cs.stateMutex.Lock()
+	defer cs.stateMutex.Unlock()
+
+	// Write to a temporary file and rename it into place so that call in
+	// commands never observe a partially written or stale trailing state. The
+	// temporary file is unique so concurrent writers never share it.
+	f, err := ioutil.TempFile(filepath.Dir(common.StagerStateFile), filepath.Base(common.StagerStateFile)+".")
 	if err != nil {
-		return fmt.Errorf("failed to open the state JSON file")
+		return fmt.Errorf("failed to open the state JSON file: %v", err)
 	}
+	defer os.Remove(f.Name())
 	defer f.Close()
 
-	cs.stateMutex.Lock()
-	defer cs.stateMutex.Unlock()
-
 	if err := json.NewEncoder(f).Encode(cs.state); err != nil {
 		return fmt.Errorf("failed to write the stager state: %v", err)
 	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close the state JSON file: %v", err)
+	}
+	if err := os.Rename(f.Name(), common.StagerStateFile); err != nil {
+		return fmt.Errorf("failed to move the state JSON file into place: %v", err)
+	}
 	return nil
 }
 
@@ -209,6 +238,7 @@ func (cs *containerSetup) containerFilesystem() error {
 	os.Mkdir("/apps", os.FileMode(0755))
 	os.Mkdir("/init", os.FileMode(0755))
 	os.Mkdir("/logs", os.FileMode(0755))
+	os.Mkdir(common.AttachDirectory, os.FileMode(0755))
 
 	// Create the configured provisioner
 	var err error
@@ -237,6 +267,18 @@ func (cs *containerSetup) containerFilesystem() error {
 		return fmt.Errorf("failed to configure app storage: %v", err)
 	}
 
+	// When the pod has a user namespace, the init filesystem and any empty
+	// volumes need to be owned by users mapped into it.
+	uidMappings, gidMappings := cs.userNamespaceMappings()
+	if uidMappings != nil {
+		if err := graphstorage.ShiftOwnership("/init", uidMappings, gidMappings); err != nil {
+			return fmt.Errorf("failed to shift ownership of the init filesystem: %v", err)
+		}
+		if err := cs.shiftVolumeOwnership(uidMappings, gidMappings); err != nil {
+			return err
+		}
+	}
+
 	// Setup the applications
 	for _, app := range cs.manifest.Pod.Apps {
 		name := app.Name.String()
@@ -255,6 +297,15 @@ func (cs *containerSetup) containerFilesystem() error {
 			return fmt.Errorf("failed to configure app %q filesystem: %v", name, err)
 		}
 
+		// Shift the ownership of the app's filesystem so that it is owned by the
+		// correct users within the user namespace. This is done before any volumes
+		// are mounted so that only the app's own filesystem is changed.
+		if uidMappings != nil {
+			if err := graphstorage.ShiftOwnership(apppath, uidMappings, gidMappings); err != nil {
+				return fmt.Errorf("failed to shift ownership of app %q filesystem: %v", name, err)
+			}
+		}
+
 		// Apply volumes to the application as well
 		for _, mount := range app.Mounts {
 			appVolume := filepath.Join(apppath, mount.Path)
@@ -273,6 +324,40 @@ func (cs *containerSetup) containerFilesystem() error {
 	return nil
 }
 
+// shiftVolumeOwnership changes the owner of the pod's empty volumes to the
+// user and group that the volume specifies, as mapped into the pod's user
+// namespace, so they are writable from within it. Host volumes are left as is,
+// since their contents are shared with the host.
+func (cs *containerSetup) shiftVolumeOwnership(uidMappings, gidMappings []configs.IDMap) error {
+	for _, volume := range cs.manifest.Pod.Volumes {
+		if volume.Kind != "empty" {
+			continue
+		}
+
+		uid, gid := 0, 0
+		if volume.UID != nil {
+			uid = *volume.UID
+		}
+		if volume.GID != nil {
+			gid = *volume.GID
+		}
+		hostUID, ok := graphstorage.MapID(uid, uidMappings)
+		if !ok {
+			return fmt.Errorf("the uid %d for volume %q is not mapped into the user namespace", uid, volume.Name)
+		}
+		hostGID, ok := graphstorage.MapID(gid, gidMappings)
+		if !ok {
+			return fmt.Errorf("the gid %d for volume %q is not mapped into the user namespace", gid, volume.Name)
+		}
+
+		volPath := filepath.Join("/volumes", volume.Name.String())
+		if err := os.Lchown(volPath, hostUID, hostGID); err != nil {
+			return fmt.Errorf("failed to change the owner of volume %q: %v", volume.Name, err)
+		}
+	}
+	return nil
+}
+
 // launchInit is used to launch the init process for the pod, which will be used
 // to initially create the main namespaces.
 func (cs *containerSetup) launchInit() error {
@@ -343,59 +428,73 @@ func (cs *containerSetup) createContainers() error {
 		cs.appContainers[name] = container
 		cs.appMutex.Unlock()
 
-		// validate the working directory
-		workingDirectory := app.WorkingDirectory
-		if workingDirectory == "" {
-			workingDirectory = "/"
+		// Setup the log file that all output from the container will be written
+		// to, which also handles clients attaching to the app. It is retained
+		// across restarts of the app and closed once it has finally exited.
+		output, err := newAppOutput(cs.log, name)
+		if err != nil {
+			return fmt.Errorf("failed to setup output for app %q: %v", name, err)
 		}
+		go output.accept()
 
-		cs.log.Tracef("Launching application [%q:%q]: %#v", app.User, app.Group, app.Exec)
-
-		// Open a log file that all output from the container will be written to
-		flags := os.O_WRONLY | os.O_APPEND | os.O_CREATE | os.O_EXCL | os.O_TRUNC
-		applog, err := os.OpenFile(filepath.Join("/logs", name), flags, os.FileMode(0666))
-		if err != nil {
+		if err := cs.launchApp(name, app, container, output); err != nil {
+			output.close()
 			return err
 		}
-		defer applog.Close()
+	}
 
-		process := &libcontainer.Process{
-			Cwd:  workingDirectory,
-			User: app.User,
-			Args: app.Exec,
-		}
-		for _, env := range app.Environment {
-			process.Env = append(process.Env, fmt.Sprintf("%s=%s", env.Name, env.Value))
-		}
+	return nil
+}
 
-		// apply inputs/outputs passed in, then apply defaults
-		cs.applyIO(name, process)
-		if process.Stdout == nil {
-			process.Stdout = applog
-		}
-		if process.Stderr == nil {
-			process.Stderr = applog
-		}
+// launchApp starts the primary process for an application within its
+// container and begins waiting on it. It is used both for the initial launch
+// and when the app is restarted.
+func (cs *containerSetup) launchApp(name string, app *types.App, container libcontainer.Container, output *appOutput) error {
+	// validate the working directory
+	workingDirectory := app.WorkingDirectory
+	if workingDirectory == "" {
+		workingDirectory = "/"
+	}
 
-		if err := container.Start(process); err != nil {
-			return fmt.Errorf("failed to launch app %q process: %v", name, err)
-		}
-		cs.appMutex.Lock()
-		cs.appProcesses[name] = process
-		cs.appMutex.Unlock()
+	cs.log.Tracef("Launching application [%q:%q]: %#v", app.User, app.Group, app.Exec)
 
-		pid, err := process.Pid()
-		if err != nil {
-			return fmt.Errorf("failed to retrieve the pid of application %q: %v", name, err)
-		}
-		cs.log.Tracef("Launched app %q process, pid: %d", name, pid)
-		cs.stateMutex.Lock()
-		cs.state.Apps[name].Pid = pid
-		cs.stateMutex.Unlock()
+	process := &libcontainer.Process{
+		Cwd:  workingDirectory,
+		User: app.User,
+		Args: app.Exec,
+	}
+	for _, env := range app.Environment {
+		process.Env = append(process.Env, fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
 
-		go cs.appWait(name, process)
+	// apply inputs/outputs passed in, then apply defaults
+	cs.applyIO(name, process)
+	if err := output.setupProcess(process, isInteractive(app)); err != nil {
+		return fmt.Errorf("failed to setup output for app %q: %v", name, err)
 	}
 
+	if err := container.Start(process); err != nil {
+		output.processExited()
+		return fmt.Errorf("failed to launch app %q process: %v", name, err)
+	}
+	cs.appMutex.Lock()
+	cs.appProcesses[name] = process
+	cs.appMutex.Unlock()
+
+	pid, err := process.Pid()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the pid of application %q: %v", name, err)
+	}
+	cs.log.Tracef("Launched app %q process, pid: %d", name, pid)
+	cs.stateMutex.Lock()
+	appState := cs.state.Apps[name]
+	appState.Pid = pid
+	appState.Exited = false
+	appState.ExitCode = 0
+	appState.ExitReason = ""
+	cs.stateMutex.Unlock()
+
+	go cs.appWait(name, app, process, output)
 	return nil
 }
 
@@ -420,6 +519,7 @@ func (cs *containerSetup) signalReadyPipe() error {
 
 func (cs *containerSetup) markShuttingDown() error {
 	cs.isStopping = true
+	close(cs.shutdownCh)
 	cs.log.Info("Marking stager as shutting down.")
 	cs.stateMutex.Lock()
 	cs.state.State = common.StagerStateTeardown
@@ -427,17 +527,41 @@ func (cs *containerSetup) markShuttingDown() error {
 	return nil
 }
 
+// markExited is used to update the state once teardown has completed, and to
+// record the exit code of the pod. If the pod's exit policy was not what
+// stopped it, the exit code is the first non-zero exit code of its apps.
+func (cs *containerSetup) markExited() error {
+	cs.log.Info("Marking stager as exited.")
+	cs.stateMutex.Lock()
+	defer cs.stateMutex.Unlock()
+	cs.state.State = common.StagerStateExited
+	if cs.exitCode != nil {
+		cs.state.ExitCode = *cs.exitCode
+	} else {
+		cs.state.ExitCode = cs.firstFailedExitCode()
+	}
+	return nil
+}
+
 // stopProcesses signals the processes within the containers to stop.
 func (cs *containerSetup) stopProcesses() error {
 	cs.log.Debug("Stopping application processes")
 	wg := sync.WaitGroup{}
 
+	// Copy the running processes so the apps can update their state as they
+	// exit while we wait on them.
 	cs.appMutex.RLock()
-	defer cs.appMutex.RUnlock()
+	processes := make(map[string]*libcontainer.Process, len(cs.appProcesses))
+	waitchs := make(map[string]chan struct{}, len(cs.appWaitch))
+	for app, process := range cs.appProcesses {
+		processes[app] = process
+		waitchs[app] = cs.appWaitch[app]
+	}
+	cs.appMutex.RUnlock()
 
 	// Stop the user applications first. For them, send a TERM signal, allow 30
 	// seconds for them to stop, then send a kill.
-	for app, process := range cs.appProcesses {
+	for app, process := range processes {
 		cs.log.Tracef("Sending app %q TERM signal", app)
 		if err := process.Signal(os.Signal(syscall.SIGTERM)); err != nil {
 			cs.log.Errorf("failed to TERM process %q: %v", app, err)
@@ -463,7 +587,7 @@ func (cs *containerSetup) stopProcesses() error {
 			if err := process.Signal(os.Signal(syscall.SIGKILL)); err != nil {
 				cs.log.Errorf("failed to SIGKILL process %q: %v", app, err)
 			}
-		}(app, cs.appWaitch[app], process)
+		}(app, waitchs[app], process)
 	}
 
 	// Wait for all the apps to finish.
@@ -535,34 +659,152 @@ func (cs *containerSetup) initWait() {
 }
 
 // appWait is used to call Wait on an app's process and update the container
-// state if the processes exits.
-func (cs *containerSetup) appWait(name string, process *libcontainer.Process) {
+// state if the processes exits. If the app's restart policy calls for it, the
+// app will be relaunched after a backoff.
+func (cs *containerSetup) appWait(name string, app *types.App, process *libcontainer.Process, output *appOutput) {
 	ch := make(chan struct{})
 	cs.appMutex.Lock()
 	cs.appWaitch[name] = ch
 	cs.appMutex.Unlock()
 
+	startTime := time.Now()
 	ps, err := process.Wait()
-	close(ch)
+	output.processExited()
+
+	cs.appMutex.Lock()
+	delete(cs.appProcesses, name)
+	cs.appMutex.Unlock()
 
+	// Record the exit before signaling the app has exited, so that teardown
+	// will capture it in the final state.
 	cs.stateMutex.Lock()
-	cs.state.Apps[name].Pid = 0
-	cs.state.Apps[name].Exited = true
+	appState := cs.state.Apps[name]
+	appState.Pid = 0
+	appState.Exited = true
 	if ps != nil {
-		cs.state.Apps[name].ExitCode = ps.Sys().(syscall.WaitStatus).ExitStatus()
+		status := ps.Sys().(syscall.WaitStatus)
+		if status.Signaled() {
+			// follow the shell convention for processes killed by a signal
+			appState.ExitCode = 128 + int(status.Signal())
+		} else {
+			appState.ExitCode = status.ExitStatus()
+		}
 	}
 	if err != nil {
-		cs.state.Apps[name].ExitReason = err.Error()
+		appState.ExitReason = err.Error()
 	}
+	exitCode := appState.ExitCode
+	exitReason := appState.ExitReason
+	restartCount := appState.RestartCount
 	cs.stateMutex.Unlock()
+	close(ch)
 
-	cs.log.Warnf("Application %q has exited %d: %s", name, cs.state.Apps[name].ExitCode, cs.state.Apps[name].ExitReason)
+	cs.log.Warnf("Application %q has exited %d: %s", name, exitCode, exitReason)
 
 	if cs.isShuttingDown() {
+		output.close()
+		return
+	}
+
+	if err := cs.writeState(); err != nil {
+		cs.log.Errorf("Failed to write state file: %v", err)
+	}
+
+	policy := cs.getRestartPolicy(app)
+	if policy == nil || !policy.ShouldRestart(exitCode, restartCount) {
+		output.close()
+		cs.appDone(name)
+		return
+	}
+
+	// Back off before restarting. Apps which ran for a while before exiting are
+	// restarted quickly, while ones that keep failing wait increasingly longer.
+	cs.appMutex.Lock()
+	if time.Since(startTime) >= maxRestartBackoff {
+		cs.appBackoff[name] = 0
+	}
+	backoff := nextRestartBackoff(cs.appBackoff[name])
+	cs.appBackoff[name] = backoff
+	container := cs.appContainers[name]
+	cs.appMutex.Unlock()
+
+	cs.log.Infof("Restarting application %q in %v", name, backoff)
+	select {
+	case <-time.After(backoff):
+	case <-cs.shutdownCh:
+		output.close()
+		return
+	}
+
+	// Teardown may have begun once the backoff elapsed, so check again under
+	// the lock before relaunching the app.
+	cs.stateMutex.Lock()
+	if cs.state.State == common.StagerStateTeardown || cs.state.State == common.StagerStateExited {
+		cs.stateMutex.Unlock()
+		output.close()
 		return
 	}
+	appState.RestartCount++
+	cs.stateMutex.Unlock()
 
+	if err := cs.launchApp(name, app, container, output); err != nil {
+		cs.log.Errorf("Failed to restart application %q: %v", name, err)
+		cs.stateMutex.Lock()
+		appState.Exited = true
+		appState.ExitReason = err.Error()
+		cs.stateMutex.Unlock()
+		output.close()
+		if err := cs.writeState(); err != nil {
+			cs.log.Errorf("Failed to write state file: %v", err)
+		}
+		cs.appDone(name)
+		return
+	}
 	if err := cs.writeState(); err != nil {
 		cs.log.Errorf("Failed to write state file: %v", err)
 	}
 }
+
+// appDone is called once an app has exited and won't be restarted. If this
+// satisfies the pod's exit policy, the pod is torn down and the stager exits.
+func (cs *containerSetup) appDone(name string) {
+	cs.appMutex.Lock()
+	cs.appFinished[name] = true
+	allFinished := len(cs.appFinished) == len(cs.manifest.Pod.Apps)
+	cs.appMutex.Unlock()
+
+	policy := cs.getExitPolicy()
+	if policy == nil {
+		return
+	}
+	switch policy.Policy {
+	case kschema.ExitPolicyAll:
+		if !allFinished {
+			return
+		}
+	case kschema.ExitPolicyAny:
+	case kschema.ExitPolicyApp:
+		if policy.App.String() != name {
+			return
+		}
+	default:
+		return
+	}
+
+	if cs.isShuttingDown() {
+		return
+	}
+
+	cs.stateMutex.Lock()
+	exitCode := cs.state.Apps[name].ExitCode
+	if policy.Policy == kschema.ExitPolicyAll {
+		exitCode = cs.firstFailedExitCode()
+	}
+	cs.exitCode = &exitCode
+	cs.stateMutex.Unlock()
+
+	cs.log.Infof("Pod exit policy %q met by app %q exiting, stopping the pod with exit code %d", policy.Policy, name, exitCode)
+	cs.stop()
+	fmt.Fprintln(os.Stderr, "Stager teardown complete, exiting")
+	os.Exit(0)
+}
@@ -4,6 +4,7 @@ package core
 
 import (
 	"runtime"
+	"time"
 
 	"github.com/apcera/logray"
 	"github.com/opencontainers/runc/libcontainer"
@@ -17,6 +18,9 @@ func Run() error {
 		appContainers: make(map[string]libcontainer.Container),
 		appProcesses:  make(map[string]*libcontainer.Process),
 		appWaitch:     make(map[string]chan struct{}),
+		appBackoff:    make(map[string]time.Duration),
+		appFinished:   make(map[string]bool),
+		shutdownCh:    make(chan struct{}),
 	}
 	if err := cs.run(); err != nil {
 		cs.log.Flush()
@@ -7,6 +7,7 @@ import (
 	"syscall"
 
 	"github.com/apcera/kurma/pkg/capabilities"
+	"github.com/apcera/kurma/pkg/resources"
 	"github.com/appc/spec/schema/types"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/devices"
@@ -16,7 +17,13 @@ import (
 
 var (
 	isolatorFuncs = map[string]func(*containerSetup, types.IsolatorValue, *types.App, *configs.Config) error{
-		kschema.LinuxPrivilegedName: (*containerSetup).applyPrivilegedIsolator,
+		kschema.LinuxPrivilegedName:          (*containerSetup).applyPrivilegedIsolator,
+		types.LinuxCapabilitiesRetainSetName: (*containerSetup).applyCapabilitiesRetainSet,
+		types.LinuxCapabilitiesRevokeSetName: (*containerSetup).applyCapabilitiesRemoveSet,
+		types.ResourceMemoryName:             (*containerSetup).applyResourceIsolator,
+		types.ResourceCPUName:                (*containerSetup).applyResourceIsolator,
+		types.ResourceBlockBandwidthName:     (*containerSetup).applyResourceIsolator,
+		types.ResourceBlockIOPSName:          (*containerSetup).applyResourceIsolator,
 	}
 )
 
@@ -62,3 +69,44 @@ func (cs *containerSetup) applyPrivilegedIsolator(iso types.IsolatorValue, app *
 	container.Devices = devices
 	return nil
 }
+
+// applyCapabilitiesRetainSet limits the app to only the capabilities in the
+// isolator's set.
+func (cs *containerSetup) applyCapabilitiesRetainSet(iso types.IsolatorValue, app *types.App, container *configs.Config) error {
+	ciso, ok := iso.(*types.LinuxCapabilitiesRetainSet)
+	if !ok {
+		return nil
+	}
+
+	caps, err := capabilities.Retain(capabilities.Names(ciso))
+	if err != nil {
+		return err
+	}
+	container.Capabilities = caps
+	return nil
+}
+
+// applyCapabilitiesRemoveSet removes the capabilities in the isolator's set from
+// those the app would otherwise be granted.
+func (cs *containerSetup) applyCapabilitiesRemoveSet(iso types.IsolatorValue, app *types.App, container *configs.Config) error {
+	ciso, ok := iso.(*types.LinuxCapabilitiesRevokeSet)
+	if !ok {
+		return nil
+	}
+
+	caps, err := capabilities.Remove(container.Capabilities, capabilities.Names(ciso))
+	if err != nil {
+		return err
+	}
+	container.Capabilities = caps
+	return nil
+}
+
+// applyResourceIsolator applies one of the appc resource isolators to the app's
+// cgroup. The limits were validated by the pod manager when the pod was
+// created.
+func (cs *containerSetup) applyResourceIsolator(iso types.IsolatorValue, app *types.App, container *configs.Config) error {
+	limits := &resources.Limits{}
+	limits.Set(iso)
+	return limits.Apply(container.Cgroups.Resources)
+}
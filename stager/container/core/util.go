@@ -8,7 +8,9 @@ import (
 	"path/filepath"
 	"strconv"
 	"syscall"
+	"time"
 
+	"github.com/apcera/kurma/pkg/capabilities"
 	"github.com/apcera/kurma/stager/container/common"
 	"github.com/appc/spec/schema"
 	"github.com/appc/spec/schema/types"
@@ -119,6 +121,14 @@ func (cs *containerSetup) getInitContainerConfig() (*configs.Config, error) {
 		cs.applyNamespacesIsolator(config, nsiso)
 	}
 
+	// Create the pod's user namespace if one was requested.
+	if uidMappings, gidMappings := cs.userNamespaceMappings(); uidMappings != nil {
+		config.Namespaces.Add(configs.NEWUSER, "")
+		config.UidMappings = uidMappings
+		config.GidMappings = gidMappings
+		config.Mounts = userNamespaceMounts(config.Mounts)
+	}
+
 	return config, nil
 }
 
@@ -131,22 +141,7 @@ func (cs *containerSetup) getAppContainerConfig(runtimeApp schema.RuntimeApp) (*
 	}
 
 	config := &configs.Config{
-		Capabilities: []string{
-			"CAP_CHOWN",
-			"CAP_DAC_OVERRIDE",
-			"CAP_FSETID",
-			"CAP_FOWNER",
-			"CAP_MKNOD",
-			"CAP_NET_RAW",
-			"CAP_SETGID",
-			"CAP_SETUID",
-			"CAP_SETFCAP",
-			"CAP_SETPCAP",
-			"CAP_NET_BIND_SERVICE",
-			"CAP_SYS_CHROOT",
-			"CAP_KILL",
-			"CAP_AUDIT_WRITE",
-		},
+		Capabilities:      append([]string(nil), capabilities.DefaultCapabilities...),
 		ParentDeathSignal: int(syscall.SIGTERM),
 		Rootfs:            filepath.Join("/apps", name),
 		RootPropagation:   syscall.MS_PRIVATE,
@@ -180,6 +175,14 @@ func (cs *containerSetup) getAppContainerConfig(runtimeApp schema.RuntimeApp) (*
 		Mounts:  defaultContainerMounts,
 	}
 
+	// Join the pod's user namespace if it has one.
+	if uidMappings, gidMappings := cs.userNamespaceMappings(); uidMappings != nil {
+		config.Namespaces.Add(configs.NEWUSER, fmt.Sprintf("/proc/%d/ns/user", initPid))
+		config.UidMappings = uidMappings
+		config.GidMappings = gidMappings
+		config.Mounts = userNamespaceMounts(config.Mounts)
+	}
+
 	app := cs.getPodApp(runtimeApp)
 
 	// apply isolators to the pod
@@ -197,10 +200,86 @@ func (cs *containerSetup) getPodApp(runtimeApp schema.RuntimeApp) *types.App {
 	return cs.manifest.Images[runtimeApp.Image.ID.String()].App
 }
 
+const (
+	// minRestartBackoff is the delay before an app is first restarted.
+	minRestartBackoff = time.Second
+
+	// maxRestartBackoff is the longest delay between restarts of an app. Apps
+	// which run for longer than this before exiting have their backoff reset.
+	maxRestartBackoff = 5 * time.Minute
+)
+
+// getRestartPolicy returns the restart policy for the app. A policy specified
+// on the app takes precedence over one specified on the pod. It returns nil if
+// neither specifies one.
+func (cs *containerSetup) getRestartPolicy(app *types.App) *kschema.AppRestartPolicy {
+	if iso := app.Isolators.GetByName(kschema.AppRestartPolicyName); iso != nil {
+		if piso, ok := iso.Value().(*kschema.AppRestartPolicy); ok {
+			return piso
+		}
+	}
+	for _, iso := range cs.manifest.Pod.Isolators {
+		if iso.Name.String() == kschema.AppRestartPolicyName {
+			if piso, ok := iso.Value().(*kschema.AppRestartPolicy); ok {
+				return piso
+			}
+		}
+	}
+	return nil
+}
+
+// nextRestartBackoff returns the delay to use before the next restart, doubling
+// the previous delay up to the maximum.
+func nextRestartBackoff(previous time.Duration) time.Duration {
+	if previous < minRestartBackoff {
+		return minRestartBackoff
+	}
+	if next := previous * 2; next < maxRestartBackoff {
+		return next
+	}
+	return maxRestartBackoff
+}
+
+// getExitPolicy returns the exit policy specified on the pod, or nil if one
+// was not specified.
+func (cs *containerSetup) getExitPolicy() *kschema.PodExitPolicy {
+	for _, iso := range cs.manifest.Pod.Isolators {
+		if iso.Name.String() == kschema.PodExitPolicyName {
+			if piso, ok := iso.Value().(*kschema.PodExitPolicy); ok {
+				return piso
+			}
+		}
+	}
+	return nil
+}
+
+// firstFailedExitCode returns the first non-zero exit code of the apps, in the
+// order they're specified in the pod manifest, or zero if none have failed. The
+// stateMutex must be held when calling it.
+func (cs *containerSetup) firstFailedExitCode() int {
+	for _, app := range cs.manifest.Pod.Apps {
+		if appState := cs.state.Apps[app.Name.String()]; appState != nil && appState.ExitCode != 0 {
+			return appState.ExitCode
+		}
+	}
+	return 0
+}
+
+// isInteractive checks whether the app has requested to be launched
+// interactively, allowing clients that attach to it to write to its console.
+func isInteractive(app *types.App) bool {
+	if iso := app.Isolators.GetByName(kschema.AppInteractiveName); iso != nil {
+		if piso, ok := iso.Value().(*kschema.AppInteractive); ok {
+			return bool(*piso)
+		}
+	}
+	return false
+}
+
 func (cs *containerSetup) isShuttingDown() bool {
 	cs.stateMutex.Lock()
 	defer cs.stateMutex.Unlock()
-	return cs.state.State == common.StagerStateTeardown
+	return cs.state.State == common.StagerStateTeardown || cs.state.State == common.StagerStateExited
 }
 
 // getNamespaceIsolator checks the pod manifest to see is a linux namespace
@@ -216,6 +295,58 @@ func getNamespaceIsolator(pod *schema.PodManifest) *kschema.LinuxNamespaces {
 	return nil
 }
 
+// getUserNamespaceIsolator returns the pod's user namespace isolator, or nil if
+// the pod isn't to be run within a user namespace.
+func getUserNamespaceIsolator(pod *schema.PodManifest) *kschema.LinuxUserNamespace {
+	for _, iso := range pod.Isolators {
+		if iso.Name.String() == kschema.LinuxUserNamespaceName {
+			if uiso, ok := iso.Value().(*kschema.LinuxUserNamespace); ok {
+				return uiso
+			}
+		}
+	}
+	return nil
+}
+
+// userNamespaceMappings returns the uid and gid mappings for the pod's user
+// namespace. They are nil if the pod doesn't use a user namespace. Kurma will
+// have already allocated mappings if the pod didn't specify its own.
+func (cs *containerSetup) userNamespaceMappings() ([]configs.IDMap, []configs.IDMap) {
+	uiso := getUserNamespaceIsolator(cs.manifest.Pod)
+	if uiso == nil || !uiso.HasMappings() {
+		return nil, nil
+	}
+
+	convert := func(mappings []kschema.IDMapping) []configs.IDMap {
+		idmaps := make([]configs.IDMap, len(mappings))
+		for i, m := range mappings {
+			idmaps[i] = configs.IDMap{ContainerID: m.ContainerID, HostID: m.HostID, Size: m.Size}
+		}
+		return idmaps
+	}
+	return convert(uiso.UIDMappings), convert(uiso.GIDMappings)
+}
+
+// userNamespaceMounts returns the mounts to use for a container within a user
+// namespace. Mounting sysfs requires owning the network namespace, which is
+// created by Kurma outside of the pod's user namespace, so it is bind mounted
+// from the stager instead.
+func userNamespaceMounts(mounts []*configs.Mount) []*configs.Mount {
+	result := make([]*configs.Mount, len(mounts))
+	for i, m := range mounts {
+		if m.Device == "sysfs" {
+			m = &configs.Mount{
+				Source:      "/sys",
+				Destination: m.Destination,
+				Device:      "bind",
+				Flags:       syscall.MS_BIND | syscall.MS_REC | syscall.MS_RDONLY,
+			}
+		}
+		result[i] = m
+	}
+	return result
+}
+
 // needNewNamespace returns whether or not a new namespace is needed on the
 // launcher object based on the LinuxNamespaceValue.
 func needNewNamespace(val kschema.LinuxNamespaceValue) bool {
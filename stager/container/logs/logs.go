@@ -0,0 +1,78 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package logs
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apcera/kurma/pkg/logfile"
+	"github.com/apcera/kurma/stager/container/common"
+)
+
+// Run writes out the log for the specified application over stdout. When
+// following, it continues to stream the log until the application exits or the
+// process is killed.
+func Run() error {
+	var since string
+	opts := &logfile.Options{Timestamped: true}
+
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	flags.BoolVar(&opts.Follow, "follow", false, "follow the log output")
+	flags.IntVar(&opts.Tail, "tail", 0, "number of lines from the end of the log to show")
+	flags.StringVar(&since, "since", "", "only show lines since the specified RFC3339 timestamp")
+	flags.BoolVar(&opts.Timestamps, "timestamps", false, "include the timestamp of each line")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("the name of the application must be specified")
+	}
+	appName := flags.Arg(0)
+
+	if since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			return fmt.Errorf("failed to parse the since time: %v", err)
+		}
+		opts.Since = t
+	}
+
+	if opts.Follow {
+		stop := make(chan struct{})
+		go waitForExit(appName, stop)
+		opts.Stop = stop
+	}
+
+	return logfile.Copy(os.Stdout, filepath.Join("/logs", appName), opts)
+}
+
+// waitForExit polls the stager state and closes the channel once the specified
+// application has exited or the stager is tearing down.
+func waitForExit(appName string, stop chan struct{}) {
+	defer close(stop)
+
+	for ; ; time.Sleep(time.Second) {
+		f, err := os.Open(common.StagerStateFile)
+		if err != nil {
+			return
+		}
+		var state *common.StagerState
+		err = json.NewDecoder(f).Decode(&state)
+		f.Close()
+		if err != nil {
+			return
+		}
+
+		if state.State == common.StagerStateTeardown || state.State == common.StagerStateExited {
+			return
+		}
+		if app, ok := state.Apps[appName]; !ok || app.Exited {
+			return
+		}
+	}
+}
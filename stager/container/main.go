@@ -10,8 +10,11 @@ import (
 	"path/filepath"
 	"runtime"
 
+	"github.com/apcera/kurma/stager/container/attach"
 	"github.com/apcera/kurma/stager/container/core"
+	"github.com/apcera/kurma/stager/container/logs"
 	"github.com/apcera/kurma/stager/container/run"
+	"github.com/apcera/kurma/stager/container/status"
 
 	"github.com/opencontainers/runc/libcontainer"
 	_ "github.com/opencontainers/runc/libcontainer/nsenter"
@@ -36,8 +39,14 @@ func main() {
 	switch execName {
 	case "stager":
 		execFunc = core.Run
+	case "attach":
+		execFunc = attach.Run
+	case "logs":
+		execFunc = logs.Run
 	case "run":
 		execFunc = run.Run
+	case "status":
+		execFunc = status.Run
 	default:
 		fmt.Fprintf(os.Stderr, "Unrecognized command %q", execName)
 		os.Exit(1)
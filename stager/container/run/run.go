@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"syscall"
 
 	"github.com/apcera/kurma/schema"
 	"github.com/opencontainers/runc/libcontainer"
@@ -71,10 +72,21 @@ func Run() error {
 	if err := container.Start(process); err != nil {
 		return err
 	}
-	process.Wait()
+	state, _ := process.Wait()
 
 	// Wait for other routines to finish up and flush output
 	wg.Wait()
 
+	// Exit with the process's exit code so the caller, such as the network
+	// manager calling into a plugin, can tell whether it failed.
+	if state != nil {
+		if status, ok := state.Sys().(syscall.WaitStatus); ok {
+			if status.Signaled() {
+				os.Exit(128 + int(status.Signal()))
+			} else if status.ExitStatus() != 0 {
+				os.Exit(status.ExitStatus())
+			}
+		}
+	}
 	return nil
 }
@@ -0,0 +1,28 @@
+// Copyright 2016 Apcera Inc. All rights reserved.
+
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/apcera/kurma/stager/container/common"
+)
+
+// Run reads the state file that the stager maintains and writes out the status
+// of each of the applications in the pod as JSON over stdout.
+func Run() error {
+	f, err := os.Open(common.StagerStateFile)
+	if err != nil {
+		return fmt.Errorf("failed to open the stager state: %v", err)
+	}
+	defer f.Close()
+
+	var state *common.StagerState
+	if err := json.NewDecoder(f).Decode(&state); err != nil {
+		return fmt.Errorf("failed to parse the stager state: %v", err)
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(state.Apps)
+}